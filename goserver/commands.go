@@ -0,0 +1,607 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"goserver/database"
+	"goserver/filestore"
+	"goserver/logging"
+	"goserver/models"
+	"goserver/repository"
+	"goserver/services"
+	"goserver/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `Pendataan IGD - Go API Server
+
+Usage:
+  goserver [command] [flags]
+
+Commands:
+  serve                       Start the HTTP API server (default)
+  migrate up                  Apply pending migrations
+  migrate down [--steps N]    Revert the last N migrations (default 1)
+  migrate status              Show applied/pending migrations and schema drift
+  seed [--file path]          Load sample_data.sql or a SQL fixture file
+  user create-admin           Create (or promote) an admin user
+  escort export               Export escorts to CSV or XLSX
+  storage migrate             Move escort photos to another storage backend
+  storage backfill-variants   Create missing thumbnail and medium photo variants
+  storage scan                Report orphaned and missing photos, quarantine and purge orphans
+  check                       Check configuration and database connectivity
+
+Run 'goserver <command> -h' for the flags of a command.
+`
+
+// runCommand dispatches os.Args to a subcommand; no arguments starts the server
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		return runServe(nil)
+	}
+
+	switch args[0] {
+	case "serve":
+		return runServe(args[1:])
+	case "migrate":
+		return runMigrate(args[1:])
+	case "seed":
+		return runSeed(args[1:])
+	case "user":
+		return runUser(args[1:])
+	case "escort":
+		return runEscort(args[1:])
+	case "storage":
+		return runStorage(args[1:])
+	case "check":
+		return runCheck(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runServe starts the HTTP API server
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	migrate := flags.Bool("migrate", true, "apply pending migrations before serving")
+	flags.Parse(args)
+
+	// Load configuration
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Structured logs from here on; the log package writes through slog as well
+	slog.SetDefault(logging.New(os.Stdout, config.LogLevel, config.LogFormat))
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), config.TracesExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer func() {
+		// Send the spans still buffered
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush trace spans", "error", err)
+		}
+	}()
+
+	// Initialize server; setupRoutes adds the request logging and recovery middleware
+	server := &Server{
+		router: gin.New(),
+		config: config,
+	}
+	if err := server.router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// Connect to database
+	if err := server.connectDatabase(config, *migrate); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer server.db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := server.openStorage(ctx); err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	slog.Info("Opened photo storage", "storage", server.files.String())
+
+	// Setup routes
+	server.startEscortFeed(ctx)
+	server.startWebhookDispatcher(ctx)
+	server.startImageVariantBackfill(ctx)
+	server.startStorageScan(ctx)
+	server.startIdempotencyCleanup(ctx)
+	server.startRateLimiter(ctx)
+	server.startMetricsServer(ctx)
+	server.setupRoutes()
+
+	// Start server
+	port := getEnv("PORT", "8080")
+	slog.Info("Starting server", "port", port, "environment", config.AppEnv, "version", version)
+	return server.router.Run(":" + port)
+}
+
+// runMigrate handles `migrate up|down|status`
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: goserver migrate up|down|status")
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert (down only)")
+	flags.Parse(args[1:])
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, _ *Config) error {
+		migrator, err := database.NewMigrator(db)
+		if err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "up":
+			applied, err := migrator.Up(ctx)
+			if err != nil {
+				return err
+			}
+			for _, migration := range applied {
+				fmt.Printf("Applied  %03d_%s\n", migration.Version, migration.Name)
+			}
+			fmt.Printf("%d migration(s) applied\n", len(applied))
+			return nil
+
+		case "down":
+			if *steps < 1 {
+				return errors.New("--steps must be at least 1")
+			}
+			reverted, err := migrator.Down(ctx, *steps)
+			if err != nil {
+				return err
+			}
+			for _, migration := range reverted {
+				fmt.Printf("Reverted %03d_%s\n", migration.Version, migration.Name)
+			}
+			fmt.Printf("%d migration(s) reverted\n", len(reverted))
+			return nil
+
+		case "status":
+			return printMigrationStatus(ctx, os.Stdout, migrator)
+
+		default:
+			return fmt.Errorf("unknown migrate command %q (expected up, down or status)", args[0])
+		}
+	})
+}
+
+// printMigrationStatus prints the migration table followed by detected schema drift
+func printMigrationStatus(ctx context.Context, out io.Writer, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state = "applied"
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.ChecksumMismatch {
+			state = "modified"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	w.Flush()
+
+	drift, err := migrator.CheckDrift(ctx)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		fmt.Fprintln(out, "\nNo schema drift detected")
+		return nil
+	}
+	fmt.Fprintf(out, "\nSchema drift (%d):\n", len(drift))
+	for _, d := range drift {
+		fmt.Fprintf(out, "  - %s\n", d)
+	}
+	return nil
+}
+
+// runSeed loads the bundled sample data or a SQL fixture file
+func runSeed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := flags.String("file", "", "SQL fixture file to load instead of the bundled sample_data.sql")
+	flags.Parse(args)
+
+	script := database.SampleData
+	if *file != "" {
+		content, err := os.ReadFile(*file)
+		if err != nil {
+			return fmt.Errorf("failed to read fixture file: %w", err)
+		}
+		script = string(content)
+	}
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, _ *Config) error {
+		if err := database.Seed(ctx, db, script); err != nil {
+			return err
+		}
+		fmt.Println("Seed data loaded successfully")
+		return nil
+	})
+}
+
+// runUser handles `user create-admin`
+func runUser(args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		return errors.New("usage: goserver user create-admin [--email] [--name] [--password] [--reset-password]")
+	}
+
+	flags := flag.NewFlagSet("user create-admin", flag.ExitOnError)
+	email := flags.String("email", "admin@igd.com", "admin email address")
+	name := flags.String("name", "Administrator", "admin display name")
+	password := flags.String("password", "", "admin password (generated when empty)")
+	resetPassword := flags.Bool("reset-password", false, "replace the password when the user already exists")
+	flags.Parse(args[1:])
+
+	generated := *password == ""
+	if generated {
+		*password = randomPassword()
+	}
+	if len(*password) < 6 {
+		return errors.New("--password must be at least 6 characters")
+	}
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, _ *Config) error {
+		user, created, err := services.NewUserService(repository.NewPostgresStore(db).Users).EnsureAdmin(ctx, *name, *email, *password, *resetPassword)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			fmt.Printf("Admin user created successfully (id %d)\n", user.ID)
+		} else {
+			fmt.Printf("User %s already exists and now has the admin role\n", user.Email)
+		}
+		if created || *resetPassword {
+			fmt.Printf("Email: %s\n", user.Email)
+			if generated {
+				fmt.Printf("Password: %s\n", *password)
+			}
+			fmt.Println("Please change the password after first login!")
+		}
+		return nil
+	})
+}
+
+// runEscort handles `escort export`
+func runEscort(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return errors.New("usage: goserver escort export --from YYYY-MM-DD --to YYYY-MM-DD [--format csv|xlsx] [--output file]")
+	}
+
+	flags := flag.NewFlagSet("escort export", flag.ExitOnError)
+	var filters models.ExportFilters
+	flags.StringVar(&filters.StartDate, "from", "", "first day to export (YYYY-MM-DD)")
+	flags.StringVar(&filters.EndDate, "to", "", "last day to export (YYYY-MM-DD)")
+	flags.StringVar(&filters.Status, "status", "", "only export escorts with this status")
+	flags.StringVar(&filters.KategoriPengantar, "kategori", "", "only export this kategori_pengantar")
+	flags.StringVar(&filters.JenisKelamin, "jenis-kelamin", "", "only export this jenis_kelamin")
+	flags.StringVar(&filters.Search, "search", "", "search nama_pengantar, nama_pasien or plat_nomor")
+	format := flags.String("format", services.ExportFormatCSV, "export format: csv or xlsx")
+	output := flags.String("output", "", "output file (defaults to stdout)")
+	flags.Parse(args[1:])
+
+	if _, ok := services.ExportContentTypes[*format]; !ok {
+		return fmt.Errorf("unknown --format %q (expected csv or xlsx)", *format)
+	}
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, _ *Config) error {
+		out := io.Writer(os.Stdout)
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		count, err := services.NewExportService(repository.NewPostgresStore(db).Escorts).Write(ctx, out, *format, filters)
+		if err != nil {
+			return err
+		}
+		if *output != "" {
+			fmt.Printf("Exported %d escort(s) to %s\n", count, *output)
+		}
+		return nil
+	})
+}
+
+// runStorage handles `storage migrate|backfill-variants|scan`
+func runStorage(args []string) error {
+	if len(args) > 0 && args[0] == "backfill-variants" {
+		return runBackfillVariants(args[1:])
+	}
+	if len(args) > 0 && args[0] == "scan" {
+		return runStorageScan(args[1:])
+	}
+	if len(args) == 0 || args[0] != "migrate" {
+		return errors.New("usage: goserver storage migrate --from local|s3 --to local|s3 [--local-root dir] [--delete] [--dry-run]\n" +
+			"       goserver storage backfill-variants\n" +
+			"       goserver storage scan [--dry-run]")
+	}
+
+	flags := flag.NewFlagSet("storage migrate", flag.ExitOnError)
+	from := flags.String("from", "", "backend to move photos from: local or s3")
+	to := flags.String("to", "", "backend to move photos to: local or s3")
+	localRoot := flags.String("local-root", "", "directory of the local backend (defaults to STORAGE_LOCAL_ROOT)")
+	deleteSource := flags.Bool("delete", false, "delete the photos from the source backend after copying")
+	dryRun := flags.Bool("dry-run", false, "only report what would be copied and rewritten")
+	flags.Parse(args[1:])
+
+	if *from == "" || *to == "" {
+		return errors.New("--from and --to are required")
+	}
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, config *Config) error {
+		if *localRoot != "" {
+			config.Storage.LocalRoot = *localRoot
+		}
+
+		backends := make([]filestore.Storage, 2)
+		for i, driver := range []string{*from, *to} {
+			storageConfig := config.Storage
+			storageConfig.Driver = driver
+			backend, err := filestore.New(storageConfig)
+			if err != nil {
+				return err
+			}
+			if err := backend.Ping(ctx); err != nil {
+				return fmt.Errorf("storage %s: %w", backend, err)
+			}
+			backends[i] = backend
+		}
+
+		fmt.Printf("Migrating escort photos from %s to %s\n", backends[0], backends[1])
+		report, err := services.MigrateImages(ctx, repository.NewPostgresStore(db).Escorts, backends[0], backends[1], services.ImageMigrationOptions{
+			DeleteSource: *deleteSource,
+			DryRun:       *dryRun,
+			Logf: func(format string, args ...any) {
+				fmt.Printf("  "+format+"\n", args...)
+			},
+		})
+		if report != nil {
+			fmt.Printf("%d copied, %d already present, %d foto_pengantar rewritten, %d deleted from source, %d missing\n",
+				report.Copied, report.Skipped, report.Rewritten, report.Deleted, len(report.Missing))
+		}
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Println("Dry run: nothing was changed")
+		}
+		return nil
+	})
+}
+
+// runBackfillVariants creates the missing photo variants in the configured storage
+func runBackfillVariants(args []string) error {
+	flags := flag.NewFlagSet("storage backfill-variants", flag.ExitOnError)
+	flags.Parse(args)
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, config *Config) error {
+		files, err := filestore.New(config.Storage)
+		if err != nil {
+			return err
+		}
+
+		created, err := services.NewEscortService(repository.NewPostgresStore(db), files).BackfillImageVariants(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created variants for %d photo(s) in %s\n", created, files)
+		return nil
+	})
+}
+
+// runStorageScan runs one photo storage consistency scan and prints its report
+func runStorageScan(args []string) error {
+	flags := flag.NewFlagSet("storage scan", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report; do not quarantine, restore or delete anything")
+	flags.Parse(args)
+
+	return withDatabase(func(ctx context.Context, db *pgxpool.Pool, config *Config) error {
+		files, err := filestore.New(config.Storage)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Scanning %s\n", files)
+		report, err := services.NewStorageScanner(repository.NewPostgresStore(db), files, config.StorageOrphanGrace).Scan(ctx, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, orphan := range report.Orphans {
+			fmt.Printf("  orphan %s (%d bytes, %s)\n", orphan.Key, orphan.Size, orphan.ModTime.Format(time.RFC3339))
+		}
+		for _, missing := range report.Missing {
+			fmt.Printf("  missing %s (escort %d)\n", missing.FotoPengantar, missing.EscortID)
+		}
+		for _, key := range report.Restored {
+			fmt.Printf("  restored %s\n", key)
+		}
+		for _, key := range report.Purged {
+			fmt.Printf("  purged %s\n", key)
+		}
+		fmt.Printf("%d file(s) scanned, %d photo(s) referenced, %d orphan(s) quarantined, %d missing, %d restored, %d purged, %d waiting in quarantine\n",
+			report.FilesScanned, report.PhotosReferenced, len(report.Orphans), len(report.Missing),
+			len(report.Restored), len(report.Purged), len(report.Quarantined))
+		if *dryRun {
+			fmt.Println("Dry run: nothing was changed")
+		}
+		return nil
+	})
+}
+
+// runCheck validates configuration and database connectivity, failing when anything is wrong
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Parse(args)
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	problems := validateConfig(config)
+	for _, problem := range problems {
+		fmt.Printf("[FAIL] config: %s\n", problem)
+	}
+	if len(problems) == 0 {
+		fmt.Println("[ OK ] configuration")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, err := openDatabase(config)
+	if err != nil {
+		fmt.Printf("[FAIL] database: %v\n", err)
+		return errors.New("check failed")
+	}
+	defer db.Close()
+	fmt.Printf("[ OK ] database %s@%s:%s/%s\n", config.DBUsername, config.DBHost, config.DBPort, config.DBDatabase)
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		fmt.Printf("[FAIL] migrations: %v\n", err)
+		return errors.New("check failed")
+	}
+	if len(pending) > 0 {
+		fmt.Printf("[WARN] migrations: %d pending (run 'goserver migrate up')\n", len(pending))
+	} else {
+		fmt.Println("[ OK ] migrations up to date")
+	}
+
+	storageOK := false
+	files, err := filestore.New(config.Storage)
+	if err == nil {
+		err = files.Ping(ctx)
+	}
+	if err != nil {
+		fmt.Printf("[FAIL] storage: %v\n", err)
+	} else {
+		storageOK = true
+		fmt.Printf("[ OK ] storage %s\n", files)
+	}
+
+	if len(problems) > 0 || !storageOK {
+		return errors.New("check failed")
+	}
+	return nil
+}
+
+// validateConfig returns a description of every invalid configuration value
+func validateConfig(config *Config) []string {
+	var problems []string
+
+	if config.DBHost == "" {
+		problems = append(problems, "DB_HOST is empty")
+	}
+	if _, err := strconv.Atoi(config.DBPort); err != nil {
+		problems = append(problems, fmt.Sprintf("DB_PORT %q is not a number", config.DBPort))
+	}
+	if config.DBDatabase == "" {
+		problems = append(problems, "DB_DATABASE is empty")
+	}
+	if config.DBUsername == "" {
+		problems = append(problems, "DB_USERNAME is empty")
+	}
+	if port := getEnv("PORT", "8080"); !isPort(port) {
+		problems = append(problems, fmt.Sprintf("PORT %q is not a valid port", port))
+	}
+	if u, err := url.Parse(config.AppURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("APP_URL %q is not an absolute URL", config.AppURL))
+	}
+	switch config.Storage.Driver {
+	case filestore.DriverLocal:
+		if config.Storage.LocalRoot == "" {
+			problems = append(problems, "STORAGE_LOCAL_ROOT is empty")
+		}
+	case filestore.DriverS3:
+		required := []struct{ name, value string }{
+			{"S3_ENDPOINT", config.Storage.S3Endpoint},
+			{"S3_BUCKET", config.Storage.S3Bucket},
+			{"S3_ACCESS_KEY", config.Storage.S3AccessKey},
+			{"S3_SECRET_KEY", config.Storage.S3SecretKey},
+		}
+		for _, setting := range required {
+			if setting.value == "" {
+				problems = append(problems, setting.name+" is empty (required by STORAGE_DRIVER=s3)")
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("STORAGE_DRIVER %q is not local or s3", config.Storage.Driver))
+	}
+
+	return problems
+}
+
+// withDatabase loads configuration, connects and runs fn with a background context
+func withDatabase(fn func(ctx context.Context, db *pgxpool.Pool, config *Config) error) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return fn(context.Background(), db, config)
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
+}
+
+func randomPassword() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
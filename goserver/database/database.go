@@ -3,7 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -37,6 +37,7 @@ func NewConnection(config DatabaseConfig) (*pgxpool.Pool, error) {
 	// Configure connection pool
 	dbConfig.MaxConns = 30
 	dbConfig.MinConns = 5
+	dbConfig.ConnConfig.Tracer = queryTracer{}
 
 	// Create connection pool
 	dbpool, err := pgxpool.NewWithConfig(context.Background(), dbConfig)
@@ -50,61 +51,29 @@ func NewConnection(config DatabaseConfig) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL database")
+	slog.Info("Connected to PostgreSQL", "host", dbConfig.ConnConfig.Host, "database", dbConfig.ConnConfig.Database)
 	return dbpool, nil
 }
 
-// RunMigrations executes database migrations
+// RunMigrations applies pending migrations embedded from database/migrations. Schema drift
+// is not checked here: replaying the migrations needs CREATE on the database, so it is left
+// to `goserver migrate status`.
 func RunMigrations(db *pgxpool.Pool) error {
-	// This is a simple migration runner
-	// In production, consider using a proper migration tool like golang-migrate
+	ctx := context.Background()
 
-	migrations := []string{
-		// Users table migration
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			email_verified_at TIMESTAMP NULL,
-			password VARCHAR(255) NOT NULL,
-			remember_token VARCHAR(100) NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
-
-		// Escorts table migration (for Pendataan IGD)
-		`CREATE TABLE IF NOT EXISTS escorts (
-			id BIGSERIAL PRIMARY KEY,
-			status VARCHAR(20) CHECK (status IN ('pending', 'verified', 'rejected')) DEFAULT 'pending',
-			kategori_pengantar VARCHAR(20) CHECK (kategori_pengantar IN ('Polisi', 'Ambulans', 'Perorangan')),
-			nama_pengantar VARCHAR(255) NOT NULL,
-			jenis_kelamin VARCHAR(20) CHECK (jenis_kelamin IN ('Laki-laki', 'Perempuan')),
-			nomor_hp VARCHAR(20) NOT NULL,
-			plat_nomor VARCHAR(20) NOT NULL,
-			nama_pasien VARCHAR(255) NOT NULL,
-			foto_pengantar VARCHAR(255) NULL,
-			submission_id VARCHAR(255) NULL,
-			submitted_from_ip VARCHAR(255) NULL,
-			api_submission BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-
-		// Escorts table indexes
-		`CREATE INDEX IF NOT EXISTS idx_escorts_status ON escorts(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_escorts_kategori ON escorts(kategori_pengantar)`,
-		`CREATE INDEX IF NOT EXISTS idx_escorts_created_at ON escorts(created_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_escorts_submission_id ON escorts(submission_id)`,
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
 	}
 
-	for i, migration := range migrations {
-		_, err := db.Exec(context.Background(), migration)
-		if err != nil {
-			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
-		}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		slog.InfoContext(ctx, "Applied migration", "version", migration.Version, "name", migration.Name)
 	}
 
-	log.Println("Database migrations completed successfully")
+	slog.InfoContext(ctx, "Database migrations completed", "applied", len(applied))
 	return nil
 }
@@ -0,0 +1,496 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// undefinedTable is the PostgreSQL error code of a query on a missing table
+const undefinedTable = "42P01"
+
+// migrationLockID is the pg_advisory_lock key that serialises migrations across instances
+const migrationLockID int64 = 0x676f6d6967726174 // "gomigrat"
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered pair of up/down SQL scripts
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Version          int64      `json:"version"`
+	Name             string     `json:"name"`
+	Applied          bool       `json:"applied"`
+	AppliedAt        *time.Time `json:"applied_at"`
+	ChecksumMismatch bool       `json:"checksum_mismatch"`
+}
+
+// SchemaDrift describes a difference between the live schema and the schema the migrations produce
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Issue    string `json:"issue"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (d SchemaDrift) String() string {
+	target := d.Table
+	if d.Column != "" {
+		target += "." + d.Column
+	}
+	if d.Expected == "" && d.Actual == "" {
+		return fmt.Sprintf("%s: %s", target, d.Issue)
+	}
+	return fmt.Sprintf("%s: %s (expected %s, found %s)", target, d.Issue, d.Expected, d.Actual)
+}
+
+// Migrator applies versioned migrations and records them in schema_migrations
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the migrations embedded in the binary
+func NewMigrator(db *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LoadMigrations reads NNN_name.up.sql / NNN_name.down.sql pairs from dir, ordered by version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrations returns the known migrations ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration and returns the ones that ran
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		records, err := m.appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if record, ok := records[migration.Version]; ok {
+				if record.checksum != migration.Checksum {
+					return fmt.Errorf("migration %d_%s was modified after it was applied", migration.Version, migration.Name)
+				}
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, at most steps of them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		records, err := m.appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := records[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+			}
+
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	records, err := m.appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := records[migration.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = record.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet. Unlike Status it only
+// reads: a database without schema_migrations has every migration pending.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied := map[int64]bool{}
+	rows, err := m.db.Query(ctx, "SELECT version FROM schema_migrations")
+	if err == nil {
+		for rows.Next() {
+			var version int64
+			if err = rows.Scan(&version); err != nil {
+				break
+			}
+			applied[version] = true
+		}
+		rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	var pgErr *pgconn.PgError
+	if err != nil && !(errors.As(err, &pgErr) && pgErr.Code == undefinedTable) {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// CheckDrift compares the live public schema with the schema the migrations produce. The
+// reference schema is built by replaying every up migration in a scratch schema inside a
+// transaction that is always rolled back, so tables created by Laravel's own migrations
+// (bigint ids, nullable timestamps, missing columns) show up as drift. Creating the scratch
+// schema needs CREATE on the database, so only `migrate status` runs this check.
+func (m *Migrator) CheckDrift(ctx context.Context) ([]SchemaDrift, error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	scratch := fmt.Sprintf("goserver_drift_%d", time.Now().UnixNano())
+	if _, err := tx.Exec(ctx, "CREATE SCHEMA "+scratch); err != nil {
+		return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SET LOCAL search_path TO "+scratch); err != nil {
+		return nil, fmt.Errorf("failed to switch to scratch schema: %w", err)
+	}
+	for _, migration := range m.migrations {
+		if _, err := tx.Exec(ctx, migration.Up); err != nil {
+			return nil, fmt.Errorf("failed to replay migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	expected, err := schemaColumns(ctx, tx, scratch)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := schemaColumns(ctx, tx, "public")
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []SchemaDrift
+	for _, table := range sortedKeys(expected) {
+		actualColumns, ok := actual[table]
+		if !ok {
+			drift = append(drift, SchemaDrift{Table: table, Issue: "missing table"})
+			continue
+		}
+
+		for _, name := range sortedKeys(expected[table]) {
+			want := expected[table][name]
+			got, ok := actualColumns[name]
+			if !ok {
+				drift = append(drift, SchemaDrift{Table: table, Column: name, Issue: "missing column", Expected: want.dataType})
+				continue
+			}
+			if got.dataType != want.dataType {
+				drift = append(drift, SchemaDrift{Table: table, Column: name, Issue: "type differs", Expected: want.dataType, Actual: got.dataType})
+			}
+			if got.nullable != want.nullable {
+				drift = append(drift, SchemaDrift{Table: table, Column: name, Issue: "nullability differs", Expected: nullability(want.nullable), Actual: nullability(got.nullable)})
+			}
+		}
+
+		for _, name := range sortedKeys(actualColumns) {
+			if _, ok := expected[table][name]; !ok {
+				drift = append(drift, SchemaDrift{Table: table, Column: name, Issue: "unexpected column", Actual: actualColumns[name].dataType})
+			}
+		}
+	}
+
+	return drift, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// apply runs an up script and records it in the same transaction
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	start := time.Now()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Up); err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, execution_ms, applied_at) VALUES ($1, $2, $3, $4, NOW())",
+		migration.Version, migration.Name, migration.Checksum, time.Since(start).Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// revert runs a down script and removes its record in the same transaction
+func (m *Migrator) revert(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Down); err != nil {
+		return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+		return fmt.Errorf("failed to unrecord migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+type migrationRecord struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// appliedMigrations loads schema_migrations keyed by version
+func (m *Migrator) appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]migrationRecord, error) {
+	rows, err := conn.Query(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	records := map[int64]migrationRecord{}
+	for rows.Next() {
+		var version int64
+		var record migrationRecord
+		if err := rows.Scan(&version, &record.checksum, &record.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		records[version] = record
+	}
+	return records, rows.Err()
+}
+
+// ensureMigrationsTable creates the schema_migrations tracking table
+func ensureMigrationsTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		execution_ms BIGINT NOT NULL DEFAULT 0,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+type columnInfo struct {
+	dataType string
+	nullable bool
+}
+
+// schemaColumns returns the columns of every table in schema, keyed by table and column name
+func schemaColumns(ctx context.Context, tx pgx.Tx, schema string) (map[string]map[string]columnInfo, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT c.table_name, c.column_name, c.data_type, c.character_maximum_length, c.is_nullable = 'YES'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = $1 AND t.table_type = 'BASE TABLE'
+	`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect schema %s: %w", schema, err)
+	}
+	defer rows.Close()
+
+	tables := map[string]map[string]columnInfo{}
+	for rows.Next() {
+		var table, column, dataType string
+		var maxLength *int
+		var info columnInfo
+		if err := rows.Scan(&table, &column, &dataType, &maxLength, &info.nullable); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		info.dataType = dataType
+		if maxLength != nil {
+			info.dataType = fmt.Sprintf("%s(%d)", dataType, *maxLength)
+		}
+		if tables[table] == nil {
+			tables[table] = map[string]columnInfo{}
+		}
+		tables[table][column] = info
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema %s: %w", schema, err)
+	}
+
+	return tables, nil
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,2 @@
+-- Intentionally a no-op. users belongs to Laravel and the up script only creates it when
+-- it is missing, so dropping it here would delete accounts the Go server never created.
@@ -0,0 +1,2 @@
+-- Intentionally a no-op. escorts belongs to Laravel and the up script only creates it when
+-- it is missing, so dropping it here would delete patient records the Go server never created.
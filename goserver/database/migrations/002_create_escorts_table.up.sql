@@ -21,14 +21,3 @@ CREATE INDEX IF NOT EXISTS idx_escorts_status ON escorts(status);
 CREATE INDEX IF NOT EXISTS idx_escorts_kategori ON escorts(kategori_pengantar);
 CREATE INDEX IF NOT EXISTS idx_escorts_created_at ON escorts(created_at);
 CREATE INDEX IF NOT EXISTS idx_escorts_submission_id ON escorts(submission_id);
-
--- Insert some sample data for testing
-INSERT INTO escorts (
-    status, kategori_pengantar, nama_pengantar, jenis_kelamin, 
-    nomor_hp, plat_nomor, nama_pasien, api_submission
-) VALUES 
-    ('pending', 'Polisi', 'Budi Santoso', 'Laki-laki', '081234567890', 'B1234ABC', 'Siti Aminah', true),
-    ('verified', 'Ambulans', 'Dr. Sarah', 'Perempuan', '081987654321', 'B5678DEF', 'Ahmad Rahman', false),
-    ('pending', 'Perorangan', 'Andi Wijaya', 'Laki-laki', '082345678901', 'B9012GHI', 'Maria Sari', true),
-    ('rejected', 'Polisi', 'Joko Susilo', 'Laki-laki', '083456789012', 'B3456JKL', 'Dewi Lestari', false)
-ON CONFLICT DO NOTHING;
\ No newline at end of file
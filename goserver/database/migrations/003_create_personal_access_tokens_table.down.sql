@@ -0,0 +1,2 @@
+-- Intentionally a no-op. personal_access_tokens belongs to Laravel Sanctum and the up script
+-- only creates it when it is missing, so dropping it here would sign out every Laravel user.
@@ -0,0 +1,6 @@
+-- Add role column used for escort access control
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'igd_staff'
+    CHECK (role IN ('admin', 'supervisor', 'igd_staff', 'security', 'auditor'));
+
+-- Promote the default Laravel admin account created by `php artisan admin:create`
+UPDATE users SET role = 'admin' WHERE email = 'admin@igd.com';
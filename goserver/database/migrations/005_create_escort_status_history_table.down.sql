@@ -0,0 +1 @@
+DROP TABLE IF EXISTS escort_status_history;
@@ -0,0 +1,14 @@
+-- Create escort status history table (kept after an escort is deleted, so no foreign key)
+CREATE TABLE IF NOT EXISTS escort_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    escort_id BIGINT NOT NULL,
+    old_status VARCHAR(20) NULL,
+    new_status VARCHAR(20) NOT NULL,
+    changed_by BIGINT NULL,
+    note TEXT NULL,
+    client_ip VARCHAR(45) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Timeline lookups are always per escort in chronological order
+CREATE INDEX IF NOT EXISTS idx_escort_status_history_escort_id ON escort_status_history(escort_id, created_at);
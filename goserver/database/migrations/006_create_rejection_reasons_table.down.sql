@@ -0,0 +1,3 @@
+ALTER TABLE escort_status_history DROP COLUMN IF EXISTS reason_code;
+ALTER TABLE escorts DROP COLUMN IF EXISTS rejection_reason;
+DROP TABLE IF EXISTS rejection_reasons;
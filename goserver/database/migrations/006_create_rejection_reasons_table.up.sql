@@ -0,0 +1,19 @@
+-- Create managed list of escort rejection reasons
+CREATE TABLE IF NOT EXISTS rejection_reasons (
+    code VARCHAR(50) PRIMARY KEY,
+    label VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Default reasons used by IGD staff
+INSERT INTO rejection_reasons (code, label) VALUES
+    ('data_tidak_valid', 'Data tidak valid'),
+    ('duplikat', 'Duplikat'),
+    ('foto_tidak_jelas', 'Foto tidak jelas')
+ON CONFLICT (code) DO NOTHING;
+
+-- Rejected escorts and their history entries carry the reason code
+ALTER TABLE escorts ADD COLUMN IF NOT EXISTS rejection_reason VARCHAR(50) NULL;
+ALTER TABLE escort_status_history ADD COLUMN IF NOT EXISTS reason_code VARCHAR(50) NULL;
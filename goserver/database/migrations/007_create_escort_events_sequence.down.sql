@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS escort_events_id_seq;
@@ -0,0 +1,2 @@
+-- Event IDs of the real-time escort feed (published with pg_notify on escort_events)
+CREATE SEQUENCE IF NOT EXISTS escort_events_id_seq;
@@ -0,0 +1,10 @@
+-- Reports of the photo storage consistency scanner; the admin endpoint shows the latest one
+CREATE TABLE IF NOT EXISTS storage_scans (
+    id BIGSERIAL PRIMARY KEY,
+    storage VARCHAR(255) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    report JSONB NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
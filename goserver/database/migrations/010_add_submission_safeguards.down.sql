@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_escorts_plat_nomor_normalized;
+ALTER TABLE escorts DROP COLUMN IF EXISTS duplicate_of;
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,20 @@
+-- Responses of POST requests sent with an Idempotency-Key, replayed when a client retries
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER NULL, -- NULL while the first request is still being handled
+    content_type VARCHAR(255) NULL,
+    response_body BYTEA NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- Submissions flagged as duplicates of an earlier escort
+ALTER TABLE escorts ADD COLUMN IF NOT EXISTS duplicate_of BIGINT NULL REFERENCES escorts(id) ON DELETE SET NULL;
+
+-- Duplicate detection matches on the normalised plate number within a time window
+CREATE INDEX IF NOT EXISTS idx_escorts_plat_nomor_normalized ON escorts ((REPLACE(UPPER(plat_nomor), ' ', '')), created_at);
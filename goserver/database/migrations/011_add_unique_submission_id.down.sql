@@ -0,0 +1,3 @@
+-- Suffixed duplicate submission IDs are left as they are
+CREATE INDEX IF NOT EXISTS idx_escorts_submission_id ON escorts(submission_id);
+DROP INDEX IF EXISTS idx_escorts_submission_id_unique;
@@ -0,0 +1,9 @@
+-- Older submission IDs (ESC_<unix seconds>_<PLATE>) could collide. Keep the first row's ID
+-- and suffix the others with their row ID so the column can be unique.
+UPDATE escorts e
+SET submission_id = e.submission_id || '-' || e.id
+WHERE e.submission_id IS NOT NULL
+  AND EXISTS (SELECT 1 FROM escorts o WHERE o.submission_id = e.submission_id AND o.id < e.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_escorts_submission_id_unique ON escorts(submission_id);
+DROP INDEX IF EXISTS idx_escorts_submission_id;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_escorts_location_code;
+ALTER TABLE escorts DROP COLUMN IF EXISTS location_code;
+DROP TABLE IF EXISTS kiosk_locations;
@@ -0,0 +1,18 @@
+-- Registered places where the escort form QR code is posted
+CREATE TABLE IF NOT EXISTS kiosk_locations (
+    code VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO kiosk_locations (code, name) VALUES
+    ('ambulance_bay', 'Area Ambulans'),
+    ('main_entrance', 'Pintu Utama'),
+    ('police_post', 'Pos Polisi')
+ON CONFLICT (code) DO NOTHING;
+
+-- Location whose QR code a submission was made from (NULL for the plain form)
+ALTER TABLE escorts ADD COLUMN IF NOT EXISTS location_code VARCHAR(50) NULL REFERENCES kiosk_locations(code);
+CREATE INDEX IF NOT EXISTS idx_escorts_location_code ON escorts(location_code, created_at);
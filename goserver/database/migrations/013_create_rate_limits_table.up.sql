@@ -0,0 +1,12 @@
+-- Token buckets shared by every server instance. A bucket can be deleted once it has refilled
+-- (expires_at), so the table only holds recently active clients; losing it on a crash merely
+-- resets the limits, hence UNLOGGED.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_expires_at ON rate_limits(expires_at);
@@ -1,3 +1,10 @@
+-- Insert sample users for testing (password: "password")
+INSERT INTO users (name, email, password) VALUES 
+    ('John Doe', 'john@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi'),
+    ('Jane Smith', 'jane@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi'),
+    ('Admin User', 'admin@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi')
+ON CONFLICT (email) DO NOTHING;
+
 -- Insert sample data for testing the Pendataan IGD API
 INSERT INTO escorts (
     status, kategori_pengantar, nama_pengantar, jenis_kelamin, 
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SampleData is the bundled sample_data.sql used by `goserver seed` when no file is given
+//
+//go:embed sample_data.sql
+var SampleData string
+
+// Seed executes a SQL fixture script in a single transaction
+func Seed(ctx context.Context, db *pgxpool.Pool, script string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("failed to run seed script: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"goserver/logging"
+	"goserver/telemetry"
+
+	"github.com/jackc/pgx/v5"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer times every query: the time is added to the request's logging.QueryStats, the
+// query gets a trace span and it is logged at debug level. Only the SQL is recorded, never
+// its arguments.
+type queryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql  string
+	at   time.Time
+	span trace.Span
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	sql := strings.Join(strings.Fields(data.SQL), " ")
+	operation, _, _ := strings.Cut(sql, " ")
+	operation = strings.ToUpper(operation)
+
+	ctx, span := telemetry.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(sql),
+		),
+	)
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: sql, at: time.Now(), span: span})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	telemetry.End(start.span, &data.Err)
+	if stats := logging.QueryStatsFrom(ctx); stats != nil {
+		stats.Add(elapsed)
+	}
+
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("sql", start.sql),
+		slog.Float64("duration_ms", logging.Milliseconds(elapsed)),
+		slog.Int64("rows", data.CommandTag.RowsAffected()),
+	}
+	if data.Err != nil {
+		attrs = append(attrs, slog.String("error", data.Err.Error()))
+	}
+	slog.LogAttrs(ctx, slog.LevelDebug, "Database query", attrs...)
+}
@@ -0,0 +1,121 @@
+// Package filestore keeps uploaded files, such as escort photos, in a pluggable backend:
+// a directory on local disk or a bucket on an S3-compatible service (AWS S3, MinIO).
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// Storage drivers accepted by New
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// ErrNotFound is returned when no file is stored under the requested key
+var ErrNotFound = errors.New("file not found")
+
+// Info describes a stored file
+type Info struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Storage stores files under slash-separated keys such as "escort_1.jpg"
+type Storage interface {
+	// Put stores content under key, replacing any existing file. size is the content
+	// length, or -1 when it is not known in advance.
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Open opens a file for reading. The file can seek, so it can be served with
+	// http.ServeContent; the caller must close it.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *Info, error)
+	Stat(ctx context.Context, key string) (*Info, error)
+	// Delete removes a file; deleting a file that does not exist is not an error
+	Delete(ctx context.Context, key string) error
+	// Walk calls fn for every stored file, in no particular order
+	Walk(ctx context.Context, fn func(Info) error) error
+	// Ping checks that the backend is reachable and usable
+	Ping(ctx context.Context) error
+	// String describes the backend for logs, e.g. "local:storage/uploads"
+	String() string
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Driver string
+
+	// LocalRoot is the directory the local driver stores files in
+	LocalRoot string
+
+	S3Endpoint  string // host[:port], without scheme
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	// S3Prefix is prepended to every key, e.g. "uploads/"
+	S3Prefix string
+}
+
+// New creates the backend selected by config.Driver
+func New(config Config) (Storage, error) {
+	switch config.Driver {
+	case DriverLocal:
+		return NewLocal(config.LocalRoot), nil
+	case DriverS3:
+		return NewS3(config)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q (expected %s or %s)", config.Driver, DriverLocal, DriverS3)
+	}
+}
+
+// Copy copies the file stored under key from one backend to another
+func Copy(ctx context.Context, from, to Storage, key string) error {
+	file, info, err := from.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return to.Put(ctx, key, file, info.Size, info.ContentType)
+}
+
+// Move moves the file stored under from to the key to within one backend
+func Move(ctx context.Context, store Storage, from, to string) error {
+	file, info, err := store.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	err = store.Put(ctx, to, file, info.Size, info.ContentType)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, from)
+}
+
+// validKey rejects keys that are empty, absolute or escape the storage root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
+
+// contentTypeOf guesses the content type of a key from its extension
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,169 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, NewLocal(t.TempDir()))
+}
+
+// TestS3Storage runs against a local MinIO, for example
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	FILESTORE_TEST_S3_ENDPOINT=localhost:9000 go test ./filestore
+//
+// The bucket is created when it does not exist.
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("FILESTORE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("FILESTORE_TEST_S3_ENDPOINT not set")
+	}
+	config := Config{
+		Driver:      DriverS3,
+		S3Endpoint:  endpoint,
+		S3Region:    "us-east-1",
+		S3Bucket:    envOr("FILESTORE_TEST_S3_BUCKET", "goserver-test"),
+		S3AccessKey: envOr("FILESTORE_TEST_S3_ACCESS_KEY", "minioadmin"),
+		S3SecretKey: envOr("FILESTORE_TEST_S3_SECRET_KEY", "minioadmin"),
+		S3Prefix:    "filestore-test-" + strings.ReplaceAll(t.Name(), "/", "-"),
+	}
+
+	store, err := NewS3(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if exists, err := store.client.BucketExists(ctx, config.S3Bucket); err != nil {
+		t.Fatal(err)
+	} else if !exists {
+		if err := store.client.MakeBucket(ctx, config.S3Bucket, minio.MakeBucketOptions{Region: config.S3Region}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		store.Walk(context.Background(), func(info Info) error {
+			return store.Delete(context.Background(), info.Key)
+		})
+	})
+
+	testStorage(t, store)
+}
+
+// testStorage checks the behaviour every backend must share
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	if _, err := store.Stat(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat missing file error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := store.Open(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open missing file error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "missing.jpg"); err != nil {
+		t.Fatalf("Delete missing file: %v", err)
+	}
+
+	for _, key := range []string{"", "/abs.jpg", "../escape.jpg", "a/../b.jpg"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Fatalf("Put accepted invalid key %q", key)
+		}
+	}
+
+	content := []byte("0123456789")
+	if err := store.Put(ctx, "escort_1.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "variants/escort_1.png", strings.NewReader("png"), -1, "image/png"); err != nil {
+		t.Fatalf("Put with unknown size: %v", err)
+	}
+
+	info, err := store.Stat(ctx, "escort_1.jpg")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Key != "escort_1.jpg" || info.Size != 10 || info.ContentType != "image/jpeg" || info.ModTime.IsZero() {
+		t.Fatalf("Stat = %+v", info)
+	}
+
+	file, info, err := store.Open(ctx, "escort_1.jpg")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := file.Seek(4, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(rest) != "456789" || info.Size != 10 {
+		t.Fatalf("read after seek = %q, %v (size %d)", rest, err, info.Size)
+	}
+
+	var keys []string
+	err = store.Walk(ctx, func(info Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	slices.Sort(keys)
+	if want := []string{"escort_1.jpg", "variants/escort_1.png"}; !slices.Equal(keys, want) {
+		t.Fatalf("Walk keys = %v, want %v", keys, want)
+	}
+
+	// Put replaces
+	if err := store.Put(ctx, "escort_1.jpg", strings.NewReader("new"), 3, "image/jpeg"); err != nil {
+		t.Fatalf("Put replace: %v", err)
+	}
+	if info, err := store.Stat(ctx, "escort_1.jpg"); err != nil || info.Size != 3 {
+		t.Fatalf("Stat after replace = %+v, %v", info, err)
+	}
+
+	if err := store.Delete(ctx, "escort_1.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, "escort_1.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat deleted file error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalWalkSkipsUnfinishedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocal(dir + "/uploads")
+
+	// Walking a root that was never written to is not an error
+	if err := store.Walk(context.Background(), func(Info) error { return errors.New("unexpected file") }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(dir+"/uploads", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/uploads/"+tempPrefix+"123", []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Walk(context.Background(), func(info Info) error { return errors.New("walked " + info.Key) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
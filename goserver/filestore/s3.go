@@ -0,0 +1,159 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 stores files in a bucket of an S3-compatible service such as AWS S3 or MinIO
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 creates a backend from the S3 fields of config. No request is made until the
+// backend is used; call Ping to check the endpoint, credentials and bucket.
+func NewS3(config Config) (*S3, error) {
+	client, err := minio.New(config.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.S3AccessKey, config.S3SecretKey, ""),
+		Secure: config.S3UseSSL,
+		Region: config.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 configuration: %w", err)
+	}
+
+	prefix := strings.Trim(config.S3Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3{client: client, bucket: config.S3Bucket, prefix: prefix}, nil
+}
+
+func (s *S3) object(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return s.prefix + key, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	object, err := s.object(key)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = contentTypeOf(key)
+	}
+
+	_, err = s.client.PutObject(ctx, s.bucket, object, content, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	return nil
+}
+
+// Open reads the object lazily; seeking issues ranged GET requests
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, *Info, error) {
+	object, err := s.object(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.client.GetObject(ctx, s.bucket, object, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s3Error(err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, s3Error(err)
+	}
+
+	return file, s.info(stat), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Info, error) {
+	object, err := s.object(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := s.client.StatObject(ctx, s.bucket, object, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return s.info(stat), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	object, err := s.object(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, object, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (s *S3) Walk(ctx context.Context, fn func(Info) error) error {
+	// Cancelling stops the listing when fn fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for stat := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if stat.Err != nil {
+			return fmt.Errorf("failed to list files: %w", stat.Err)
+		}
+		if err := fn(*s.info(stat)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Ping checks that the bucket exists and the credentials can see it
+func (s *S3) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to reach S3 endpoint: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", s.bucket)
+	}
+	return nil
+}
+
+func (s *S3) String() string {
+	return DriverS3 + ":" + s.client.EndpointURL().Host + "/" + s.bucket + "/" + s.prefix
+}
+
+func (s *S3) info(stat minio.ObjectInfo) *Info {
+	info := &Info{
+		Key:         strings.TrimPrefix(stat.Key, s.prefix),
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+		ContentType: stat.ContentType,
+	}
+	if info.ContentType == "" {
+		info.ContentType = contentTypeOf(info.Key)
+	}
+	return info
+}
+
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to open file: %w", err)
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"strconv"
-	"strings"
 
+	"goserver/middleware"
 	"goserver/models"
 	"goserver/services"
 
@@ -12,18 +16,32 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+// imageFormField is the multipart field carrying the photo, named after the escorts column
+const imageFormField = "foto_pengantar"
+
+// maxImageUploadRequest bounds a multipart photo upload: the 2MB photo plus the envelope
+const maxImageUploadRequest = 3 * 1024 * 1024
+
 type EscortHandler struct {
 	service   *services.EscortService
+	receipts  *services.ReceiptService
 	validator *validator.Validate
 }
 
-func NewEscortHandler(service *services.EscortService) *EscortHandler {
+func NewEscortHandler(service *services.EscortService, receipts *services.ReceiptService) *EscortHandler {
 	return &EscortHandler{
 		service:   service,
+		receipts:  receipts,
 		validator: validator.New(),
 	}
 }
 
+// createdEscort is the create response: the escort plus the submitter's receipt
+type createdEscort struct {
+	*models.Escort
+	Receipt *models.ReceiptLink `json:"receipt,omitempty"`
+}
+
 // CreateEscort handles POST /api/escort
 func (h *EscortHandler) CreateEscort(c *gin.Context) {
 	var req models.CreateEscortRequest
@@ -49,6 +67,36 @@ func (h *EscortHandler) CreateEscort(c *gin.Context) {
 	clientIP := c.ClientIP()
 	escort, err := h.service.CreateEscort(c.Request.Context(), req, clientIP)
 	if err != nil {
+		if respondImageError(c, err) {
+			return
+		}
+		var duplicate *services.DuplicateEscortError
+		if errors.As(err, &duplicate) {
+			// The form is public: only staff who may view escorts learn which submission it
+			// duplicates
+			resp := models.APIResponse{
+				Status:  "error",
+				Message: "This escort has already been submitted",
+			}
+			if middleware.HasPermission(c, models.PermissionEscortView) {
+				resp.Errors = gin.H{
+					"duplicate_of":  duplicate.Original.ID,
+					"submission_id": duplicate.Original.SubmissionID,
+					"submitted_at":  duplicate.Original.CreatedAt,
+				}
+			}
+			c.JSON(http.StatusConflict, resp)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidKioskToken) || errors.Is(err, services.ErrKioskTokenExpired) ||
+			errors.Is(err, services.ErrKioskTokenRequired) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Status:  "error",
+				Message: "Please scan the QR code at the entrance again",
+				Errors:  map[string]string{"kiosk_token": err.Error()},
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Status:  "error",
 			Message: "Failed to create escort",
@@ -57,10 +105,16 @@ func (h *EscortHandler) CreateEscort(c *gin.Context) {
 		return
 	}
 
+	// The escort is stored either way; a missing receipt only costs the submitter the link
+	receipt, err := h.receipts.Issue(escort)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to issue receipt", "escort_id", escort.ID, "error", err)
+	}
+
 	c.JSON(http.StatusCreated, models.APIResponse{
 		Status:  "success",
 		Message: "Escort created successfully",
-		Data:    escort,
+		Data:    createdEscort{Escort: escort, Receipt: receipt},
 	})
 }
 
@@ -109,7 +163,33 @@ func (h *EscortHandler) GetEscort(c *gin.Context) {
 
 	escort, err := h.service.GetEscortByID(c.Request.Context(), id)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
+		if errors.Is(err, services.ErrEscortNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Escort not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve escort",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Escort retrieved successfully",
+		Data:    escort,
+	})
+}
+
+// GetEscortBySubmission handles GET /api/escort/by-submission/:submission_id
+func (h *EscortHandler) GetEscortBySubmission(c *gin.Context) {
+	escort, err := h.service.GetEscortBySubmissionID(c.Request.Context(), c.Param("submission_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrEscortNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Escort not found",
@@ -165,13 +245,16 @@ func (h *EscortHandler) UpdateEscort(c *gin.Context) {
 
 	escort, err := h.service.UpdateEscort(c.Request.Context(), id, req)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
+		if errors.Is(err, services.ErrEscortNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Escort not found",
 			})
 			return
 		}
+		if respondImageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Status:  "error",
 			Message: "Failed to update escort",
@@ -219,9 +302,42 @@ func (h *EscortHandler) UpdateEscortStatus(c *gin.Context) {
 		return
 	}
 
-	escort, err := h.service.UpdateEscortStatus(c.Request.Context(), id, req.Status)
+	change := models.StatusChange{
+		Status:     req.Status,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+		ClientIP:   c.ClientIP(),
+	}
+	if user, ok := middleware.CurrentUser(c); ok {
+		change.ChangedBy = &user.ID
+	}
+
+	escort, err := h.service.UpdateEscortStatus(c.Request.Context(), id, change)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
+		var transitionErr *services.InvalidTransitionError
+		if errors.As(err, &transitionErr) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Status:  "error",
+				Message: fmt.Sprintf("Escort status cannot change from %s to %s", transitionErr.From, transitionErr.To),
+				Errors: gin.H{
+					"current_status":      transitionErr.From,
+					"requested_status":    transitionErr.To,
+					"allowed_transitions": transitionErr.Allowed,
+				},
+			})
+			return
+		}
+		if errors.Is(err, services.ErrRejectionReasonRequired) || errors.Is(err, services.ErrUnknownRejectionReason) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Validation failed",
+				Errors: map[string]string{
+					"ReasonCode": err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, services.ErrEscortNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Escort not found",
@@ -243,6 +359,42 @@ func (h *EscortHandler) UpdateEscortStatus(c *gin.Context) {
 	})
 }
 
+// GetEscortHistory handles GET /api/escort/:id/history
+func (h *EscortHandler) GetEscortHistory(c *gin.Context) {
+	id, err := h.parseIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid escort ID",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	history, err := h.service.GetStatusHistory(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrEscortNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Escort not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve escort history",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Escort history retrieved successfully",
+		Data:    history,
+	})
+}
+
 // DeleteEscort handles DELETE /api/escort/:id
 func (h *EscortHandler) DeleteEscort(c *gin.Context) {
 	id, err := h.parseIDParam(c)
@@ -257,7 +409,7 @@ func (h *EscortHandler) DeleteEscort(c *gin.Context) {
 
 	err = h.service.DeleteEscort(c.Request.Context(), id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrEscortNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Escort not found",
@@ -311,7 +463,7 @@ func (h *EscortHandler) GetImageBase64(c *gin.Context) {
 
 	base64Data, err := h.service.GetImageAsBase64(c.Request.Context(), id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "no image") {
+		if errors.Is(err, services.ErrEscortNotFound) || errors.Is(err, services.ErrImageNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Image not found",
@@ -375,13 +527,16 @@ func (h *EscortHandler) UploadImageBase64(c *gin.Context) {
 
 	escort, err := h.service.UpdateEscort(c.Request.Context(), id, updateReq)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrEscortNotFound) {
 			c.JSON(http.StatusNotFound, models.APIResponse{
 				Status:  "error",
 				Message: "Escort not found",
 			})
 			return
 		}
+		if respondImageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Status:  "error",
 			Message: "Failed to upload image",
@@ -397,6 +552,159 @@ func (h *EscortHandler) UploadImageBase64(c *gin.Context) {
 	})
 }
 
+// UploadImage handles POST /api/escort/:id/image (multipart/form-data, photo in the
+// foto_pengantar field). The file part is streamed to storage without buffering the request.
+func (h *EscortHandler) UploadImage(c *gin.Context) {
+	id, err := h.parseIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid escort ID",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	// Leave room for the multipart envelope and small form fields around the photo
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadRequest)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  "request must be multipart/form-data",
+		})
+		return
+	}
+
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil || (part.FormName() == imageFormField && part.FileName() != "") {
+			break
+		}
+	}
+	if err != nil {
+		if respondImageError(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  map[string]string{imageFormField: imageFormField + " file is required"},
+		})
+		return
+	}
+	defer part.Close()
+
+	escort, err := h.service.UploadImage(c.Request.Context(), id, part)
+	if err != nil {
+		if errors.Is(err, services.ErrEscortNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Escort not found",
+			})
+			return
+		}
+		if respondImageError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to upload image",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Image uploaded successfully",
+		Data:    escort,
+	})
+}
+
+// GetImage handles GET and HEAD /api/escort/:id/image?variant=thumb|medium, streaming the
+// photo itself. http.ServeContent answers Range, If-None-Match and If-Modified-Since requests.
+func (h *EscortHandler) GetImage(c *gin.Context) {
+	id, err := h.parseIDParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid escort ID",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	image, err := h.service.OpenImage(c.Request.Context(), id, c.Query("variant"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownImageVariant) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Invalid image variant",
+				Errors:  err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, services.ErrEscortNotFound) || errors.Is(err, services.ErrImageNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Image not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve image",
+			Errors:  err.Error(),
+		})
+		return
+	}
+	defer image.Close()
+
+	c.Header("Content-Type", image.ContentType)
+	c.Header("ETag", image.ETag())
+	// Photos are personal data: browsers may keep them but must revalidate, shared caches must not
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Content-Disposition", `inline; filename="`+image.Name+`"`)
+	http.ServeContent(c.Writer, c.Request, image.Name, image.ModTime, image)
+}
+
+// respondImageError answers 400 for invalid photos and 413 for oversized ones (including
+// request bodies cut off by http.MaxBytesReader). It reports whether err was one of those.
+// The errors carry the machine-readable rejection code of services.ImageError.
+func respondImageError(c *gin.Context, err error) bool {
+	var bodyTooLarge *http.MaxBytesError
+	var imageErr *services.ImageError
+	switch {
+	case errors.Is(err, services.ErrImageTooLarge) || errors.As(err, &bodyTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+			Status:  "error",
+			Message: "Image too large",
+			Errors:  imageErrorDetails(services.ErrImageTooLarge),
+		})
+	case errors.As(err, &imageErr):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid image",
+			Errors:  imageErrorDetails(imageErr),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+func imageErrorDetails(err *services.ImageError) map[string]string {
+	return map[string]string{
+		"field":   imageFormField,
+		"code":    err.Code,
+		"message": err.Message,
+	}
+}
+
 // parseIDParam parses the ID parameter from URL
 func (h *EscortHandler) parseIDParam(c *gin.Context) (uint, error) {
 	idStr := c.Param("id")
@@ -409,31 +717,5 @@ func (h *EscortHandler) parseIDParam(c *gin.Context) (uint, error) {
 
 // formatValidationErrors formats validation errors for API response
 func (h *EscortHandler) formatValidationErrors(err error) map[string]string {
-	errors := make(map[string]string)
-
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		for _, fieldError := range validationErrors {
-			field := fieldError.Field()
-			tag := fieldError.Tag()
-
-			switch tag {
-			case "required":
-				errors[field] = field + " is required"
-			case "min":
-				errors[field] = field + " must be at least " + fieldError.Param() + " characters"
-			case "max":
-				errors[field] = field + " must not exceed " + fieldError.Param() + " characters"
-			case "oneof":
-				errors[field] = field + " must be one of: " + fieldError.Param()
-			case "email":
-				errors[field] = field + " must be a valid email address"
-			case "url":
-				errors[field] = field + " must be a valid URL"
-			default:
-				errors[field] = field + " is invalid"
-			}
-		}
-	}
-
-	return errors
+	return formatValidationErrors(err)
 }
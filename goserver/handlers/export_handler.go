@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	service *services.ExportService
+}
+
+func NewExportHandler(service *services.ExportService) *ExportHandler {
+	return &ExportHandler{service: service}
+}
+
+// ExportEscorts handles GET /api/escort/export?format=csv|xlsx
+func (h *ExportHandler) ExportEscorts(c *gin.Context) {
+	var filters models.ExportFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid query parameters",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.ExportFormatCSV)
+	contentType, ok := services.ExportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  map[string]string{"format": "format must be csv or xlsx"},
+		})
+		return
+	}
+
+	filename := services.ExportFilename(filters, format, time.Now())
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+
+	count, err := h.service.Write(c.Request.Context(), c.Writer, format, filters)
+	if err != nil {
+		// Once rows have been sent the status line is gone; all we can do is cut the download short
+		if c.Writer.Written() {
+			slog.ErrorContext(c.Request.Context(), "Escort export failed", "filename", filename, "rows", count, "error", err)
+			c.Abort()
+			return
+		}
+
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		if errors.Is(err, services.ErrInvalidExportFilter) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Invalid export filter",
+				Errors:  err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to export escorts",
+			Errors:  err.Error(),
+		})
+	}
+}
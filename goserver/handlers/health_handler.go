@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	health *services.HealthService
+}
+
+func NewHealthHandler(health *services.HealthService) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Live handles GET /livez: 200 while the process can serve requests at all
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.health.Live())
+}
+
+// Ready handles GET /readyz: 200 when the database, the photo storage and the schema are
+// usable, otherwise 503 with the failing components. The probe is public, so the errors
+// are left out; ReadyDetails has them.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	report := h.health.Ready(c.Request.Context())
+	c.JSON(readyStatus(report), withoutErrors(report))
+}
+
+// ReadyDetails handles GET /readyz/details, the readiness report with the error of each
+// failing component, for operators only (see middleware.OpsAuth)
+func (h *HealthHandler) ReadyDetails(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	report := h.health.Ready(c.Request.Context())
+	c.JSON(readyStatus(report), report)
+}
+
+// Health handles GET /api/health, the readiness report in the response format Laravel
+// has always read: status healthy/unhealthy and database connected/disconnected
+func (h *HealthHandler) Health(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	report := h.health.Ready(c.Request.Context())
+
+	status, database := "healthy", "connected"
+	if report.Status != models.HealthOK {
+		status = "unhealthy"
+	}
+	for _, component := range report.Components {
+		if component.Name == services.HealthCheckDatabase && component.Status != models.HealthOK {
+			database = "disconnected"
+		}
+	}
+
+	c.JSON(readyStatus(report), gin.H{
+		"status":         status,
+		"database":       database,
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"version":        report.Version,
+		"commit":         report.Commit,
+		"uptime_seconds": report.UptimeSeconds,
+		"components":     withoutErrors(report).Components,
+	})
+}
+
+func readyStatus(report *models.HealthReport) int {
+	if report.Status != models.HealthOK {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// withoutErrors copies report without the component errors, which can name hosts, paths
+// and buckets
+func withoutErrors(report *models.HealthReport) *models.HealthReport {
+	public := *report
+	public.Components = make([]models.ComponentHealth, len(report.Components))
+	for i, component := range report.Components {
+		component.Error = ""
+		public.Components[i] = component
+	}
+	return &public
+}
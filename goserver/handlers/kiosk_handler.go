@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type KioskHandler struct {
+	service   *services.KioskService
+	validator *validator.Validate
+}
+
+func NewKioskHandler(service *services.KioskService) *KioskHandler {
+	return &KioskHandler{
+		service:   service,
+		validator: validator.New(),
+	}
+}
+
+// GetLocations handles GET /api/kiosk-locations
+func (h *KioskHandler) GetLocations(c *gin.Context) {
+	includeInactive := c.Query("include_inactive") == "true"
+
+	locations, err := h.service.ListLocations(c.Request.Context(), includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve kiosk locations",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Kiosk locations retrieved successfully",
+		Data:    locations,
+	})
+}
+
+// CreateLocation handles POST /api/kiosk-locations
+func (h *KioskHandler) CreateLocation(c *gin.Context) {
+	var req models.CreateKioskLocationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	location, err := h.service.CreateLocation(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to create kiosk location",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  "success",
+		Message: "Kiosk location created successfully",
+		Data:    location,
+	})
+}
+
+// UpdateLocation handles PUT /api/kiosk-locations/:code
+func (h *KioskHandler) UpdateLocation(c *gin.Context) {
+	var req models.UpdateKioskLocationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	location, err := h.service.UpdateLocation(c.Request.Context(), c.Param("code"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrKioskLocationNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Kiosk location not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to update kiosk location",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Kiosk location updated successfully",
+		Data:    location,
+	})
+}
+
+// DeleteLocation handles DELETE /api/kiosk-locations/:code (deactivates the location and revokes its QR codes)
+func (h *KioskHandler) DeleteLocation(c *gin.Context) {
+	err := h.service.DeactivateLocation(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		if errors.Is(err, services.ErrKioskLocationNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Kiosk location not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to deactivate kiosk location",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Kiosk location deactivated successfully",
+	})
+}
+
+// GetQRCode handles GET /api/kiosk-locations/:code/qr (returns the PNG)
+func (h *KioskHandler) GetQRCode(c *gin.Context) {
+	var req models.KioskQRCodeRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request parameters",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	qr, ok := h.mintQRCode(c, req)
+	if !ok {
+		return
+	}
+
+	// Every request mints a new token, so the image must not be served from a cache
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="kiosk-%s.png"`, qr.Location))
+	c.Data(http.StatusOK, "image/png", qr.PNG)
+}
+
+// GetQRCodeJSON handles POST /api/kiosk-locations/:code/qr (returns the token, form URL and base64 PNG)
+func (h *KioskHandler) GetQRCodeJSON(c *gin.Context) {
+	var req models.KioskQRCodeRequest
+
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Invalid request format",
+				Errors:  err.Error(),
+			})
+			return
+		}
+	}
+
+	qr, ok := h.mintQRCode(c, req)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "QR code generated successfully",
+		Data:    qr,
+	})
+}
+
+// mintQRCode validates req and mints the QR code, writing the error response on failure
+func (h *KioskHandler) mintQRCode(c *gin.Context, req models.KioskQRCodeRequest) (*models.KioskQRCode, bool) {
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return nil, false
+	}
+
+	qr, err := h.service.MintQRCode(c.Request.Context(), c.Param("code"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrKioskLocationNotFound):
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Kiosk location not found",
+			})
+		case errors.Is(err, services.ErrKioskLocationInactive):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Status:  "error",
+				Message: "Kiosk location is inactive",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Status:  "error",
+				Message: "Failed to generate QR code",
+				Errors:  err.Error(),
+			})
+		}
+		return nil, false
+	}
+	return qr, true
+}
+
+// GetLocationReport handles GET /api/reports/kiosk-locations
+func (h *KioskHandler) GetLocationReport(c *gin.Context) {
+	var filters models.ExportFilters
+
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid query parameters",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	rows, err := h.service.Report(c.Request.Context(), filters)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportFilter) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Invalid report filters",
+				Errors:  err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to build location report",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Location report retrieved successfully",
+		Data:    rows,
+	})
+}
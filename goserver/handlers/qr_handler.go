@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"goserver/metrics"
 	"goserver/models"
 
 	"github.com/gin-gonic/gin"
@@ -10,13 +14,17 @@ import (
 	"github.com/skip2/go-qrcode"
 )
 
+// QRCodeHandler serves QR codes for the escort form. The endpoints are public, so they only
+// encode links to the form itself (formURL, with any query string such as ?kiosk=).
 type QRCodeHandler struct {
 	validator *validator.Validate
+	formURL   string
 }
 
-func NewQRCodeHandler() *QRCodeHandler {
+func NewQRCodeHandler(formURL string) *QRCodeHandler {
 	return &QRCodeHandler{
 		validator: validator.New(),
+		formURL:   formURL,
 	}
 }
 
@@ -46,6 +54,14 @@ func (h *QRCodeHandler) GenerateQRCode(c *gin.Context) {
 		})
 		return
 	}
+	if !h.isFormURL(req.URL) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  map[string]string{"URL": "URL must point to the escort form " + h.formURL},
+		})
+		return
+	}
 
 	// Generate QR code
 	png, err := qrcode.Encode(req.URL, qrcode.Medium, req.Size)
@@ -57,6 +73,7 @@ func (h *QRCodeHandler) GenerateQRCode(c *gin.Context) {
 		})
 		return
 	}
+	metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeForm).Inc()
 
 	// Return as PNG image
 	c.Header("Content-Type", "image/png")
@@ -90,6 +107,14 @@ func (h *QRCodeHandler) GenerateQRCodeJSON(c *gin.Context) {
 		})
 		return
 	}
+	if !h.isFormURL(req.URL) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  map[string]string{"URL": "URL must point to the escort form " + h.formURL},
+		})
+		return
+	}
 
 	// Generate QR code
 	png, err := qrcode.Encode(req.URL, qrcode.Medium, req.Size)
@@ -101,9 +126,10 @@ func (h *QRCodeHandler) GenerateQRCodeJSON(c *gin.Context) {
 		})
 		return
 	}
+	metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeForm).Inc()
 
 	// Encode as base64
-	base64Data := "data:image/png;base64," + string(png)
+	base64Data := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Status:  "success",
@@ -117,29 +143,23 @@ func (h *QRCodeHandler) GenerateQRCodeJSON(c *gin.Context) {
 	})
 }
 
-// formatValidationErrors formats validation errors for API response
-func (h *QRCodeHandler) formatValidationErrors(err error) map[string]string {
-	errors := make(map[string]string)
-
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		for _, fieldError := range validationErrors {
-			field := fieldError.Field()
-			tag := fieldError.Tag()
-
-			switch tag {
-			case "required":
-				errors[field] = field + " is required"
-			case "min":
-				errors[field] = field + " must be at least " + fieldError.Param() + " characters"
-			case "max":
-				errors[field] = field + " must not exceed " + fieldError.Param() + " characters"
-			case "url":
-				errors[field] = field + " must be a valid URL"
-			default:
-				errors[field] = field + " is invalid"
-			}
-		}
+// isFormURL reports whether raw links to the escort form: the same scheme, host and path as
+// formURL, with any query string
+func (h *QRCodeHandler) isFormURL(raw string) bool {
+	form, err := url.Parse(h.formURL)
+	if err != nil {
+		return false
 	}
+	target, err := url.Parse(raw)
+	if err != nil || target.User != nil {
+		return false
+	}
+	return strings.EqualFold(target.Scheme, form.Scheme) &&
+		strings.EqualFold(target.Host, form.Host) &&
+		strings.TrimSuffix(target.Path, "/") == strings.TrimSuffix(form.Path, "/")
+}
 
-	return errors
+// formatValidationErrors formats validation errors for API response
+func (h *QRCodeHandler) formatValidationErrors(err error) map[string]string {
+	return formatValidationErrors(err)
 }
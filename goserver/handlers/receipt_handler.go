@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReceiptHandler struct {
+	receipts *services.ReceiptService
+}
+
+func NewReceiptHandler(receipts *services.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{receipts: receipts}
+}
+
+// GetReceipt handles GET /api/receipt/:token
+func (h *ReceiptHandler) GetReceipt(c *gin.Context) {
+	// The status changes while the escort waits, and the page is personal
+	c.Header("Cache-Control", "no-store")
+
+	receipt, err := h.receipts.Lookup(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReceiptExpired):
+			c.JSON(http.StatusGone, models.APIResponse{
+				Status:  "error",
+				Message: "Receipt has expired",
+			})
+		case errors.Is(err, services.ErrInvalidReceipt), errors.Is(err, services.ErrEscortNotFound):
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Receipt not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Status:  "error",
+				Message: "Failed to retrieve receipt",
+				Errors:  err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Receipt retrieved successfully",
+		Data:    receipt,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type RejectionReasonHandler struct {
+	service   *services.RejectionReasonService
+	validator *validator.Validate
+}
+
+func NewRejectionReasonHandler(service *services.RejectionReasonService) *RejectionReasonHandler {
+	return &RejectionReasonHandler{
+		service:   service,
+		validator: validator.New(),
+	}
+}
+
+// GetReasons handles GET /api/rejection-reasons
+func (h *RejectionReasonHandler) GetReasons(c *gin.Context) {
+	includeInactive := c.Query("include_inactive") == "true"
+
+	reasons, err := h.service.ListReasons(c.Request.Context(), includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve rejection reasons",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Rejection reasons retrieved successfully",
+		Data:    reasons,
+	})
+}
+
+// CreateReason handles POST /api/rejection-reasons
+func (h *RejectionReasonHandler) CreateReason(c *gin.Context) {
+	var req models.CreateRejectionReasonRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	reason, err := h.service.CreateReason(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrRejectionReasonExists) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Status:  "error",
+				Message: "Rejection reason code already exists; update it instead",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to create rejection reason",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  "success",
+		Message: "Rejection reason created successfully",
+		Data:    reason,
+	})
+}
+
+// UpdateReason handles PUT /api/rejection-reasons/:code
+func (h *RejectionReasonHandler) UpdateReason(c *gin.Context) {
+	var req models.UpdateRejectionReasonRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	reason, err := h.service.UpdateReason(c.Request.Context(), c.Param("code"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrRejectionReasonNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Rejection reason not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to update rejection reason",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Rejection reason updated successfully",
+		Data:    reason,
+	})
+}
+
+// DeleteReason handles DELETE /api/rejection-reasons/:code (deactivates the reason)
+func (h *RejectionReasonHandler) DeleteReason(c *gin.Context) {
+	err := h.service.DeactivateReason(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		if errors.Is(err, services.ErrRejectionReasonNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Rejection reason not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to deactivate rejection reason",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Rejection reason deactivated successfully",
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StorageHandler struct {
+	scanner *services.StorageScanner
+}
+
+func NewStorageHandler(scanner *services.StorageScanner) *StorageHandler {
+	return &StorageHandler{scanner: scanner}
+}
+
+// GetLatestScan handles GET /api/storage/scan
+func (h *StorageHandler) GetLatestScan(c *gin.Context) {
+	report, err := h.scanner.LatestReport(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrNoStorageScan) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "No storage scan has run yet",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve storage scan report",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Storage scan report retrieved successfully",
+		Data:    report,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamRetry is the reconnect delay, in milliseconds, suggested to EventSource clients
+const streamRetry = 3000
+
+type StreamHandler struct {
+	feed      *services.EscortFeed
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(feed *services.EscortFeed, heartbeat time.Duration) *StreamHandler {
+	return &StreamHandler{feed: feed, heartbeat: heartbeat}
+}
+
+// StreamEscorts handles GET /api/escort/stream. It sends every escort event as a
+// Server-Sent Event named after its type, with the event ID as the SSE id, plus a "ping"
+// event every heartbeat interval. Clients resume with the Last-Event-ID header (or the
+// last_event_id query parameter); a "resync" event tells them events were missed and
+// the escort list must be reloaded.
+func (h *StreamHandler) StreamEscorts(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	var lastID int64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Status:  "error",
+				Message: "Invalid Last-Event-ID",
+			})
+			return
+		}
+	}
+
+	sub, backlog, complete := h.feed.Subscribe(lastID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry); err != nil {
+		return
+	}
+	if !complete {
+		if err := writeSSE(w, "", "resync", gin.H{"last_event_id": lastID}); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if err := writeEscortEvent(w, event); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped by the feed; the client reconnects and resumes from its Last-Event-ID
+				return
+			}
+			if err := writeEscortEvent(w, event); err != nil {
+				return
+			}
+		case now := <-heartbeat.C:
+			if err := writeSSE(w, "", "ping", gin.H{"time": now.Format(time.RFC3339)}); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+func writeEscortEvent(w io.Writer, event models.EscortEvent) error {
+	return writeSSE(w, strconv.FormatInt(event.ID, 10), event.Type, event)
+}
+
+// writeSSE writes one Server-Sent Event with a JSON data line
+func writeSSE(w io.Writer, id, name string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
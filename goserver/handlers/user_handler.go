@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler serves the legacy /api/v1/users endpoints, which keep their original
+// {"data": ...} / {"error": ...} response shape for compatibility
+type UserHandler struct {
+	service *services.UserService
+}
+
+func NewUserHandler(service *services.UserService) *UserHandler {
+	return &UserHandler{service: service}
+}
+
+// GetUsers handles GET /api/v1/users
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	users, err := h.service.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": users,
+	})
+}
+
+// CreateUser handles POST /api/v1/users
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var user struct {
+		Name     string `json:"name" binding:"required"`
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required,min=6"`
+		Role     string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.Role == "" {
+		user.Role = models.DefaultRole
+	}
+	if !models.IsValidRole(user.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + user.Role})
+		return
+	}
+
+	created, err := h.service.CreateUser(c.Request.Context(), user.Name, user.Email, user.Password, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "User created successfully",
+		"id":      created.ID,
+	})
+}
+
+// GetUser handles GET /api/v1/users/:id
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := h.parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// UpdateUser handles PUT /api/v1/users/:id
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := h.parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.Role != "" && !models.IsValidRole(user.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + user.Role})
+		return
+	}
+
+	// Role is optional; an empty value keeps the current role
+	if err := h.service.UpdateUser(c.Request.Context(), id, user.Name, user.Email, user.Role); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// DeleteUser handles DELETE /api/v1/users/:id
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := h.parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteUser(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// parseIDParam parses the :id parameter, answering 400 when it is not a number
+func (h *UserHandler) parseIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
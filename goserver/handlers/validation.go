@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// formatValidationErrors formats validation errors for API response
+func formatValidationErrors(err error) map[string]string {
+	errors := make(map[string]string)
+
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, fieldError := range validationErrors {
+			field := fieldError.Field()
+			tag := fieldError.Tag()
+
+			switch tag {
+			case "required":
+				errors[field] = field + " is required"
+			case "required_if":
+				errors[field] = field + " is required when " + strings.Replace(fieldError.Param(), " ", " is ", 1)
+			case "min":
+				errors[field] = field + " must be at least " + fieldError.Param() + " characters"
+			case "max":
+				errors[field] = field + " must not exceed " + fieldError.Param() + " characters"
+			case "oneof":
+				errors[field] = field + " must be one of: " + fieldError.Param()
+			case "email":
+				errors[field] = field + " must be a valid email address"
+			case "url":
+				errors[field] = field + " must be a valid URL"
+			default:
+				errors[field] = field + " is invalid"
+			}
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type WebhookHandler struct {
+	service   *services.WebhookService
+	validator *validator.Validate
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service:   service,
+		validator: validator.New(),
+	}
+}
+
+// GetWebhooks handles GET /api/webhooks
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	subs, err := h.service.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve webhooks",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Webhooks retrieved successfully",
+		Data:    subs,
+	})
+}
+
+// CreateWebhook handles POST /api/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	sub, err := h.service.CreateWebhook(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to create webhook",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  "success",
+		Message: "Webhook created successfully; store the secret, it is not shown again",
+		Data:    sub,
+	})
+}
+
+// UpdateWebhook handles PUT /api/webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := parseUintParam(c, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid request format",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Validation failed",
+			Errors:  formatValidationErrors(err),
+		})
+		return
+	}
+
+	sub, err := h.service.UpdateWebhook(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Webhook not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to update webhook",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Webhook updated successfully",
+		Data:    sub,
+	})
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseUintParam(c, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Webhook not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to delete webhook",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// GetDeliveries handles GET /api/webhooks/deliveries?status=&subscription_id=
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	h.listDeliveries(c, "")
+}
+
+// GetDeadLetters handles GET /api/webhooks/dead-letters, the deliveries that ran out of attempts
+func (h *WebhookHandler) GetDeadLetters(c *gin.Context) {
+	h.listDeliveries(c, models.DeliveryDead)
+}
+
+func (h *WebhookHandler) listDeliveries(c *gin.Context, status string) {
+	var filters models.WebhookDeliveryFilters
+
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: "Invalid query parameters",
+			Errors:  err.Error(),
+		})
+		return
+	}
+	if status != "" {
+		filters.Status = status
+	}
+
+	deliveries, meta, err := h.service.ListDeliveries(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to retrieve webhook deliveries",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Status:  "success",
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    deliveries,
+		Meta:    meta,
+	})
+}
+
+// RedeliverDelivery handles POST /api/webhooks/deliveries/:id/redeliver
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	id, ok := parseUintParam(c, "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.service.Redeliver(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrWebhookDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Status:  "error",
+				Message: "Webhook delivery not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to redeliver webhook",
+			Errors:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Status:  "success",
+		Message: "Webhook delivery queued for redelivery",
+		Data:    delivery,
+	})
+}
+
+// parseUintParam parses the :id parameter, answering 400 with message when it is not a number
+func parseUintParam(c *gin.Context, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: message,
+			Errors:  err.Error(),
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
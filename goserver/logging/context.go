@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type requestIDKey struct{}
+
+type queryStatsKey struct{}
+
+// WithRequestID returns a context carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// QueryStats adds up the database queries made while handling one request
+type QueryStats struct {
+	count    atomic.Int64
+	duration atomic.Int64
+}
+
+// Add records a query that took d
+func (s *QueryStats) Add(d time.Duration) {
+	s.count.Add(1)
+	s.duration.Add(int64(d))
+}
+
+// Count is the number of queries made
+func (s *QueryStats) Count() int64 {
+	return s.count.Load()
+}
+
+// Duration is the time spent in queries
+func (s *QueryStats) Duration() time.Duration {
+	return time.Duration(s.duration.Load())
+}
+
+// WithQueryStats returns a context that collects the database queries made with it
+func WithQueryStats(ctx context.Context) (context.Context, *QueryStats) {
+	stats := &QueryStats{}
+	return context.WithValue(ctx, queryStatsKey{}, stats), stats
+}
+
+// QueryStatsFrom returns the query stats collected for ctx, or nil outside a request
+func QueryStatsFrom(ctx context.Context) *QueryStats {
+	stats, _ := ctx.Value(queryStatsKey{}).(*QueryStats)
+	return stats
+}
+
+// Milliseconds returns d in milliseconds, for log attributes
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
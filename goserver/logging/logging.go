@@ -0,0 +1,142 @@
+// Package logging sets up the structured logger of the server and carries the request ID and
+// database timings of a request through its context
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Log output formats (LOG_FORMAT)
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of PII attributes in log output
+const Redacted = "[REDACTED]"
+
+// piiKeys are attribute and field names whose values never reach the log
+var piiKeys = map[string]bool{
+	"nama_pengantar": true,
+	"nama_pasien":    true,
+	"nomor_hp":       true,
+	"plat_nomor":     true,
+	"email":          true,
+	"password":       true,
+	"authorization":  true,
+	"token":          true,
+}
+
+// New creates a logger writing format records of level and above to w. Records logged with
+// a request context carry its request_id and trace_id; PII attributes, also inside logged structs and
+// maps, are replaced by Redacted.
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// ParseFormat checks a LOG_FORMAT value
+func ParseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case FormatJSON, FormatText:
+		return format, nil
+	}
+	return "", fmt.Errorf("must be %s or %s", FormatJSON, FormatText)
+}
+
+// contextHandler adds the request ID and trace of the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr is the ReplaceAttr hook that keeps PII out of log output
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if piiKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+	if attr.Value.Kind() == slog.KindAny {
+		if value, ok := redactValue(attr.Value.Any()); ok {
+			return slog.Any(attr.Key, value)
+		}
+	}
+	return attr
+}
+
+// redactValue returns a struct, map or slice as its JSON form with PII fields redacted; ok
+// is false for other values, which are logged as they are
+func redactValue(value any) (any, bool) {
+	if _, isError := value.(error); isError {
+		return nil, false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, false
+	}
+	return redactJSON(decoded), true
+}
+
+// redactJSON redacts the PII fields of decoded JSON
+func redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if piiKeys[strings.ToLower(key)] {
+				v[key] = Redacted
+			} else {
+				v[key] = redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedactsPII(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, slog.LevelInfo, FormatJSON)
+
+	type escort struct {
+		ID         uint   `json:"id"`
+		NomorHP    string `json:"nomor_hp"`
+		NamaPasien string `json:"nama_pasien"`
+		Status     string `json:"status"`
+	}
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "Escort stored",
+		"nomor_hp", "081234567890",
+		"escort", &escort{ID: 7, NomorHP: "081234567890", NamaPasien: "Siti Aminah", Status: "pending"},
+		"request", map[string]any{"body": []any{map[string]any{"Nama_Pasien": "Siti Aminah"}}},
+		"error", errors.New("boom"),
+	)
+
+	if strings.Contains(out.String(), "081234567890") || strings.Contains(out.String(), "Siti") {
+		t.Fatalf("PII in log output: %s", out.String())
+	}
+
+	var record struct {
+		RequestID string         `json:"request_id"`
+		NomorHP   string         `json:"nomor_hp"`
+		Escort    map[string]any `json:"escort"`
+		Error     string         `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("decode log record: %v", err)
+	}
+	if record.RequestID != "req-1" || record.NomorHP != Redacted || record.Error != "boom" {
+		t.Fatalf("unexpected record: %s", out.String())
+	}
+	if record.Escort["status"] != "pending" || record.Escort["nama_pasien"] != Redacted {
+		t.Fatalf("escort = %v, want status kept and nama_pasien redacted", record.Escort)
+	}
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, slog.LevelWarn, FormatText)
+	logger.Info("hidden")
+	logger.With("component", "test").WarnContext(WithRequestID(context.Background(), "req-2"), "shown")
+
+	if got := out.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "msg=shown") || !strings.Contains(got, "request_id=req-2") {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatal("ParseFormat accepted xml")
+	}
+}
@@ -4,21 +4,62 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 
 	"goserver/database"
+	"goserver/filestore"
 	"goserver/handlers"
+	"goserver/logging"
+	"goserver/metrics"
+	"goserver/middleware"
+	"goserver/models"
+	"goserver/repository"
 	"goserver/services"
+	"goserver/telemetry"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 )
 
+// Build identification, set with -ldflags "-X main.version=... -X main.commit=..."
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildInfo returns the version and commit of this binary. Without -ldflags the commit comes
+// from the VCS stamp go build embeds.
+func buildInfo() models.BuildInfo {
+	info := models.BuildInfo{Version: version, Commit: commit}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+		if build, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range build.Settings {
+				if setting.Key == "vcs.revision" {
+					info.Commit = setting.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
 type Server struct {
-	db     *pgxpool.Pool
-	router *gin.Engine
+	db      *pgxpool.Pool
+	store   *repository.Store
+	files   filestore.Storage
+	feed    *services.EscortFeed
+	limiter *services.RateLimiter
+	health  *handlers.HealthHandler
+	router  *gin.Engine
+	config  *Config
 }
 
 type Config struct {
@@ -29,6 +70,63 @@ type Config struct {
 	DBPassword string
 	AppURL     string
 	AppEnv     string
+
+	// LogLevel is the lowest level logged (LOG_LEVEL: debug, info, warn or error)
+	LogLevel slog.Level
+	// LogFormat is json or text (LOG_FORMAT)
+	LogFormat string
+	// TracesExporter sends trace spans over OTLP, to stdout or nowhere (OTEL_TRACES_EXPORTER)
+	TracesExporter string
+
+	// SanctumExpiration mirrors config/sanctum.php 'expiration' (0 = tokens never expire)
+	SanctumExpiration time.Duration
+
+	// StreamHeartbeat is how often idle /api/escort/stream connections get a ping event
+	StreamHeartbeat time.Duration
+	// StreamReplaySize is how many escort events are kept for Last-Event-ID resumes
+	StreamReplaySize int
+
+	// WebhookMaxAttempts is how many times a webhook delivery is tried before it is dead-lettered
+	WebhookMaxAttempts int
+	// WebhookRetryBase is the wait after the first failed attempt; it doubles after each retry
+	WebhookRetryBase time.Duration
+
+	// Storage selects where escort photos are kept (STORAGE_DRIVER, STORAGE_LOCAL_ROOT, S3_*)
+	Storage filestore.Config
+	// ImageVariantBackfill creates missing photo variants in the background at startup
+	ImageVariantBackfill bool
+	// StorageScanInterval is how often the photo storage consistency scan runs (0 = never)
+	StorageScanInterval time.Duration
+	// StorageOrphanGrace is how long orphaned photos stay in quarantine before they are deleted
+	StorageOrphanGrace time.Duration
+
+	// IdempotencyTTL is how long responses to requests with an Idempotency-Key are replayed
+	IdempotencyTTL time.Duration
+	// Duplicates configures the detection of repeated escort submissions (DUPLICATE_*)
+	Duplicates services.DuplicatePolicy
+	// Receipts configures the signed receipt links given to submitters (RECEIPT_*)
+	Receipts services.ReceiptConfig
+	// Kiosks configures the signed QR codes posted at kiosk locations (KIOSK_*)
+	Kiosks services.KioskConfig
+
+	// RateLimitEnabled turns request rate limiting on (RATE_LIMIT_ENABLED)
+	RateLimitEnabled bool
+	// RateLimitStore keeps the rate limit buckets in "postgres", shared by all instances, or in "memory"
+	RateLimitStore string
+	// RateLimits are the per-route rate limit policies (RATE_LIMIT_SUBMIT, _QR, _PUBLIC, _API)
+	RateLimits services.RatePolicies
+	// TrustedProxies may set X-Forwarded-For; the client IP of other requests is the peer address
+	TrustedProxies []string
+
+	// MetricsAddr serves GET /metrics on its own address (e.g. 127.0.0.1:9090) instead of the API port
+	MetricsAddr string
+	// MetricsToken is the bearer token scrapes must send; without MetricsAddr, /metrics needs one
+	MetricsToken string
+
+	// HealthCheckTimeout bounds each dependency check of /readyz and /api/health
+	HealthCheckTimeout time.Duration
+	// HealthCheckCacheTTL is how long a dependency check result is reused by the probes
+	HealthCheckCacheTTL time.Duration
 }
 
 func loadConfig() (*Config, error) {
@@ -38,14 +136,223 @@ func loadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: must be debug, info, warn or error")
+	}
+
+	logFormat, err := logging.ParseFormat(getEnv("LOG_FORMAT", logging.FormatJSON))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_FORMAT: %w", err)
+	}
+
+	tracesExporter, err := telemetry.ParseExporter(getEnv("OTEL_TRACES_EXPORTER", telemetry.ExporterNone))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_TRACES_EXPORTER: %w", err)
+	}
+
+	sanctumExpiration, err := strconv.Atoi(getEnv("SANCTUM_EXPIRATION", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SANCTUM_EXPIRATION: %w", err)
+	}
+
+	streamHeartbeat, err := strconv.Atoi(getEnv("ESCORT_STREAM_HEARTBEAT", "15"))
+	if err != nil || streamHeartbeat <= 0 {
+		return nil, fmt.Errorf("invalid ESCORT_STREAM_HEARTBEAT: must be a positive number of seconds")
+	}
+
+	streamReplaySize, err := strconv.Atoi(getEnv("ESCORT_STREAM_REPLAY", "500"))
+	if err != nil || streamReplaySize <= 0 {
+		return nil, fmt.Errorf("invalid ESCORT_STREAM_REPLAY: must be a positive number of events")
+	}
+
+	webhookMaxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil || webhookMaxAttempts <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must be a positive number")
+	}
+
+	webhookRetryBase, err := strconv.Atoi(getEnv("WEBHOOK_RETRY_BASE", "30"))
+	if err != nil || webhookRetryBase < 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE: must be a number of seconds")
+	}
+
+	s3UseSSL, err := strconv.ParseBool(getEnv("S3_USE_SSL", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_USE_SSL: %w", err)
+	}
+
+	imageVariantBackfill, err := strconv.ParseBool(getEnv("IMAGE_VARIANT_BACKFILL", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_VARIANT_BACKFILL: %w", err)
+	}
+
+	storageScanInterval, err := strconv.Atoi(getEnv("STORAGE_SCAN_INTERVAL", "24"))
+	if err != nil || storageScanInterval < 0 {
+		return nil, fmt.Errorf("invalid STORAGE_SCAN_INTERVAL: must be a number of hours")
+	}
+
+	storageOrphanGrace, err := strconv.Atoi(getEnv("STORAGE_ORPHAN_GRACE", "168"))
+	if err != nil || storageOrphanGrace <= 0 {
+		return nil, fmt.Errorf("invalid STORAGE_ORPHAN_GRACE: must be a positive number of hours")
+	}
+
+	idempotencyTTL, err := strconv.Atoi(getEnv("IDEMPOTENCY_TTL", "24"))
+	if err != nil || idempotencyTTL <= 0 {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_TTL: must be a positive number of hours")
+	}
+
+	duplicateWindow, err := strconv.Atoi(getEnv("DUPLICATE_WINDOW", "10"))
+	if err != nil || duplicateWindow < 0 {
+		return nil, fmt.Errorf("invalid DUPLICATE_WINDOW: must be a number of minutes")
+	}
+
+	duplicateAction := getEnv("DUPLICATE_ACTION", services.DuplicateActionLink)
+	if duplicateAction != services.DuplicateActionLink && duplicateAction != services.DuplicateActionReject {
+		return nil, fmt.Errorf("invalid DUPLICATE_ACTION: must be %s or %s", services.DuplicateActionLink, services.DuplicateActionReject)
+	}
+
+	// Laravel's APP_KEY is already a per-installation secret, so it serves when no separate one is set
+	receiptSecret := getEnv("RECEIPT_SECRET", os.Getenv("APP_KEY"))
+	if receiptSecret == "" {
+		return nil, fmt.Errorf("invalid RECEIPT_SECRET: set RECEIPT_SECRET or APP_KEY")
+	}
+
+	receiptTTL, err := strconv.Atoi(getEnv("RECEIPT_TTL", "72"))
+	if err != nil || receiptTTL <= 0 {
+		return nil, fmt.Errorf("invalid RECEIPT_TTL: must be a positive number of hours")
+	}
+
+	kioskSecret := getEnv("KIOSK_SECRET", os.Getenv("APP_KEY"))
+	if kioskSecret == "" {
+		return nil, fmt.Errorf("invalid KIOSK_SECRET: set KIOSK_SECRET or APP_KEY")
+	}
+
+	kioskQRTTL, err := strconv.Atoi(getEnv("KIOSK_QR_TTL", "90"))
+	if err != nil || kioskQRTTL <= 0 {
+		return nil, fmt.Errorf("invalid KIOSK_QR_TTL: must be a positive number of days")
+	}
+
+	kioskTokenRequired, err := strconv.ParseBool(getEnv("KIOSK_TOKEN_REQUIRED", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KIOSK_TOKEN_REQUIRED: %w", err)
+	}
+
+	rateLimitEnabled, err := strconv.ParseBool(getEnv("RATE_LIMIT_ENABLED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ENABLED: %w", err)
+	}
+
+	rateLimitStore := getEnv("RATE_LIMIT_STORE", "postgres")
+	if rateLimitStore != "postgres" && rateLimitStore != "memory" {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: must be postgres or memory")
+	}
+
+	submitLimit, err := services.ParseRatePolicy("submit", getEnv("RATE_LIMIT_SUBMIT", "10/m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_SUBMIT: %w", err)
+	}
+
+	qrLimit, err := services.ParseRatePolicy("qr", getEnv("RATE_LIMIT_QR", "30/m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_QR: %w", err)
+	}
+
+	publicLimit, err := services.ParseRatePolicy("public", getEnv("RATE_LIMIT_PUBLIC", "60/m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_PUBLIC: %w", err)
+	}
+
+	apiLimit, err := services.ParseRatePolicy("api", getEnv("RATE_LIMIT_API", "600/m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_API: %w", err)
+	}
+
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnv("TRUSTED_PROXIES", "127.0.0.1,::1"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
+	healthCheckTimeout, err := strconv.Atoi(getEnv("HEALTH_CHECK_TIMEOUT", "2"))
+	if err != nil || healthCheckTimeout <= 0 {
+		return nil, fmt.Errorf("invalid HEALTH_CHECK_TIMEOUT: must be a positive number of seconds")
+	}
+
+	healthCheckCache, err := strconv.Atoi(getEnv("HEALTH_CHECK_CACHE", "5"))
+	if err != nil || healthCheckCache < 0 {
+		return nil, fmt.Errorf("invalid HEALTH_CHECK_CACHE: must be a number of seconds")
+	}
+
+	appURL := getEnv("APP_URL", "http://localhost:8080")
+
 	config := &Config{
 		DBHost:     getEnv("DB_HOST", "127.0.0.1"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBDatabase: getEnv("DB_DATABASE", "laravel_app"),
 		DBUsername: getEnv("DB_USERNAME", "laravel_user"),
 		DBPassword: getEnv("DB_PASSWORD", ""),
-		AppURL:     getEnv("APP_URL", "http://localhost:8080"),
+		AppURL:     appURL,
 		AppEnv:     getEnv("APP_ENV", "local"),
+
+		LogLevel:       logLevel,
+		LogFormat:      logFormat,
+		TracesExporter: tracesExporter,
+
+		SanctumExpiration: time.Duration(sanctumExpiration) * time.Minute,
+		StreamHeartbeat:   time.Duration(streamHeartbeat) * time.Second,
+		StreamReplaySize:  streamReplaySize,
+
+		WebhookMaxAttempts: webhookMaxAttempts,
+		WebhookRetryBase:   time.Duration(webhookRetryBase) * time.Second,
+
+		Storage: filestore.Config{
+			Driver:      getEnv("STORAGE_DRIVER", filestore.DriverLocal),
+			LocalRoot:   getEnv("STORAGE_LOCAL_ROOT", "storage/uploads"),
+			S3Endpoint:  getEnv("S3_ENDPOINT", ""),
+			S3Region:    getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:    getEnv("S3_BUCKET", ""),
+			S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+			S3UseSSL:    s3UseSSL,
+			S3Prefix:    getEnv("S3_PREFIX", ""),
+		},
+		ImageVariantBackfill: imageVariantBackfill,
+		StorageScanInterval:  time.Duration(storageScanInterval) * time.Hour,
+		StorageOrphanGrace:   time.Duration(storageOrphanGrace) * time.Hour,
+
+		IdempotencyTTL: time.Duration(idempotencyTTL) * time.Hour,
+		Duplicates: services.DuplicatePolicy{
+			Window: time.Duration(duplicateWindow) * time.Minute,
+			Action: duplicateAction,
+		},
+		Receipts: services.ReceiptConfig{
+			Secret: receiptSecret,
+			TTL:    time.Duration(receiptTTL) * time.Hour,
+			URL:    getEnv("RECEIPT_URL", strings.TrimRight(appURL, "/")+"/api/receipt"),
+		},
+		Kiosks: services.KioskConfig{
+			Secret:   kioskSecret,
+			TTL:      time.Duration(kioskQRTTL) * 24 * time.Hour,
+			FormURL:  getEnv("KIOSK_FORM_URL", strings.TrimRight(appURL, "/")+"/form"),
+			Required: kioskTokenRequired,
+		},
+
+		RateLimitEnabled: rateLimitEnabled,
+		RateLimitStore:   rateLimitStore,
+		RateLimits: services.RatePolicies{
+			Submit: submitLimit,
+			QRCode: qrLimit,
+			Public: publicLimit,
+			API:    apiLimit,
+		},
+		TrustedProxies: trustedProxies,
+
+		MetricsAddr:  getEnv("METRICS_ADDR", ""),
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		HealthCheckTimeout:  time.Duration(healthCheckTimeout) * time.Second,
+		HealthCheckCacheTTL: time.Duration(healthCheckCache) * time.Second,
 	}
 
 	return config, nil
@@ -58,7 +365,8 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func (s *Server) connectDatabase(config *Config) error {
+// openDatabase connects to PostgreSQL without running migrations
+func openDatabase(config *Config) (*pgxpool.Pool, error) {
 	// Use the database package for connection
 	dbConfig := database.DatabaseConfig{
 		Host:     config.DBHost,
@@ -70,29 +378,141 @@ func (s *Server) connectDatabase(config *Config) error {
 
 	dbpool, err := database.NewConnection(dbConfig)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return dbpool, nil
+}
 
-	// Run migrations
-	err = database.RunMigrations(dbpool)
+func (s *Server) connectDatabase(config *Config, migrate bool) error {
+	dbpool, err := openDatabase(config)
 	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+		return err
+	}
+
+	// Run migrations
+	if migrate {
+		err = database.RunMigrations(dbpool)
+		if err != nil {
+			dbpool.Close()
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
 	}
 
 	s.db = dbpool
+	s.store = repository.NewPostgresStore(dbpool)
+	if err := metrics.RegisterPool(dbpool); err != nil {
+		slog.Warn("Database pool metrics unavailable", "error", err)
+	}
 	return nil
 }
 
+// openStorage creates the escort photo storage backend selected by the configuration
+func (s *Server) openStorage(ctx context.Context) error {
+	files, err := filestore.New(s.config.Storage)
+	if err != nil {
+		return err
+	}
+
+	// An unreachable bucket is reported but does not stop the server; 'goserver check' fails on it
+	if err := files.Ping(ctx); err != nil {
+		slog.WarnContext(ctx, "Storage is not usable", "storage", files.String(), "error", err)
+	}
+	s.files = files
+	return nil
+}
+
+// startEscortFeed relays escort events from the store to stream clients until ctx is cancelled
+func (s *Server) startEscortFeed(ctx context.Context) {
+	s.feed = services.NewEscortFeed(s.config.StreamReplaySize)
+	go s.feed.Run(ctx, s.store)
+}
+
+// startWebhookDispatcher sends queued webhook deliveries until ctx is cancelled
+func (s *Server) startWebhookDispatcher(ctx context.Context) {
+	dispatcher := services.NewWebhookDispatcher(s.store.Webhooks, s.config.WebhookMaxAttempts, s.config.WebhookRetryBase)
+	go dispatcher.Run(ctx)
+}
+
+// startImageVariantBackfill creates the variants of photos stored before variants existed
+func (s *Server) startImageVariantBackfill(ctx context.Context) {
+	if !s.config.ImageVariantBackfill {
+		return
+	}
+	go func() {
+		created, err := services.NewEscortService(s.store, s.files).BackfillImageVariants(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Image variant backfill failed", "error", err)
+		}
+		if created > 0 {
+			slog.InfoContext(ctx, "Image variant backfill completed", "photos", created)
+		}
+	}()
+}
+
+// startStorageScan quarantines and purges orphaned photos every StorageScanInterval
+func (s *Server) startStorageScan(ctx context.Context) {
+	if s.config.StorageScanInterval == 0 {
+		return
+	}
+	scanner := services.NewStorageScanner(s.store, s.files, s.config.StorageOrphanGrace)
+	go scanner.Run(ctx, s.config.StorageScanInterval)
+}
+
+// startIdempotencyCleanup deletes expired Idempotency-Key records until ctx is cancelled
+func (s *Server) startIdempotencyCleanup(ctx context.Context) {
+	go services.NewIdempotencyService(s.store.Idempotency, s.config.IdempotencyTTL).Run(ctx)
+}
+
+// startRateLimiter creates the request rate limiter and deletes its refilled buckets until
+// ctx is cancelled; with rate limiting disabled, s.limiter stays nil
+func (s *Server) startRateLimiter(ctx context.Context) {
+	if !s.config.RateLimitEnabled {
+		return
+	}
+	buckets := s.store.RateLimits
+	if s.config.RateLimitStore == "memory" {
+		buckets = repository.NewMemoryRateLimitRepository()
+	}
+	s.limiter = services.NewRateLimiter(buckets, services.NewKioskService(s.store, s.config.Kiosks))
+	go s.limiter.Run(ctx)
+}
+
+// startMetricsServer serves GET /metrics and /readyz/details on MetricsAddr until ctx is
+// cancelled. Without MetricsAddr, setupRoutes puts them on the API router instead.
+func (s *Server) startMetricsServer(ctx context.Context) {
+	if s.config.MetricsAddr == "" {
+		return
+	}
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	s.setupOpsRoutes(router)
+
+	server := &http.Server{Addr: s.config.MetricsAddr, Handler: router}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		slog.InfoContext(ctx, "Serving metrics", "addr", s.config.MetricsAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.ErrorContext(ctx, "Metrics server stopped", "error", err)
+		}
+	}()
+}
+
 func (s *Server) setupRoutes() {
 	// Middleware
-	s.router.Use(gin.Logger())
-	s.router.Use(gin.Recovery())
+	s.router.Use(middleware.Tracing())
+	s.router.Use(middleware.RequestLogger())
+	s.router.Use(middleware.Metrics())
+	s.router.Use(middleware.ErrorHandler())
 
 	// CORS middleware for Laravel frontend
 	s.router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -103,240 +523,222 @@ func (s *Server) setupRoutes() {
 	})
 
 	// Initialize services and handlers
-	escortService := services.NewEscortService(s.db)
-	escortHandler := handlers.NewEscortHandler(escortService)
-	qrHandler := handlers.NewQRCodeHandler()
+	escortService := services.NewEscortService(s.store, s.files)
+	kioskService := services.NewKioskService(s.store, s.config.Kiosks)
+	escortService.SetDuplicatePolicy(s.config.Duplicates)
+	escortService.SetKiosks(kioskService)
+	receiptService := services.NewReceiptService(s.store.Escorts, s.config.Receipts)
+	escortHandler := handlers.NewEscortHandler(escortService, receiptService)
+	receiptHandler := handlers.NewReceiptHandler(receiptService)
+	kioskHandler := handlers.NewKioskHandler(kioskService)
+	qrHandler := handlers.NewQRCodeHandler(s.config.Kiosks.FormURL)
+	rejectionReasonHandler := handlers.NewRejectionReasonHandler(services.NewRejectionReasonService(s.store.RejectionReasons))
+	exportHandler := handlers.NewExportHandler(services.NewExportService(s.store.Escorts))
+	streamHandler := handlers.NewStreamHandler(s.feed, s.config.StreamHeartbeat)
+	userHandler := handlers.NewUserHandler(services.NewUserService(s.store.Users))
+	webhookHandler := handlers.NewWebhookHandler(services.NewWebhookService(s.store.Webhooks))
+	storageHandler := handlers.NewStorageHandler(services.NewStorageScanner(s.store, s.files, s.config.StorageOrphanGrace))
+	idempotencyService := services.NewIdempotencyService(s.store.Idempotency, s.config.IdempotencyTTL)
+	authService := services.NewAuthService(s.store.Tokens, s.store.Users, s.config.SanctumExpiration)
+	healthHandler := s.healthHandler()
+
+	// Liveness and readiness probes for orchestrators and load balancers
+	s.router.GET("/livez", healthHandler.Live)
+	s.router.GET("/readyz", healthHandler.Ready)
 
 	// API routes
 	api := s.router.Group("/api")
 	{
-		// Health check endpoint
-		api.GET("/health", s.healthCheck)
+		// Health check endpoint (the readiness report in the format Laravel reads)
+		api.GET("/health", healthHandler.Health)
 		api.GET("/db-test", s.dbTest)
 
-		// HIGH PRIORITY - Core Escort API Endpoints (from migration guide)
-		api.GET("/escort", escortHandler.GetEscorts)          // List escorts with filtering/pagination
-		api.POST("/escort", escortHandler.CreateEscort)       // Create new escort record
-		api.GET("/escort/:id", escortHandler.GetEscort)       // Get single escort record
-		api.PUT("/escort/:id", escortHandler.UpdateEscort)    // Update escort record
-		api.PATCH("/escort/:id", escortHandler.UpdateEscort)  // Update escort record
-		api.DELETE("/escort/:id", escortHandler.DeleteEscort) // Delete escort record
-
-		// Status Management
-		api.PATCH("/escort/:id/status", escortHandler.UpdateEscortStatus) // Update escort status
-
-		// Dashboard Statistics
-		api.GET("/dashboard/stats", escortHandler.GetDashboardStats) // Get dashboard statistics
-		api.GET("/session-stats", escortHandler.GetDashboardStats)   // Get session statistics (same as dashboard)
-
-		// MEDIUM PRIORITY - Image Management Endpoints
-		api.GET("/escort/:id/image/base64", escortHandler.GetImageBase64)     // Get image as base64
-		api.POST("/escort/:id/image/base64", escortHandler.UploadImageBase64) // Upload image as base64
+		// Rate limits: public endpoints per client IP (form submissions from a kiosk QR code per
+		// kiosk location), protected endpoints per access token
+		submitLimit := middleware.RateLimiter(s.limiter, s.config.RateLimits.Submit)
+		qrLimit := middleware.RateLimiter(s.limiter, s.config.RateLimits.QRCode)
+		publicLimit := middleware.RateLimiter(s.limiter, s.config.RateLimits.Public)
+
+		// Public endpoints (escort form submissions, mirrors Laravel's public routes)
+		// The submission body is read once, capped, before the limiter and Idempotency look at it.
+		// Staff tokens are optional and only add the original's details to a duplicate's 409;
+		// they are checked after the limiter, which keeps counting per IP or kiosk.
+		submitBody := middleware.BodyLimit(middleware.MaxRequestBody)
+		optionalAuth := middleware.OptionalSanctumAuth(authService)
+		idempotent := middleware.Idempotency(idempotencyService)
+		api.POST("/escort", submitBody, submitLimit, optionalAuth, idempotent, escortHandler.CreateEscort) // Create new escort record (retries with an Idempotency-Key are replayed)
+		api.GET("/session-stats", publicLimit, escortHandler.GetDashboardStats)                            // Get session statistics (same as dashboard)
+		api.GET("/receipt/:token", publicLimit, receiptHandler.GetReceipt)                                 // Masked submission status behind a receipt link
 
 		// QR Code Generation
-		api.GET("/qr-code/form", qrHandler.GenerateQRCode)      // Generate QR code for form
-		api.POST("/qr-code/form", qrHandler.GenerateQRCodeJSON) // Generate QR code as JSON
+		api.GET("/qr-code/form", qrLimit, qrHandler.GenerateQRCode)      // Generate QR code for form
+		api.POST("/qr-code/form", qrLimit, qrHandler.GenerateQRCodeJSON) // Generate QR code as JSON
 
-		// Legacy user endpoints (for compatibility)
-		v1 := api.Group("/v1")
+		// Protected endpoints - require a Sanctum personal access token (same as Laravel's auth:sanctum group)
+		// and a role granting the permission listed next to each route
+		protected := api.Group("")
+		protected.Use(middleware.SanctumAuth(authService), middleware.RateLimiter(s.limiter, s.config.RateLimits.API))
 		{
-			v1.GET("/users", s.getUsers)
-			v1.POST("/users", s.createUser)
-			v1.GET("/users/:id", s.getUser)
-			v1.PUT("/users/:id", s.updateUser)
-			v1.DELETE("/users/:id", s.deleteUser)
+			canView := middleware.RequirePermission(models.PermissionEscortView)
+			canUpdate := middleware.RequirePermission(models.PermissionEscortUpdate)
+			canVerify := middleware.RequirePermission(models.PermissionEscortVerify)
+			canDelete := middleware.RequirePermission(models.PermissionEscortDelete)
+
+			// HIGH PRIORITY - Core Escort API Endpoints (from migration guide)
+			protected.GET("/escort", canView, escortHandler.GetEscorts)            // List escorts with filtering/pagination
+			protected.GET("/escort/export", canView, exportHandler.ExportEscorts)  // Download escorts as CSV or XLSX
+			protected.GET("/escort/stream", canView, streamHandler.StreamEscorts)  // Real-time escort events (SSE)
+			protected.GET("/escort/:id", canView, escortHandler.GetEscort)         // Get single escort record
+			protected.PUT("/escort/:id", canUpdate, escortHandler.UpdateEscort)    // Update escort record
+			protected.PATCH("/escort/:id", canUpdate, escortHandler.UpdateEscort)  // Update escort record
+			protected.DELETE("/escort/:id", canDelete, escortHandler.DeleteEscort) // Delete escort record
+
+			// Status Management
+			protected.PATCH("/escort/:id/status", canVerify, escortHandler.UpdateEscortStatus) // Update escort status
+			protected.GET("/escort/:id/history", canView, escortHandler.GetEscortHistory)      // Get status timeline
+
+			// Ticket lookup at the desk (e.g. IGD-20261016-7K3Q)
+			protected.GET("/escort/by-submission/:submission_id", canView, escortHandler.GetEscortBySubmission)
+
+			// Managed list of rejection reasons
+			canManageReasons := middleware.RequirePermission(models.PermissionReasonsManage)
+			protected.GET("/rejection-reasons", canView, rejectionReasonHandler.GetReasons)
+			protected.POST("/rejection-reasons", canManageReasons, rejectionReasonHandler.CreateReason)
+			protected.PUT("/rejection-reasons/:code", canManageReasons, rejectionReasonHandler.UpdateReason)
+			protected.DELETE("/rejection-reasons/:code", canManageReasons, rejectionReasonHandler.DeleteReason)
+
+			// Kiosk locations and their signed form QR codes
+			canManageKiosks := middleware.RequirePermission(models.PermissionKiosksManage)
+			protected.GET("/kiosk-locations", canView, kioskHandler.GetLocations)
+			protected.POST("/kiosk-locations", canManageKiosks, kioskHandler.CreateLocation)
+			protected.PUT("/kiosk-locations/:code", canManageKiosks, kioskHandler.UpdateLocation)
+			protected.DELETE("/kiosk-locations/:code", canManageKiosks, kioskHandler.DeleteLocation)
+			protected.GET("/kiosk-locations/:code/qr", canManageKiosks, kioskHandler.GetQRCode)      // QR code PNG for printing
+			protected.POST("/kiosk-locations/:code/qr", canManageKiosks, kioskHandler.GetQRCodeJSON) // QR code as JSON with its token
+
+			// Dashboard Statistics
+			protected.GET("/dashboard/stats", middleware.RequirePermission(models.PermissionDashboardView), escortHandler.GetDashboardStats) // Get dashboard statistics
+
+			// Submissions per kiosk location
+			protected.GET("/reports/kiosk-locations", middleware.RequirePermission(models.PermissionDashboardView), kioskHandler.GetLocationReport)
+
+			// MEDIUM PRIORITY - Image Management Endpoints
+			protected.GET("/escort/:id/image", canView, escortHandler.GetImage)                    // Stream image bytes (Range, ETag)
+			protected.HEAD("/escort/:id/image", canView, escortHandler.GetImage)                   // Image headers only
+			protected.POST("/escort/:id/image", canUpdate, escortHandler.UploadImage)              // Upload image as multipart/form-data
+			protected.GET("/escort/:id/image/base64", canView, escortHandler.GetImageBase64)       // Get image as base64
+			protected.POST("/escort/:id/image/base64", canUpdate, escortHandler.UploadImageBase64) // Upload image as base64
+
+			// Outgoing webhooks and their delivery log
+			webhooks := protected.Group("/webhooks")
+			webhooks.Use(middleware.RequirePermission(models.PermissionWebhooksManage))
+			{
+				webhooks.GET("", webhookHandler.GetWebhooks)
+				webhooks.POST("", webhookHandler.CreateWebhook)
+				webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+				webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+				webhooks.GET("/deliveries", webhookHandler.GetDeliveries)
+				webhooks.GET("/dead-letters", webhookHandler.GetDeadLetters)
+				webhooks.POST("/deliveries/:id/redeliver", webhookHandler.RedeliverDelivery)
+			}
+
+			// Photo storage consistency
+			protected.GET("/storage/scan", middleware.RequirePermission(models.PermissionStorageManage), storageHandler.GetLatestScan)
+
+			// Legacy user endpoints (for compatibility)
+			v1 := protected.Group("/v1")
+			v1.Use(middleware.RequirePermission(models.PermissionUsersManage))
+			{
+				v1.GET("/users", userHandler.GetUsers)
+				v1.POST("/users", userHandler.CreateUser)
+				v1.GET("/users/:id", userHandler.GetUser)
+				v1.PUT("/users/:id", userHandler.UpdateUser)
+				v1.DELETE("/users/:id", userHandler.DeleteUser)
+			}
 		}
 	}
 
+	// Prometheus metrics and readiness details; on the API port only behind METRICS_TOKEN
+	if s.config.MetricsAddr == "" && s.config.MetricsToken != "" {
+		s.setupOpsRoutes(s.router)
+	}
+
 	// Root endpoint
 	s.router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message":     "Pendataan IGD - Go API Server",
 			"status":      "running",
-			"version":     "1.0.0",
+			"version":     version,
 			"description": "Laravel to Golang Migration - Phase 1: Core CRUD Operations",
 			"endpoints": gin.H{
 				"escorts":   "/api/escort",
+				"stream":    "/api/escort/stream",
 				"dashboard": "/api/dashboard/stats",
 				"qr_codes":  "/api/qr-code/form",
 				"health":    "/api/health",
+				"liveness":  "/livez",
+				"readiness": "/readyz",
 			},
 		})
 	})
 }
 
-// Health check handler
-func (s *Server) healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"database":  "connected",
-		"timestamp": fmt.Sprintf("%v", context.Background()),
-	})
+// setupOpsRoutes adds the operator endpoints to router, behind METRICS_TOKEN when it is set
+func (s *Server) setupOpsRoutes(router *gin.Engine) {
+	ops := router.Group("", middleware.OpsAuth(s.config.MetricsToken))
+	ops.GET("/metrics", middleware.MetricsHandler())
+	ops.GET("/readyz/details", s.healthHandler().ReadyDetails)
 }
 
-// Database test handler
-func (s *Server) dbTest(c *gin.Context) {
-	var result int
-	err := s.db.QueryRow(context.Background(), "SELECT 1").Scan(&result)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database query failed",
-			"details": err.Error(),
-		})
-		return
+// healthHandler returns the probes handler. The API and the metrics server share it, and
+// with it the cached check results.
+func (s *Server) healthHandler() *handlers.HealthHandler {
+	if s.health == nil {
+		health := services.NewHealthService(buildInfo(), s.config.HealthCheckTimeout, s.config.HealthCheckCacheTTL, s.healthChecks()...)
+		s.health = handlers.NewHealthHandler(health)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Database connection successful",
-		"result":  result,
-	})
+	return s.health
 }
 
-// User handlers (Laravel-compatible structure)
-func (s *Server) getUsers(c *gin.Context) {
-	rows, err := s.db.Query(context.Background(), "SELECT id, name, email, created_at FROM users ORDER BY id")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// healthChecks lists the dependencies /readyz checks. The schema can only be checked on
+// PostgreSQL; the memory store has none.
+func (s *Server) healthChecks() []services.HealthCheck {
+	checks := []services.HealthCheck{
+		services.DatabaseReachable(s.store),
+		services.StorageWritable(s.files),
 	}
-	defer rows.Close()
-
-	var users []map[string]interface{}
-	for rows.Next() {
-		var id int
-		var name, email string
-		var createdAt interface{}
-
-		err := rows.Scan(&id, &name, &email, &createdAt)
+	if s.db != nil {
+		migrator, err := database.NewMigrator(s.db)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			checks = append(checks, services.HealthCheck{
+				Name:  services.HealthCheckMigrations,
+				Check: func(context.Context) error { return err },
+			})
+		} else {
+			checks = append(checks, services.MigrationsApplied(migrator))
 		}
-
-		users = append(users, map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"email":      email,
-			"created_at": createdAt,
-		})
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": users,
-	})
+	return checks
 }
 
-func (s *Server) createUser(c *gin.Context) {
-	var user struct {
-		Name     string `json:"name" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
-	}
-
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	var userID int
-	err := s.db.QueryRow(context.Background(),
-		"INSERT INTO users (name, email, password, created_at, updated_at) VALUES ($1, $2, $3, NOW(), NOW()) RETURNING id",
-		user.Name, user.Email, user.Password).Scan(&userID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// Database test handler
+func (s *Server) dbTest(c *gin.Context) {
+	if err := s.store.Ping(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database query failed",
+			"details": err.Error(),
+		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User created successfully",
-		"id":      userID,
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Database connection successful",
+		"result":  1,
 	})
 }
 
-func (s *Server) getUser(c *gin.Context) {
-	id := c.Param("id")
-
-	var user struct {
-		ID        int    `json:"id"`
-		Name      string `json:"name"`
-		Email     string `json:"email"`
-		CreatedAt string `json:"created_at"`
-	}
-
-	err := s.db.QueryRow(context.Background(),
-		"SELECT id, name, email, created_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
-
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"data": user})
-}
-
-func (s *Server) updateUser(c *gin.Context) {
-	id := c.Param("id")
-
-	var user struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}
-
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	_, err := s.db.Exec(context.Background(),
-		"UPDATE users SET name = $1, email = $2, updated_at = NOW() WHERE id = $3",
-		user.Name, user.Email, id)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
-}
-
-func (s *Server) deleteUser(c *gin.Context) {
-	id := c.Param("id")
-
-	_, err := s.db.Exec(context.Background(), "DELETE FROM users WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
-}
-
 func main() {
-	// Load configuration
-	config, err := loadConfig()
-	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+	if err := runCommand(os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
-
-	// Initialize server
-	server := &Server{
-		router: gin.Default(),
-	}
-
-	// Connect to database
-	if err := server.connectDatabase(config); err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer server.db.Close()
-
-	// Setup routes
-	server.setupRoutes()
-
-	// Start server
-	port := getEnv("PORT", "8080")
-	log.Printf("Starting server on port %s", port)
-	log.Printf("Environment: %s", config.AppEnv)
-	log.Fatal(server.router.Run(":" + port))
 }
@@ -0,0 +1,109 @@
+// Package metrics defines the Prometheus metrics of the server. They are registered on
+// Registry, which GET /metrics exposes, and updated by the middleware and services.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "goserver"
+
+// Registry holds every metric of the server, next to the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts handled requests by method, route template and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency by method, route template and status
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method, route and status.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestsInFlight is the number of requests being handled, including open streams
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being handled.",
+	})
+
+	// EscortsCreated counts stored escort submissions by kategori_pengantar
+	EscortsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "escorts_created_total",
+		Help:      "Escorts created, by kategori_pengantar.",
+	}, []string{"kategori_pengantar"})
+
+	// EscortStatusTransitions counts escort status changes
+	EscortStatusTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "escort_status_transitions_total",
+		Help:      "Escort status changes, by previous and new status.",
+	}, []string{"from", "to"})
+
+	// QRCodesGenerated counts generated QR codes by kind: form, kiosk or receipt
+	QRCodesGenerated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "qr_codes_generated_total",
+		Help:      "QR codes generated, by kind (form, kiosk, receipt).",
+	}, []string{"kind"})
+
+	// StorageBytes is the size of the photo storage by area, as of the last storage scan
+	StorageBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_bytes",
+		Help:      "Bytes in photo storage as of the last storage scan, by area (photos, staging, quarantine).",
+	}, []string{"area"})
+
+	// StorageFiles is the number of files in photo storage by area, as of the last storage scan
+	StorageFiles = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_files",
+		Help:      "Files in photo storage as of the last storage scan, by area (photos, staging, quarantine).",
+	}, []string{"area"})
+
+	// StorageScanTimestamp is when the last storage scan finished
+	StorageScanTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_scan_timestamp_seconds",
+		Help:      "Unix time the last storage scan finished.",
+	})
+)
+
+// QR code kinds counted by QRCodesGenerated
+const (
+	QRCodeForm    = "form"
+	QRCodeKiosk   = "kiosk"
+	QRCodeReceipt = "receipt"
+)
+
+// Storage areas of StorageBytes and StorageFiles
+const (
+	AreaPhotos     = "photos"
+	AreaStaging    = "staging"
+	AreaQuarantine = "quarantine"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		EscortsCreated,
+		EscortStatusTransitions,
+		QRCodesGenerated,
+		StorageBytes,
+		StorageFiles,
+		StorageScanTimestamp,
+	)
+}
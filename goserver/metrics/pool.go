@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads the statistics of a pgx connection pool at scrape time
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired, idle, constructing, total, max       *prometheus.Desc
+	acquires, emptyAcquires, canceledAcquires      *prometheus.Desc
+	acquireDuration, emptyAcquireWait              *prometheus.Desc
+	newConns, maxLifetimeDestroys, maxIdleDestroys *prometheus.Desc
+}
+
+// RegisterPool adds the statistics of pool to Registry
+func RegisterPool(pool *pgxpool.Pool) error {
+	return Registry.Register(newPoolCollector(pool))
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		pool:                pool,
+		acquired:            desc("acquired_connections", "Connections currently in use."),
+		idle:                desc("idle_connections", "Idle connections in the pool."),
+		constructing:        desc("constructing_connections", "Connections being opened."),
+		total:               desc("total_connections", "Connections in the pool."),
+		max:                 desc("max_connections", "Maximum size of the pool."),
+		acquires:            desc("acquires_total", "Connections acquired from the pool."),
+		emptyAcquires:       desc("empty_acquires_total", "Acquires that had to wait for a connection because none was idle."),
+		canceledAcquires:    desc("canceled_acquires_total", "Acquires canceled by their context while waiting."),
+		acquireDuration:     desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquireWait:    desc("empty_acquire_wait_seconds_total", "Time spent waiting for a connection when none was idle."),
+		newConns:            desc("new_connections_total", "Connections opened."),
+		maxLifetimeDestroys: desc("max_lifetime_destroys_total", "Connections closed for exceeding their maximum lifetime."),
+		maxIdleDestroys:     desc("max_idle_destroys_total", "Connections closed for exceeding their maximum idle time."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(c.acquired, float64(stat.AcquiredConns()))
+	gauge(c.idle, float64(stat.IdleConns()))
+	gauge(c.constructing, float64(stat.ConstructingConns()))
+	gauge(c.total, float64(stat.TotalConns()))
+	gauge(c.max, float64(stat.MaxConns()))
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.emptyAcquireWait, stat.EmptyAcquireWaitTime().Seconds())
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.maxLifetimeDestroys, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleDestroys, float64(stat.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authUserKey  = "auth_user"
+	authTokenKey = "auth_token"
+)
+
+// SanctumAuth authenticates requests carrying a Laravel Sanctum personal access token
+// in the `Authorization: Bearer <id>|<token>` header
+func SanctumAuth(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plainToken, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortUnauthenticated(c)
+			return
+		}
+
+		user, token, err := authService.AuthenticateToken(c.Request.Context(), plainToken)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenExpired) {
+				abortUnauthenticated(c)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+				Status:  "error",
+				Message: "Failed to authenticate request",
+				Errors:  err.Error(),
+			})
+			return
+		}
+
+		c.Set(authUserKey, user)
+		c.Set(authTokenKey, token)
+		c.Next()
+	}
+}
+
+// OptionalSanctumAuth authenticates requests carrying a valid Sanctum token like SanctumAuth,
+// and lets every other request through anonymously. Public routes use it to show staff more.
+func OptionalSanctumAuth(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if plainToken, ok := bearerToken(c.GetHeader("Authorization")); ok {
+			if user, token, err := authService.AuthenticateToken(c.Request.Context(), plainToken); err == nil {
+				c.Set(authUserKey, user)
+				c.Set(authTokenKey, token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireAbility rejects requests whose token does not grant the given Sanctum ability
+func RequireAbility(ability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := CurrentToken(c)
+		if !ok || !token.Can(ability) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Status:  "error",
+				Message: "This action is unauthorized.",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose user role, or token abilities, do not grant the permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			abortUnauthenticated(c)
+			return
+		}
+
+		token, _ := CurrentToken(c)
+		if !user.Can(permission) || token == nil || !token.Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Status:  "error",
+				Message: "You do not have permission to perform this action",
+				Errors: gin.H{
+					"permission": permission,
+					"role":       user.Role,
+				},
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the request was authenticated with a user role and token
+// that both grant the permission
+func HasPermission(c *gin.Context, permission string) bool {
+	user, ok := CurrentUser(c)
+	token, _ := CurrentToken(c)
+	return ok && token != nil && user.Can(permission) && token.Can(permission)
+}
+
+// CurrentUser returns the user authenticated by SanctumAuth
+func CurrentUser(c *gin.Context) (*models.AuthUser, bool) {
+	value, exists := c.Get(authUserKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*models.AuthUser)
+	return user, ok
+}
+
+// CurrentToken returns the access token authenticated by SanctumAuth
+func CurrentToken(c *gin.Context) (*models.PersonalAccessToken, bool) {
+	value, exists := c.Get(authTokenKey)
+	if !exists {
+		return nil, false
+	}
+	token, ok := value.(*models.PersonalAccessToken)
+	return token, ok
+}
+
+// bearerToken extracts the token from an Authorization header value
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// abortUnauthenticated responds with the same message Laravel uses for auth:sanctum failures
+func abortUnauthenticated(c *gin.Context) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+		Status:  "error",
+		Message: "Unauthenticated.",
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"goserver/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxRequestBody bounds a JSON escort submission: a 2MB photo as base64 (about 2.7MB) plus
+// the rest of the form
+const MaxRequestBody = 3 * 1024 * 1024
+
+// bufferedBodyKey is the context key of the request body read by bufferBody
+const bufferedBodyKey = "buffered_body"
+
+// BodyLimit reads the request body up front, refusing bodies over limit bytes with 413. The
+// body is kept in memory, so the middlewares that look at it after this one do not read it again.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := bufferBody(c, limit); err != nil {
+			abortBodyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// bufferBody returns the request body, reading at most limit bytes of it the first time it is
+// called for a request. c.Request.Body is reset to the start of the body, so the handler can
+// still read all of it.
+func bufferBody(c *gin.Context, limit int64) ([]byte, error) {
+	body, ok := c.Get(bufferedBodyKey)
+	if !ok {
+		data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		if err != nil {
+			return nil, err
+		}
+		c.Set(bufferedBodyKey, data)
+		body = data
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body.([]byte)))
+	return body.([]byte), nil
+}
+
+// abortBodyError answers 413 for a body cut off by bufferBody's limit and 400 for any other
+// read error
+func abortBodyError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+			Status:  "error",
+			Message: "Request body too large",
+			Errors:  gin.H{"limit_bytes": tooLarge.Limit},
+		})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, models.APIResponse{
+		Status:  "error",
+		Message: "Failed to read request body",
+		Errors:  err.Error(),
+	})
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idempotency headers. Responses replayed for a retried request carry IdempotentReplayedHeader.
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyRetryAfterSecs = "1"
+)
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key header. Requests without the header are handled as usual. Server errors
+// (5xx) are not stored, so the client can retry them. Bodies over MaxRequestBody get 413.
+func Idempotency(service *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		// The body is fingerprinted, so it is read here; behind BodyLimit it is read only once
+		body, err := bufferBody(c, MaxRequestBody)
+		if err != nil {
+			abortBodyError(c, err)
+			return
+		}
+
+		scope := c.Request.Method + " " + c.FullPath()
+		stored, err := service.Begin(c.Request.Context(), scope, key, body)
+		if err != nil {
+			abortIdempotencyError(c, err)
+			return
+		}
+		if stored != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(*stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The response is sent already; a client that hung up will retry
+		ctx := context.WithoutCancel(c.Request.Context())
+		if status := recorder.Status(); status >= http.StatusInternalServerError {
+			err = service.Release(ctx, scope, key)
+		} else {
+			err = service.Complete(ctx, scope, key, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+func abortIdempotencyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidIdempotencyKey):
+		c.AbortWithStatusJSON(http.StatusBadRequest, models.APIResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+		c.Header("Retry-After", idempotencyRetryAfterSecs)
+		c.AbortWithStatusJSON(http.StatusConflict, models.APIResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+			Status:  "error",
+			Message: "Failed to check Idempotency-Key",
+			Errors:  err.Error(),
+		})
+	}
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
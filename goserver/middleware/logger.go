@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"goserver/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by the client (such as Laravel's
+// GoApiService) is kept, otherwise one is generated; either way it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs taken from clients
+const maxRequestIDLength = 128
+
+// RequestLogger gives each request an ID, puts it in the request context for the handlers
+// and services, and logs the request once it is done: route template, status, latency,
+// user and the database queries made for it. Server errors are logged at error level and
+// client errors at warn level; passing /livez and /readyz probes only at debug level.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		ctx, queries := logging.WithQueryStats(logging.WithRequestID(c.Request.Context(), id))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", logging.Milliseconds(time.Since(start))),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int64("db_queries", queries.Count()),
+			slog.Float64("db_ms", logging.Milliseconds(queries.Duration())),
+		}
+		if c.FullPath() == "" {
+			// No route matched, so there is no template to log instead of the path
+			attrs = append(attrs, slog.String("path", c.Request.URL.Path))
+		}
+		if token, ok := CurrentToken(c); ok {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(token.TokenableID)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case isProbe(c.FullPath()):
+			level = slog.LevelDebug
+		}
+		slog.LogAttrs(ctx, level, "HTTP request", attrs...)
+	}
+}
+
+// isProbe reports whether route is polled by an orchestrator or load balancer
+func isProbe(route string) bool {
+	return route == "/livez" || route == "/readyz"
+}
+
+// validRequestID accepts IDs of letters, digits and -_.: up to maxRequestIDLength
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes in hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
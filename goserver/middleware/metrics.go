@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"goserver/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests no route matched, so arbitrary paths do not become label values
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and observes their latency per method, route template and status
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// OpsAuth guards the operator endpoints (/metrics, /readyz/details): with a token, requests
+// must send it as a bearer token. Without one they are let through, for the METRICS_ADDR
+// server that only operators can reach.
+func OpsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			sent, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				abortUnauthenticated(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// MetricsHandler serves metrics.Registry in the Prometheus exposition format
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
@@ -1,39 +1,30 @@
 package middleware
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+
+	"goserver/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RateLimiter creates a simple rate limiting middleware
-func RateLimiter() gin.HandlerFunc {
-	// This is a simple implementation - in production use Redis or similar
-	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Next()
-	})
-}
-
-// RequestLogger logs API requests with additional context
-func RequestLogger() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Next()
-		// Custom logging can be implemented here
-		// In production, consider using a structured logger like logrus or zap
-	}
-}
-
-// ErrorHandler handles panics and errors gracefully
+// ErrorHandler recovers from panics in later handlers, logs them with their stack and
+// responds with 500
 func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"status":  "error",
-					"message": "Internal server error",
-					"error":   "Something went wrong",
+				slog.ErrorContext(c.Request.Context(), "Panic while handling request",
+					"panic", fmt.Sprint(err),
+					"stack", string(debug.Stack()),
+				)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+					Status:  "error",
+					Message: "Internal server error",
 				})
-				c.Abort()
 			}
 		}()
 		c.Next()
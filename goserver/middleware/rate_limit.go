@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goserver/models"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit headers (draft-ietf-httpapi-ratelimit-headers)
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// RateLimiter limits requests under policy. Clients are told apart by their API token when
+// authenticated, by the kiosk location of a kiosk_token in the JSON body together with their
+// IP address, and otherwise by IP address alone. Refused requests get 429 with Retry-After. A nil limiter lets everything
+// through, and so does a limiter whose store fails, so an outage does not take the API down.
+func RateLimiter(limiter *services.RateLimiter, policy services.RatePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		subject, err := rateLimitSubject(c, limiter)
+		if err != nil {
+			abortBodyError(c, err)
+			return
+		}
+
+		decision, err := limiter.Allow(c.Request.Context(), policy, subject)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Rate limiter unavailable, letting request through", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header(RateLimitLimitHeader, strconv.Itoa(decision.Limit))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+		c.Header(RateLimitResetHeader, seconds(decision.Reset))
+		c.Header(RateLimitPolicyHeader, policy.String())
+
+		if !decision.Allowed {
+			c.Header("Retry-After", seconds(decision.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.APIResponse{
+				Status:  "error",
+				Message: "Too many requests, please try again later",
+				Errors: gin.H{
+					"policy":      policy.Name,
+					"retry_after": int(decision.RetryAfter.Seconds()),
+				},
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitSubject identifies the client a request is counted against. It fails only when
+// the request body cannot be read.
+func rateLimitSubject(c *gin.Context, limiter *services.RateLimiter) (string, error) {
+	if token, ok := CurrentToken(c); ok {
+		return "token:" + strconv.FormatUint(uint64(token.ID), 10), nil
+	}
+	kioskToken, err := peekKioskToken(c)
+	if err != nil {
+		return "", err
+	}
+	if subject, ok := limiter.KioskSubject(kioskToken, c.ClientIP()); ok {
+		return subject, nil
+	}
+	return "ip:" + c.ClientIP(), nil
+}
+
+// peekKioskToken reads kiosk_token from a JSON request body of at most MaxRequestBody bytes
+// and puts the body back
+func peekKioskToken(c *gin.Context) (string, error) {
+	if c.Request.Method != http.MethodPost || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return "", nil
+	}
+
+	body, err := bufferBody(c, MaxRequestBody)
+	if err != nil {
+		return "", err
+	}
+
+	var payload struct {
+		KioskToken string `json:"kiosk_token"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return "", nil
+	}
+	return payload.KioskToken, nil
+}
+
+// seconds formats d as whole seconds for a header
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(d.Seconds()))
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthUser represents the authenticated user resolved from a Sanctum token
+type AuthUser struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Can reports whether the user's role grants the given permission
+func (u *AuthUser) Can(permission string) bool {
+	return RoleHasPermission(u.Role, permission)
+}
+
+// PersonalAccessToken mirrors a row of Laravel Sanctum's personal_access_tokens table
+type PersonalAccessToken struct {
+	ID            uint       `json:"id" db:"id"`
+	TokenableType string     `json:"tokenable_type" db:"tokenable_type"`
+	TokenableID   uint       `json:"tokenable_id" db:"tokenable_id"`
+	Name          string     `json:"name" db:"name"`
+	Token         string     `json:"-" db:"token"`
+	Abilities     []string   `json:"abilities" db:"abilities"`
+	LastUsedAt    *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Can reports whether the token grants the given ability, following Sanctum's "*" wildcard rule
+func (t *PersonalAccessToken) Can(ability string) bool {
+	for _, a := range t.Abilities {
+		if a == "*" || a == ability {
+			return true
+		}
+	}
+	return false
+}
@@ -18,6 +18,9 @@ type Escort struct {
 	SubmissionID      *string   `json:"submission_id" db:"submission_id"`
 	SubmittedFromIP   *string   `json:"submitted_from_ip" db:"submitted_from_ip"`
 	APISubmission     bool      `json:"api_submission" db:"api_submission"`
+	RejectionReason   *string   `json:"rejection_reason" db:"rejection_reason"`
+	DuplicateOf       *uint     `json:"duplicate_of" db:"duplicate_of"`   // earlier escort with the same plate, phone and patient
+	LocationCode      *string   `json:"location_code" db:"location_code"` // kiosk location whose QR code was scanned
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -31,7 +34,7 @@ type CreateEscortRequest struct {
 	PlatNomor         string `json:"plat_nomor" validate:"required,min=3,max=20"`
 	NamaPasien        string `json:"nama_pasien" validate:"required,min=3,max=255"`
 	FotoPengantarB64  string `json:"foto_pengantar_base64,omitempty"`
-	Status            string `json:"status" validate:"omitempty,oneof=pending verified rejected"`
+	KioskToken        string `json:"kiosk_token,omitempty" validate:"omitempty,max=512"` // from the kiosk QR code the form was opened with
 }
 
 // UpdateEscortRequest represents the request payload for updating an escort
@@ -47,7 +50,33 @@ type UpdateEscortRequest struct {
 
 // UpdateStatusRequest represents the request payload for updating escort status
 type UpdateStatusRequest struct {
-	Status string `json:"status" validate:"required,oneof=pending verified rejected"`
+	Status     string `json:"status" validate:"required,oneof=pending verified rejected"`
+	ReasonCode string `json:"reason_code,omitempty" validate:"required_if=Status rejected,max=50"`
+	Note       string `json:"note,omitempty" validate:"omitempty,max=1000"`
+}
+
+// StatusChange describes a status transition together with who requested it
+type StatusChange struct {
+	Status     string
+	ReasonCode string
+	Note       string
+	ChangedBy  *uint
+	ClientIP   string
+}
+
+// EscortStatusHistory represents one entry of an escort's status timeline
+type EscortStatusHistory struct {
+	ID            uint      `json:"id" db:"id"`
+	EscortID      uint      `json:"escort_id" db:"escort_id"`
+	OldStatus     *string   `json:"old_status" db:"old_status"`
+	NewStatus     string    `json:"new_status" db:"new_status"`
+	ReasonCode    *string   `json:"reason_code" db:"reason_code"`
+	ReasonLabel   *string   `json:"reason_label" db:"reason_label"`
+	ChangedBy     *uint     `json:"changed_by" db:"changed_by"`
+	ChangedByName *string   `json:"changed_by_name" db:"changed_by_name"`
+	Note          *string   `json:"note" db:"note"`
+	ClientIP      *string   `json:"client_ip" db:"client_ip"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 }
 
 // APIResponse represents the standard API response format
@@ -79,6 +108,13 @@ type EscortFilters struct {
 	SortOrder         string `form:"sort_order"`
 }
 
+// ExportFilters represents query filters for escort exports
+type ExportFilters struct {
+	EscortFilters
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+}
+
 // DashboardStats represents dashboard statistics
 type DashboardStats struct {
 	TotalEscorts     int64            `json:"total_escorts"`
@@ -93,6 +129,6 @@ type DashboardStats struct {
 
 // QRCodeRequest represents QR code generation request
 type QRCodeRequest struct {
-	URL  string `json:"url" validate:"required,url"`
-	Size int    `json:"size" validate:"omitempty,min=100,max=1000"`
+	URL  string `json:"url" form:"url" validate:"required,url"`
+	Size int    `json:"size" form:"size" validate:"omitempty,min=100,max=1000"`
 }
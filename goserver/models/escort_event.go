@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// Escort event types pushed on the real-time feed (GET /api/escort/stream)
+const (
+	EscortEventCreated       = "escort.created"
+	EscortEventUpdated       = "escort.updated"
+	EscortEventStatusChanged = "escort.status_changed"
+	EscortEventDeleted       = "escort.deleted"
+)
+
+// EscortEvent describes one change to an escort. IDs come from a database sequence, so they
+// are unique across every server instance and can be used as the SSE Last-Event-ID.
+type EscortEvent struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	EscortID   uint      `json:"escort_id"`
+	OldStatus  *string   `json:"old_status,omitempty"`
+	Escort     *Escort   `json:"escort,omitempty"` // state after the change; nil for deletions
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// Escort statuses stored in escorts.status
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusRejected = "rejected"
+)
+
+// StatusTransitions lists, for each status, the statuses an escort may move to next.
+// This is the single place to change the escort workflow.
+var StatusTransitions = map[string][]string{
+	StatusPending:  {StatusVerified, StatusRejected},
+	StatusVerified: {StatusPending},
+	StatusRejected: {StatusPending},
+}
+
+// StatusDisplayNames are the Indonesian labels shown for each status (EscortModel::getStatusDisplayName)
+var StatusDisplayNames = map[string]string{
+	StatusPending:  "Menunggu",
+	StatusVerified: "Terverifikasi",
+	StatusRejected: "Ditolak",
+}
+
+// StatusDisplayName returns the Indonesian label for a status
+func StatusDisplayName(status string) string {
+	if name, ok := StatusDisplayNames[status]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// AllowedTransitions returns the statuses reachable from the given status
+func AllowedTransitions(from string) []string {
+	allowed := StatusTransitions[from]
+	if allowed == nil {
+		return []string{}
+	}
+	return allowed
+}
+
+// CanTransition reports whether an escort may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, status := range StatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectionReason is an entry of the managed list of reasons for rejecting an escort
+type RejectionReason struct {
+	Code      string    `json:"code" db:"code"`
+	Label     string    `json:"label" db:"label"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateRejectionReasonRequest represents the request payload for adding a rejection reason
+type CreateRejectionReasonRequest struct {
+	Code  string `json:"code" validate:"required,min=2,max=50"`
+	Label string `json:"label" validate:"required,min=3,max=255"`
+}
+
+// UpdateRejectionReasonRequest represents the request payload for updating a rejection reason
+type UpdateRejectionReasonRequest struct {
+	Label  *string `json:"label,omitempty" validate:"omitempty,min=3,max=255"`
+	Active *bool   `json:"active,omitempty"`
+}
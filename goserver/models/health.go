@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// Health statuses of a HealthReport and its components
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// BuildInfo identifies the running build; set at link time with -ldflags
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+// HealthReport is the response of /livez and /readyz
+type HealthReport struct {
+	Status string `json:"status"`
+	BuildInfo
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// Components lists the dependency checks; only /readyz runs them
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+// ComponentHealth is the outcome of checking one dependency
+type ComponentHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyRecord is a request sent with an Idempotency-Key and, once handled, its response
+type IdempotencyRecord struct {
+	Scope       string    `json:"scope" db:"scope"` // method and route, e.g. "POST /api/escort"
+	Key         string    `json:"key" db:"key"`
+	RequestHash string    `json:"request_hash" db:"request_hash"` // hex SHA-256 of the request body
+	StatusCode  *int      `json:"status_code" db:"status_code"`   // nil while the request is in progress
+	ContentType string    `json:"content_type" db:"content_type"`
+	Body        []byte    `json:"-" db:"response_body"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// Completed reports whether the response has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != nil
+}
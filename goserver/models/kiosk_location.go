@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// KioskLocation is a registered place where the escort form QR code is posted
+type KioskLocation struct {
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateKioskLocationRequest represents the request payload for registering a location
+type CreateKioskLocationRequest struct {
+	Code string `json:"code" validate:"required,min=2,max=50"`
+	Name string `json:"name" validate:"required,min=3,max=255"`
+}
+
+// UpdateKioskLocationRequest represents the request payload for updating a location
+type UpdateKioskLocationRequest struct {
+	Name   *string `json:"name,omitempty" validate:"omitempty,min=3,max=255"`
+	Active *bool   `json:"active,omitempty"`
+}
+
+// KioskQRCodeRequest represents the query of a kiosk QR code request
+type KioskQRCodeRequest struct {
+	Size int `json:"size" form:"size" validate:"omitempty,min=100,max=1000"`
+	// Days overrides how long the QR code stays valid
+	Days int `json:"days" form:"days" validate:"omitempty,min=1,max=365"`
+}
+
+// KioskQRCode is a QR code minted for a location
+type KioskQRCode struct {
+	Location  string    `json:"location"`
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	QRCode    string    `json:"qr_code"` // PNG data URL encoding URL
+	PNG       []byte    `json:"-"`
+	Size      int       `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LocationCount counts the escorts submitted from one location
+type LocationCount struct {
+	LocationCode *string `json:"location_code"` // nil for submissions without a kiosk QR code
+	Total        int64   `json:"total"`
+	Pending      int64   `json:"pending"`
+	Verified     int64   `json:"verified"`
+	Rejected     int64   `json:"rejected"`
+}
+
+// LocationReportRow is one line of the submissions-per-location report
+type LocationReportRow struct {
+	LocationCount
+	LocationName string `json:"location_name"`
+	Active       bool   `json:"active"`
+}
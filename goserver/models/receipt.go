@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// ReceiptLink is handed to the submitter with a new escort so they can show it at the desk
+type ReceiptLink struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	QRCode    string    `json:"qr_code"` // PNG data URL encoding the submission ID
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EscortReceipt is the public view of a submission behind a receipt link. Personal data is
+// masked; only the submitter, who knows it already, should recognise it.
+type EscortReceipt struct {
+	SubmissionID      string    `json:"submission_id"`
+	Status            string    `json:"status"`
+	StatusLabel       string    `json:"status_label"`
+	RejectionReason   *string   `json:"rejection_reason"`
+	KategoriPengantar string    `json:"kategori_pengantar"`
+	NamaPengantar     string    `json:"nama_pengantar"`
+	NomorHP           string    `json:"nomor_hp"`
+	PlatNomor         string    `json:"plat_nomor"`
+	NamaPasien        string    `json:"nama_pasien"`
+	SubmittedAt       time.Time `json:"submitted_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
@@ -0,0 +1,71 @@
+package models
+
+// User roles stored in users.role
+const (
+	RoleAdmin      = "admin"
+	RoleSupervisor = "supervisor"
+	RoleIGDStaff   = "igd_staff"
+	RoleSecurity   = "security"
+	RoleAuditor    = "auditor"
+)
+
+// Roles lists every role, from most to least privileged
+var Roles = []string{RoleAdmin, RoleSupervisor, RoleIGDStaff, RoleSecurity, RoleAuditor}
+
+// DefaultRole is assigned to users that have not been given an explicit role
+const DefaultRole = RoleIGDStaff
+
+// Permissions checked by the API. They double as Sanctum token abilities.
+const (
+	PermissionEscortView     = "escort:view"
+	PermissionEscortUpdate   = "escort:update"
+	PermissionEscortVerify   = "escort:verify"
+	PermissionEscortDelete   = "escort:delete"
+	PermissionDashboardView  = "dashboard:view"
+	PermissionUsersManage    = "users:manage"
+	PermissionReasonsManage  = "reasons:manage"
+	PermissionWebhooksManage = "webhooks:manage"
+	PermissionStorageManage  = "storage:manage"
+	PermissionKiosksManage   = "kiosks:manage"
+)
+
+// RolePermissions is the single place where roles are mapped to permissions
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionEscortView, PermissionEscortUpdate, PermissionEscortVerify,
+		PermissionEscortDelete, PermissionDashboardView, PermissionUsersManage,
+		PermissionReasonsManage, PermissionWebhooksManage, PermissionStorageManage,
+		PermissionKiosksManage,
+	},
+	RoleSupervisor: {
+		PermissionEscortView, PermissionEscortUpdate, PermissionEscortVerify,
+		PermissionEscortDelete, PermissionDashboardView, PermissionReasonsManage,
+		PermissionKiosksManage,
+	},
+	RoleIGDStaff: {
+		PermissionEscortView, PermissionEscortUpdate, PermissionEscortVerify,
+		PermissionDashboardView,
+	},
+	RoleSecurity: {
+		PermissionEscortView, PermissionEscortVerify,
+	},
+	RoleAuditor: {
+		PermissionEscortView, PermissionDashboardView,
+	},
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission reports whether role is granted permission
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
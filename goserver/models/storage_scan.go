@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageScanReport is the outcome of one photo storage consistency scan
+type StorageScanReport struct {
+	ID         uint      `json:"id" db:"id"`
+	Storage    string    `json:"storage" db:"storage"`
+	DryRun     bool      `json:"dry_run" db:"dry_run"` // nothing was moved or deleted
+	StartedAt  time.Time `json:"started_at" db:"started_at"`
+	FinishedAt time.Time `json:"finished_at" db:"finished_at"`
+
+	FilesScanned     int `json:"files_scanned"`
+	PhotosReferenced int `json:"photos_referenced"`
+
+	// Orphans are stored files that no escort refers to; they are moved to quarantine
+	Orphans []StoredFile `json:"orphans"`
+	// Missing lists escorts whose photo is in neither the storage nor the quarantine
+	Missing []MissingPhoto `json:"missing"`
+	// Restored are referenced photos found in staging or quarantine and moved into place
+	Restored []string `json:"restored"`
+	// Quarantined lists the files waiting in quarantine for their grace period to end
+	Quarantined []StoredFile `json:"quarantined"`
+	// Purged are quarantined files deleted after the grace period
+	Purged []string `json:"purged"`
+}
+
+// StoredFile is a file found in photo storage
+type StoredFile struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// MissingPhoto is an escort whose photo file does not exist
+type MissingPhoto struct {
+	EscortID      uint   `json:"escort_id"`
+	FotoPengantar string `json:"foto_pengantar"`
+}
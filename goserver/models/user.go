@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// User mirrors a row of Laravel's users table
+type User struct {
+	ID        uint      `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Email     string    `json:"email" db:"email"`
+	Password  string    `json:"-" db:"password"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AuthUser returns the identity attached to authenticated requests
+func (u *User) AuthUser() *AuthUser {
+	return &AuthUser{ID: u.ID, Name: u.Name, Email: u.Email, Role: u.Role}
+}
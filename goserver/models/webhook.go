@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+)
+
+// Webhook event types. Besides the escort feed events, status changes to verified or
+// rejected are also sent as escort.verified / escort.rejected.
+const (
+	WebhookEventVerified = "escort.verified"
+	WebhookEventRejected = "escort.rejected"
+)
+
+// WebhookEvents lists every event type a webhook can subscribe to
+var WebhookEvents = []string{
+	EscortEventCreated, EscortEventUpdated, EscortEventStatusChanged, EscortEventDeleted,
+	WebhookEventVerified, WebhookEventRejected,
+}
+
+// Webhook delivery statuses stored in webhook_deliveries.status
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // attempts exhausted; listed in the dead-letter view
+)
+
+// WebhookSubscription is an external endpoint notified of escort events
+type WebhookSubscription struct {
+	ID          uint      `json:"id" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	Events      []string  `json:"events" db:"events"`
+	Secret      string    `json:"secret,omitempty" db:"secret"` // only returned when the subscription is created
+	Description *string   `json:"description" db:"description"`
+	Active      bool      `json:"active" db:"active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Subscribes reports whether the subscription wants events of the given type
+func (s *WebhookSubscription) Subscribes(eventType string) bool {
+	for _, event := range s.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for one subscription in the outbox
+type WebhookDelivery struct {
+	ID             uint       `json:"id" db:"id"`
+	SubscriptionID uint       `json:"subscription_id" db:"subscription_id"`
+	URL            string     `json:"url" db:"url"` // joined from webhook_subscriptions
+	EventID        int64      `json:"event_id" db:"event_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Payload        string     `json:"payload" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code" db:"last_status_code"`
+	LastError      *string    `json:"last_error" db:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CreateWebhookRequest represents the request payload for adding a webhook subscription
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url,max=2048"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=escort.created escort.updated escort.status_changed escort.deleted escort.verified escort.rejected"`
+	Secret      string   `json:"secret,omitempty" validate:"omitempty,min=16,max=255"` // generated when empty
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+}
+
+// UpdateWebhookRequest represents the request payload for updating a webhook subscription
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url,max=2048"`
+	Events      []string `json:"events,omitempty" validate:"omitempty,min=1,dive,oneof=escort.created escort.updated escort.status_changed escort.deleted escort.verified escort.rejected"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookDeliveryFilters represents query filters for the delivery log
+type WebhookDeliveryFilters struct {
+	Status         string `form:"status"`
+	SubscriptionID uint   `form:"subscription_id"`
+	Page           int    `form:"page"`
+	PerPage        int    `form:"per_page"`
+}
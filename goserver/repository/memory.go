@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"goserver/models"
+)
+
+// memoryTables holds the rows of every in-memory table
+type memoryTables struct {
+	escorts map[uint]models.Escort
+	history []models.EscortStatusHistory
+	users   map[uint]models.User
+	tokens  map[uint]models.PersonalAccessToken
+	reasons map[string]models.RejectionReason
+	kiosks  map[string]models.KioskLocation
+
+	webhooks   map[uint]models.WebhookSubscription
+	deliveries map[uint]models.WebhookDelivery
+
+	storageScans []models.StorageScanReport
+	idempotency  map[idempotencyID]models.IdempotencyRecord
+}
+
+// clone copies the tables so a transaction can be rolled back. Rows are copied by value;
+// their pointer fields are never mutated in place, so sharing them is safe.
+func (t *memoryTables) clone() *memoryTables {
+	return &memoryTables{
+		escorts: maps.Clone(t.escorts),
+		history: slices.Clone(t.history),
+		users:   maps.Clone(t.users),
+		tokens:  maps.Clone(t.tokens),
+		reasons: maps.Clone(t.reasons),
+		kiosks:  maps.Clone(t.kiosks),
+
+		webhooks:   maps.Clone(t.webhooks),
+		deliveries: maps.Clone(t.deliveries),
+
+		storageScans: slices.Clone(t.storageScans),
+		idempotency:  maps.Clone(t.idempotency),
+	}
+}
+
+// memoryDB is the shared state behind an in-memory Store
+type memoryDB struct {
+	mu     sync.RWMutex
+	tables *memoryTables
+	// sequences hand out IDs per table; like PostgreSQL sequences they are not rolled back
+	sequences map[string]uint
+
+	// txMu serialises transactions, which stands in for row locks such as SELECT ... FOR UPDATE
+	txMu sync.Mutex
+
+	// listeners receive published events; listenMu also keeps deliveries in publish order
+	listenMu  sync.Mutex
+	listeners map[*func(models.EscortEvent)]struct{}
+
+	// rateLimits are kept apart from the tables: like in PostgreSQL, taking a token is
+	// never undone by a rollback
+	rateLimits *MemoryRateLimitRepository
+}
+
+func (db *memoryDB) nextID(table string) uint {
+	db.sequences[table]++
+	return db.sequences[table]
+}
+
+// read runs fn with the tables under a read lock
+func (db *memoryDB) read(fn func(t *memoryTables)) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	fn(db.tables)
+}
+
+// write runs fn with the tables under a write lock
+func (db *memoryDB) write(fn func(t *memoryTables) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return fn(db.tables)
+}
+
+// NewMemoryStore creates a Store that keeps everything in memory. It starts with the
+// rejection reasons and kiosk locations seeded by the migrations and is safe for concurrent use.
+// Transactions run one at a time and are rolled back by restoring a snapshot, so writes
+// made outside a transaction while another one rolls back are lost with it.
+func NewMemoryStore() *Store {
+	now := time.Now()
+	db := &memoryDB{
+		tables: &memoryTables{
+			escorts: map[uint]models.Escort{},
+			users:   map[uint]models.User{},
+			tokens:  map[uint]models.PersonalAccessToken{},
+			reasons: map[string]models.RejectionReason{},
+			kiosks:  map[string]models.KioskLocation{},
+
+			webhooks:   map[uint]models.WebhookSubscription{},
+			deliveries: map[uint]models.WebhookDelivery{},
+
+			idempotency: map[idempotencyID]models.IdempotencyRecord{},
+		},
+		sequences:  map[string]uint{},
+		listeners:  map[*func(models.EscortEvent)]struct{}{},
+		rateLimits: NewMemoryRateLimitRepository(),
+	}
+
+	// Same defaults as migration 006_create_rejection_reasons_table
+	for code, label := range map[string]string{
+		"data_tidak_valid": "Data tidak valid",
+		"duplikat":         "Duplikat",
+		"foto_tidak_jelas": "Foto tidak jelas",
+	} {
+		db.tables.reasons[code] = models.RejectionReason{Code: code, Label: label, Active: true, CreatedAt: now, UpdatedAt: now}
+	}
+
+	// Same defaults as migration 012_create_kiosk_locations_table
+	for code, name := range map[string]string{
+		"ambulance_bay": "Area Ambulans",
+		"main_entrance": "Pintu Utama",
+		"police_post":   "Pos Polisi",
+	} {
+		db.tables.kiosks[code] = models.KioskLocation{Code: code, Name: name, Active: true, CreatedAt: now, UpdatedAt: now}
+	}
+
+	return newMemoryStore(&memoryBackend{db: db}, db)
+}
+
+func newMemoryStore(b backend, db *memoryDB) *Store {
+	events := &memoryEventRepository{db: db}
+	if tx, ok := b.(*memoryTxBackend); ok {
+		events.tx = tx
+	}
+
+	return &Store{
+		Escorts:          &memoryEscortRepository{db: db},
+		Users:            &memoryUserRepository{db: db},
+		Tokens:           &memoryTokenRepository{db: db},
+		RejectionReasons: &memoryRejectionReasonRepository{db: db},
+		Locations:        &memoryKioskLocationRepository{db: db},
+		Webhooks:         &memoryWebhookRepository{db: db},
+		StorageScans:     &memoryStorageScanRepository{db: db},
+		Idempotency:      &memoryIdempotencyRepository{db: db},
+		RateLimits:       db.rateLimits,
+		Events:           events,
+		backend:          b,
+	}
+}
+
+type memoryBackend struct {
+	db *memoryDB
+}
+
+func (b *memoryBackend) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	b.db.txMu.Lock()
+	defer b.db.txMu.Unlock()
+
+	b.db.mu.RLock()
+	snapshot := b.db.tables.clone()
+	b.db.mu.RUnlock()
+
+	tx := &memoryTxBackend{db: b.db}
+	if err := fn(newMemoryStore(tx, b.db)); err != nil {
+		b.db.mu.Lock()
+		b.db.tables = snapshot
+		b.db.mu.Unlock()
+		return err
+	}
+
+	// Like NOTIFY, events published by the transaction are delivered once it commits
+	for _, event := range tx.events {
+		b.db.notify(event)
+	}
+	return nil
+}
+
+func (b *memoryBackend) ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (b *memoryBackend) listen(ctx context.Context, fn func(models.EscortEvent)) error {
+	b.db.listenMu.Lock()
+	b.db.listeners[&fn] = struct{}{}
+	b.db.listenMu.Unlock()
+
+	<-ctx.Done()
+
+	b.db.listenMu.Lock()
+	delete(b.db.listeners, &fn)
+	b.db.listenMu.Unlock()
+	return ctx.Err()
+}
+
+// memoryTxBackend joins nested units of work to the enclosing transaction
+type memoryTxBackend struct {
+	db *memoryDB
+	// events are held back until the transaction commits
+	events []models.EscortEvent
+}
+
+func (b *memoryTxBackend) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	return fn(newMemoryStore(b, b.db))
+}
+
+func (b *memoryTxBackend) ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (b *memoryTxBackend) listen(ctx context.Context, fn func(models.EscortEvent)) error {
+	return ErrListenInTransaction
+}
@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryEscortRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryEscortRepository) Create(ctx context.Context, escort *models.Escort) error {
+	return r.db.write(func(t *memoryTables) error {
+		if escort.SubmissionID != nil {
+			for _, existing := range t.escorts {
+				if existing.SubmissionID != nil && *existing.SubmissionID == *escort.SubmissionID {
+					return ErrSubmissionIDTaken
+				}
+			}
+		}
+
+		now := time.Now()
+		escort.ID = r.db.nextID("escorts")
+		escort.CreatedAt = now
+		escort.UpdatedAt = now
+		t.escorts[escort.ID] = *escort
+		return nil
+	})
+}
+
+func (r *memoryEscortRepository) GetBySubmissionID(ctx context.Context, submissionID string) (*models.Escort, error) {
+	var escort models.Escort
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		for _, candidate := range t.escorts {
+			if candidate.SubmissionID != nil && *candidate.SubmissionID == submissionID {
+				escort, found = candidate, true
+				return
+			}
+		}
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &escort, nil
+}
+
+func (r *memoryEscortRepository) FindRecentDuplicate(ctx context.Context, escort models.Escort, since time.Time) (*models.Escort, error) {
+	var latest *models.Escort
+	plat, phone, patient := duplicateKey(escort)
+	r.db.read(func(t *memoryTables) {
+		for _, candidate := range t.escorts {
+			candidatePlat, candidatePhone, candidatePatient := duplicateKey(candidate)
+			if candidatePlat != plat || candidatePhone != phone || candidatePatient != patient ||
+				candidate.CreatedAt.Before(since) {
+				continue
+			}
+			if latest == nil || candidate.CreatedAt.After(latest.CreatedAt) ||
+				(candidate.CreatedAt.Equal(latest.CreatedAt) && candidate.ID > latest.ID) {
+				latest = &candidate
+			}
+		}
+	})
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (r *memoryEscortRepository) List(ctx context.Context, query EscortQuery) ([]models.Escort, int64, error) {
+	escorts := r.matching(query)
+	total := int64(len(escorts))
+
+	offset := min((query.Page-1)*query.PerPage, len(escorts))
+	end := min(offset+query.PerPage, len(escorts))
+	if offset < end {
+		return escorts[offset:end], total, nil
+	}
+	return nil, total, nil
+}
+
+func (r *memoryEscortRepository) Count(ctx context.Context, query EscortQuery) (int64, error) {
+	return int64(len(r.matching(query))), nil
+}
+
+func (r *memoryEscortRepository) Each(ctx context.Context, query EscortQuery, fn func(models.Escort) error) error {
+	for _, escort := range r.matching(query) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(escort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matching returns the escorts selected by query in listing order
+func (r *memoryEscortRepository) matching(query EscortQuery) []models.Escort {
+	var escorts []models.Escort
+	r.db.read(func(t *memoryTables) {
+		for _, escort := range t.escorts {
+			if matchesEscortQuery(escort, query) {
+				escorts = append(escorts, escort)
+			}
+		}
+	})
+	sortEscorts(escorts, query.EscortFilters)
+	return escorts
+}
+
+func matchesEscortQuery(escort models.Escort, query EscortQuery) bool {
+	if query.Status != "" && escort.Status != query.Status {
+		return false
+	}
+	if query.KategoriPengantar != "" && escort.KategoriPengantar != query.KategoriPengantar {
+		return false
+	}
+	if query.JenisKelamin != "" && escort.JenisKelamin != query.JenisKelamin {
+		return false
+	}
+	if query.Search != "" {
+		search := strings.ToLower(query.Search)
+		if !strings.Contains(strings.ToLower(escort.NamaPengantar), search) &&
+			!strings.Contains(strings.ToLower(escort.NamaPasien), search) &&
+			!strings.Contains(strings.ToLower(escort.PlatNomor), search) {
+			return false
+		}
+	}
+	if query.CreatedFrom != nil && escort.CreatedAt.Before(*query.CreatedFrom) {
+		return false
+	}
+	if query.CreatedBefore != nil && !escort.CreatedAt.Before(*query.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// sortEscorts orders escorts the same way buildEscortOrder does, newest ID first on ties
+func sortEscorts(escorts []models.Escort, filters models.EscortFilters) {
+	field := "created_at"
+	descending := true
+	if escortSortFields[filters.SortBy] {
+		field = filters.SortBy
+		descending = filters.SortOrder != "asc"
+	}
+
+	slices.SortFunc(escorts, func(a, b models.Escort) int {
+		var order int
+		switch field {
+		case "id":
+			order = cmp.Compare(a.ID, b.ID)
+		case "status":
+			order = cmp.Compare(a.Status, b.Status)
+		case "kategori_pengantar":
+			order = cmp.Compare(a.KategoriPengantar, b.KategoriPengantar)
+		case "nama_pengantar":
+			order = cmp.Compare(a.NamaPengantar, b.NamaPengantar)
+		case "nama_pasien":
+			order = cmp.Compare(a.NamaPasien, b.NamaPasien)
+		default:
+			order = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if order == 0 {
+			order = cmp.Compare(a.ID, b.ID)
+		}
+		if descending {
+			return -order
+		}
+		return order
+	})
+}
+
+func (r *memoryEscortRepository) GetByID(ctx context.Context, id uint) (*models.Escort, error) {
+	var escort models.Escort
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		escort, found = t.escorts[id]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &escort, nil
+}
+
+func (r *memoryEscortRepository) GetForUpdate(ctx context.Context, id uint) (*models.Escort, error) {
+	// Transactions are serialised by memoryDB.txMu, so a plain read is already exclusive
+	return r.GetByID(ctx, id)
+}
+
+func (r *memoryEscortRepository) Update(ctx context.Context, id uint, update EscortUpdate) error {
+	return r.modify(id, func(escort *models.Escort) {
+		setIfNotNil(&escort.KategoriPengantar, update.KategoriPengantar)
+		setIfNotNil(&escort.NamaPengantar, update.NamaPengantar)
+		setIfNotNil(&escort.JenisKelamin, update.JenisKelamin)
+		setIfNotNil(&escort.NomorHP, update.NomorHP)
+		setIfNotNil(&escort.PlatNomor, update.PlatNomor)
+		setIfNotNil(&escort.NamaPasien, update.NamaPasien)
+		if update.FotoPengantar != nil {
+			foto := *update.FotoPengantar
+			escort.FotoPengantar = &foto
+		}
+	})
+}
+
+func (r *memoryEscortRepository) UpdateStatus(ctx context.Context, id uint, status string, rejectionReason *string) error {
+	return r.modify(id, func(escort *models.Escort) {
+		escort.Status = status
+		escort.RejectionReason = rejectionReason
+	})
+}
+
+// modify applies fn to a stored escort and bumps its updated_at
+func (r *memoryEscortRepository) modify(id uint, fn func(escort *models.Escort)) error {
+	return r.db.write(func(t *memoryTables) error {
+		escort, found := t.escorts[id]
+		if !found {
+			return ErrNotFound
+		}
+		fn(&escort)
+		escort.UpdatedAt = time.Now()
+		t.escorts[id] = escort
+		return nil
+	})
+}
+
+func setIfNotNil(dst *string, value *string) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func (r *memoryEscortRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.write(func(t *memoryTables) error {
+		if _, found := t.escorts[id]; !found {
+			return ErrNotFound
+		}
+		delete(t.escorts, id)
+		return nil
+	})
+}
+
+func (r *memoryEscortRepository) Stats(ctx context.Context) (*models.DashboardStats, error) {
+	stats := &models.DashboardStats{
+		CategoryStats:   make(map[string]int64),
+		StatusBreakdown: make(map[string]int64),
+	}
+
+	escorts := r.matching(EscortQuery{})
+	year, month, day := time.Now().Date()
+	for _, escort := range escorts {
+		stats.TotalEscorts++
+		switch escort.Status {
+		case models.StatusPending:
+			stats.PendingEscorts++
+		case models.StatusVerified:
+			stats.VerifiedEscorts++
+		case models.StatusRejected:
+			stats.RejectedEscorts++
+		}
+		if y, m, d := escort.CreatedAt.Date(); y == year && m == month && d == day {
+			stats.TodaySubmissions++
+		}
+		stats.CategoryStats[escort.KategoriPengantar]++
+		stats.StatusBreakdown[escort.Status]++
+	}
+
+	stats.RecentEscorts = escorts[:min(5, len(escorts))]
+	return stats, nil
+}
+
+func (r *memoryEscortRepository) CountByLocation(ctx context.Context, query EscortQuery) ([]models.LocationCount, error) {
+	byCode := map[string]*models.LocationCount{}
+	var unattributed *models.LocationCount
+	for _, escort := range r.matching(query) {
+		count := unattributed
+		if escort.LocationCode != nil {
+			count = byCode[*escort.LocationCode]
+		}
+		if count == nil {
+			count = &models.LocationCount{LocationCode: escort.LocationCode}
+			if escort.LocationCode != nil {
+				byCode[*escort.LocationCode] = count
+			} else {
+				unattributed = count
+			}
+		}
+
+		count.Total++
+		switch escort.Status {
+		case models.StatusPending:
+			count.Pending++
+		case models.StatusVerified:
+			count.Verified++
+		case models.StatusRejected:
+			count.Rejected++
+		}
+	}
+
+	counts := []models.LocationCount{}
+	for _, code := range slices.Sorted(maps.Keys(byCode)) {
+		counts = append(counts, *byCode[code])
+	}
+	if unattributed != nil {
+		counts = append(counts, *unattributed)
+	}
+	return counts, nil
+}
+
+func (r *memoryEscortRepository) AddStatusHistory(ctx context.Context, entry *models.EscortStatusHistory) error {
+	return r.db.write(func(t *memoryTables) error {
+		entry.ID = r.db.nextID("escort_status_history")
+		entry.CreatedAt = time.Now()
+		t.history = append(t.history, *entry)
+		return nil
+	})
+}
+
+func (r *memoryEscortRepository) StatusHistory(ctx context.Context, escortID uint) ([]models.EscortStatusHistory, error) {
+	history := []models.EscortStatusHistory{}
+	r.db.read(func(t *memoryTables) {
+		// Entries are appended in ID order, which is also creation order
+		for _, entry := range t.history {
+			if entry.EscortID != escortID {
+				continue
+			}
+			if entry.ChangedBy != nil {
+				if user, found := t.users[*entry.ChangedBy]; found {
+					entry.ChangedByName = &user.Name
+				}
+			}
+			if entry.ReasonCode != nil {
+				if reason, found := t.reasons[*entry.ReasonCode]; found {
+					entry.ReasonLabel = &reason.Label
+				}
+			}
+			history = append(history, entry)
+		}
+	})
+	return history, nil
+}
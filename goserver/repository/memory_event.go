@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryEventRepository struct {
+	db *memoryDB
+	tx *memoryTxBackend // nil outside a transaction
+}
+
+func (r *memoryEventRepository) Publish(ctx context.Context, event *models.EscortEvent) error {
+	r.db.write(func(t *memoryTables) error {
+		event.ID = int64(r.db.nextID("escort_events"))
+		return nil
+	})
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if r.tx != nil {
+		r.tx.events = append(r.tx.events, *event)
+		return nil
+	}
+	r.db.notify(*event)
+	return nil
+}
+
+// notify delivers an event to every listener
+func (db *memoryDB) notify(event models.EscortEvent) {
+	db.listenMu.Lock()
+	defer db.listenMu.Unlock()
+	for fn := range db.listeners {
+		(*fn)(event)
+	}
+}
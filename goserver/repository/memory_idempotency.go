@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"goserver/models"
+)
+
+// idempotencyID is the primary key of an idempotency record
+type idempotencyID struct {
+	scope, key string
+}
+
+type memoryIdempotencyRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	var stored models.IdempotencyRecord
+	var reserved bool
+	err := r.db.write(func(t *memoryTables) error {
+		id := idempotencyID{record.Scope, record.Key}
+		existing, found := t.idempotency[id]
+		if found && existing.ExpiresAt.After(record.CreatedAt) &&
+			(existing.Completed() || !existing.CreatedAt.Before(staleBefore)) {
+			stored = existing
+			return nil
+		}
+
+		stored = *record
+		stored.StatusCode, stored.ContentType, stored.Body = nil, "", nil
+		t.idempotency[id] = stored
+		reserved = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return record, true, nil
+	}
+	return &stored, false, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	return r.db.write(func(t *memoryTables) error {
+		id := idempotencyID{scope, key}
+		record, found := t.idempotency[id]
+		if !found {
+			return ErrNotFound
+		}
+		record.StatusCode = &statusCode
+		record.ContentType = contentType
+		record.Body = append([]byte(nil), body...)
+		t.idempotency[id] = record
+		return nil
+	})
+}
+
+func (r *memoryIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	return r.db.write(func(t *memoryTables) error {
+		id := idempotencyID{scope, key}
+		if record, found := t.idempotency[id]; found && !record.Completed() {
+			delete(t.idempotency, id)
+		}
+		return nil
+	})
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.write(func(t *memoryTables) error {
+		for id, record := range t.idempotency {
+			if !record.ExpiresAt.After(now) {
+				delete(t.idempotency, id)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
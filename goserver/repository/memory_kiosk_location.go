@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryKioskLocationRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryKioskLocationRepository) List(ctx context.Context, includeInactive bool) ([]models.KioskLocation, error) {
+	locations := []models.KioskLocation{}
+	r.db.read(func(t *memoryTables) {
+		for _, location := range t.kiosks {
+			if location.Active || includeInactive {
+				locations = append(locations, location)
+			}
+		}
+	})
+	slices.SortFunc(locations, func(a, b models.KioskLocation) int { return cmp.Compare(a.Name, b.Name) })
+	return locations, nil
+}
+
+func (r *memoryKioskLocationRepository) Get(ctx context.Context, code string) (*models.KioskLocation, error) {
+	var location models.KioskLocation
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		location, found = t.kiosks[code]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &location, nil
+}
+
+func (r *memoryKioskLocationRepository) Upsert(ctx context.Context, code, name string) (*models.KioskLocation, error) {
+	var location models.KioskLocation
+	err := r.db.write(func(t *memoryTables) error {
+		now := time.Now()
+		location = t.kiosks[code]
+		if location.Code == "" {
+			location = models.KioskLocation{Code: code, CreatedAt: now}
+		}
+		location.Name = name
+		location.Active = true
+		location.UpdatedAt = now
+		t.kiosks[code] = location
+		return nil
+	})
+	return &location, err
+}
+
+func (r *memoryKioskLocationRepository) Update(ctx context.Context, code string, name *string, active *bool) (*models.KioskLocation, error) {
+	var location models.KioskLocation
+	err := r.db.write(func(t *memoryTables) error {
+		var found bool
+		location, found = t.kiosks[code]
+		if !found {
+			return ErrNotFound
+		}
+		if name != nil {
+			location.Name = *name
+		}
+		if active != nil {
+			location.Active = *active
+		}
+		location.UpdatedAt = time.Now()
+		t.kiosks[code] = location
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
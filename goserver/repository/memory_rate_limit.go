@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateBucket is the state of one token bucket
+type rateBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// MemoryRateLimitRepository keeps token buckets in the memory of one process. It serves the
+// in-memory Store and single-instance deployments that do not want a database round trip
+// per request (RATE_LIMIT_STORE=memory).
+type MemoryRateLimitRepository struct {
+	mu      sync.Mutex
+	buckets map[string]rateBucket
+}
+
+func NewMemoryRateLimitRepository() *MemoryRateLimitRepository {
+	return &MemoryRateLimitRepository{buckets: map[string]rateBucket{}}
+}
+
+func (r *MemoryRateLimitRepository) Take(ctx context.Context, key string, burst, rate float64, now, expiresAt time.Time) (bool, float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket, found := r.buckets[key]
+	if !found {
+		bucket = rateBucket{tokens: burst, updatedAt: now}
+	}
+	if elapsed := now.Sub(bucket.updatedAt); elapsed > 0 {
+		bucket.tokens = min(burst, bucket.tokens+elapsed.Seconds()*rate)
+		bucket.updatedAt = now
+	}
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	bucket.expiresAt = expiresAt
+	r.buckets[key] = bucket
+	return allowed, bucket.tokens, nil
+}
+
+func (r *MemoryRateLimitRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, bucket := range r.buckets {
+		if !bucket.expiresAt.After(now) {
+			delete(r.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryRejectionReasonRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryRejectionReasonRepository) List(ctx context.Context, includeInactive bool) ([]models.RejectionReason, error) {
+	reasons := []models.RejectionReason{}
+	r.db.read(func(t *memoryTables) {
+		for _, reason := range t.reasons {
+			if reason.Active || includeInactive {
+				reasons = append(reasons, reason)
+			}
+		}
+	})
+	slices.SortFunc(reasons, func(a, b models.RejectionReason) int { return cmp.Compare(a.Label, b.Label) })
+	return reasons, nil
+}
+
+func (r *memoryRejectionReasonRepository) Get(ctx context.Context, code string) (*models.RejectionReason, error) {
+	var reason models.RejectionReason
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		reason, found = t.reasons[code]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &reason, nil
+}
+
+func (r *memoryRejectionReasonRepository) Create(ctx context.Context, code, label string) (*models.RejectionReason, error) {
+	var reason models.RejectionReason
+	err := r.db.write(func(t *memoryTables) error {
+		if _, found := t.reasons[code]; found {
+			return ErrAlreadyExists
+		}
+		now := time.Now()
+		reason = models.RejectionReason{Code: code, Label: label, Active: true, CreatedAt: now, UpdatedAt: now}
+		t.reasons[code] = reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reason, nil
+}
+
+func (r *memoryRejectionReasonRepository) Update(ctx context.Context, code string, label *string, active *bool) (*models.RejectionReason, error) {
+	var reason models.RejectionReason
+	err := r.db.write(func(t *memoryTables) error {
+		var found bool
+		reason, found = t.reasons[code]
+		if !found {
+			return ErrNotFound
+		}
+		if label != nil {
+			reason.Label = *label
+		}
+		if active != nil {
+			reason.Active = *active
+		}
+		reason.UpdatedAt = time.Now()
+		t.reasons[code] = reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reason, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"goserver/models"
+)
+
+type memoryStorageScanRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryStorageScanRepository) Save(ctx context.Context, report *models.StorageScanReport) error {
+	return r.db.write(func(t *memoryTables) error {
+		report.ID = r.db.nextID("storage_scans")
+		t.storageScans = append(t.storageScans, *report)
+		return nil
+	})
+}
+
+func (r *memoryStorageScanRepository) Latest(ctx context.Context) (*models.StorageScanReport, error) {
+	var report models.StorageScanReport
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		if len(t.storageScans) > 0 {
+			report, found = t.storageScans[len(t.storageScans)-1], true
+		}
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &report, nil
+}
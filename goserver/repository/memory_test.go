@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"goserver/models"
+)
+
+func TestMemoryStoreWithTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	errAbort := errors.New("abort")
+
+	err := store.WithTx(ctx, func(tx *Store) error {
+		if err := tx.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending}); err != nil {
+			return err
+		}
+		// Nested units of work join the enclosing transaction
+		return tx.WithTx(ctx, func(nested *Store) error {
+			if err := nested.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx error = %v, want %v", err, errAbort)
+	}
+
+	count, _ := store.Escorts.Count(ctx, EscortQuery{})
+	if count != 0 {
+		t.Fatalf("rolled back transaction left %d escorts", count)
+	}
+
+	err = store.WithTx(ctx, func(tx *Store) error {
+		return tx.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _ = store.Escorts.Count(ctx, EscortQuery{})
+	if count != 1 {
+		t.Fatalf("committed transaction left %d escorts, want 1", count)
+	}
+}
+
+func TestMemoryStoreConcurrentStatusUpdates(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	escort := &models.Escort{Status: models.StatusPending}
+	if err := store.Escorts.Create(ctx, escort); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one of the concurrent pending -> verified transitions may see the pending status
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	transitions := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.WithTx(ctx, func(tx *Store) error {
+				current, err := tx.Escorts.GetForUpdate(ctx, escort.ID)
+				if err != nil || current.Status != models.StatusPending {
+					return err
+				}
+				mu.Lock()
+				transitions++
+				mu.Unlock()
+				return tx.Escorts.UpdateStatus(ctx, escort.ID, models.StatusVerified, nil)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if transitions != 1 {
+		t.Fatalf("%d transactions saw the pending status, want 1", transitions)
+	}
+}
+
+func TestMemoryEscortSubmissionIDIsUnique(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	submissionID := "IGD-20261016-7K3Q"
+
+	if err := store.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending, SubmissionID: &submissionID}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	err := store.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending, SubmissionID: &submissionID})
+	if !errors.Is(err, ErrSubmissionIDTaken) {
+		t.Fatalf("second Create error = %v, want %v", err, ErrSubmissionIDTaken)
+	}
+
+	escort, err := store.Escorts.GetBySubmissionID(ctx, submissionID)
+	if err != nil || escort.ID != 1 {
+		t.Fatalf("GetBySubmissionID = %+v, %v; want escort 1", escort, err)
+	}
+	if _, err := store.Escorts.GetBySubmissionID(ctx, "IGD-20261016-0000"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown submission ID error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryUserRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryUserRepository) List(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	r.db.read(func(t *memoryTables) {
+		for _, user := range t.users {
+			users = append(users, user)
+		}
+	})
+	slices.SortFunc(users, func(a, b models.User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		user, found = t.users[id]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		for _, candidate := range t.users {
+			if candidate.Email == email {
+				user, found = candidate, true
+				return
+			}
+		}
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.write(func(t *memoryTables) error {
+		if emailTaken(t, user.Email, 0) {
+			return fmt.Errorf("failed to create user: email %s already exists", user.Email)
+		}
+		now := time.Now()
+		user.ID = r.db.nextID("users")
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		t.users[user.ID] = *user
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	return r.db.write(func(t *memoryTables) error {
+		stored, found := t.users[user.ID]
+		if !found {
+			return ErrNotFound
+		}
+		if emailTaken(t, user.Email, user.ID) {
+			return fmt.Errorf("failed to update user: email %s already exists", user.Email)
+		}
+		stored.Name = user.Name
+		stored.Email = user.Email
+		if user.Role != "" {
+			stored.Role = user.Role
+		}
+		stored.UpdatedAt = time.Now()
+		t.users[user.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) UpdateRole(ctx context.Context, id uint, role, passwordHash string) error {
+	return r.db.write(func(t *memoryTables) error {
+		stored, found := t.users[id]
+		if !found {
+			return ErrNotFound
+		}
+		stored.Role = role
+		if passwordHash != "" {
+			stored.Password = passwordHash
+		}
+		stored.UpdatedAt = time.Now()
+		t.users[id] = stored
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.write(func(t *memoryTables) error {
+		if _, found := t.users[id]; !found {
+			return ErrNotFound
+		}
+		delete(t.users, id)
+		return nil
+	})
+}
+
+// emailTaken mirrors the unique index on users.email
+func emailTaken(t *memoryTables, email string, exceptID uint) bool {
+	for id, user := range t.users {
+		if id != exceptID && user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryTokenRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryTokenRepository) FindByID(ctx context.Context, id uint) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		token, found = t.tokens[id]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (r *memoryTokenRepository) FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		for _, candidate := range t.tokens {
+			if candidate.Token == hash {
+				token, found = candidate, true
+				return
+			}
+		}
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (r *memoryTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return r.db.write(func(t *memoryTables) error {
+		now := time.Now()
+		token.ID = r.db.nextID("personal_access_tokens")
+		token.CreatedAt = now
+		token.UpdatedAt = now
+		t.tokens[token.ID] = *token
+		return nil
+	})
+}
+
+func (r *memoryTokenRepository) Touch(ctx context.Context, id uint) error {
+	return r.db.write(func(t *memoryTables) error {
+		token, found := t.tokens[id]
+		if !found {
+			return nil
+		}
+		now := time.Now()
+		token.LastUsedAt = &now
+		token.UpdatedAt = now
+		t.tokens[id] = token
+		return nil
+	})
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"goserver/models"
+)
+
+type memoryWebhookRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subs := []models.WebhookSubscription{}
+	r.db.read(func(t *memoryTables) {
+		for _, sub := range t.webhooks {
+			subs = append(subs, sub)
+		}
+	})
+	slices.SortFunc(subs, func(a, b models.WebhookSubscription) int { return cmp.Compare(a.ID, b.ID) })
+	return subs, nil
+}
+
+func (r *memoryWebhookRepository) GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var found bool
+	r.db.read(func(t *memoryTables) {
+		sub, found = t.webhooks[id]
+	})
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &sub, nil
+}
+
+func (r *memoryWebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	return r.db.write(func(t *memoryTables) error {
+		now := time.Now()
+		sub.ID = r.db.nextID("webhook_subscriptions")
+		sub.CreatedAt = now
+		sub.UpdatedAt = now
+
+		stored := *sub
+		stored.Events = slices.Clone(sub.Events)
+		t.webhooks[sub.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) UpdateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	return r.db.write(func(t *memoryTables) error {
+		stored, found := t.webhooks[sub.ID]
+		if !found {
+			return ErrNotFound
+		}
+		stored.URL = sub.URL
+		stored.Events = slices.Clone(sub.Events)
+		stored.Description = sub.Description
+		stored.Active = sub.Active
+		stored.UpdatedAt = time.Now()
+		t.webhooks[sub.ID] = stored
+
+		sub.UpdatedAt = stored.UpdatedAt
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) DeleteSubscription(ctx context.Context, id uint) error {
+	return r.db.write(func(t *memoryTables) error {
+		if _, found := t.webhooks[id]; !found {
+			return ErrNotFound
+		}
+		delete(t.webhooks, id)
+
+		// Same as ON DELETE CASCADE
+		for deliveryID, delivery := range t.deliveries {
+			if delivery.SubscriptionID == id {
+				delete(t.deliveries, deliveryID)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) Enqueue(ctx context.Context, eventID int64, eventType string, payload []byte) (int, error) {
+	count := 0
+	err := r.db.write(func(t *memoryTables) error {
+		now := time.Now()
+		for _, sub := range t.webhooks {
+			if !sub.Active || !sub.Subscribes(eventType) {
+				continue
+			}
+			id := r.db.nextID("webhook_deliveries")
+			t.deliveries[id] = models.WebhookDelivery{
+				ID:             id,
+				SubscriptionID: sub.ID,
+				EventID:        eventID,
+				EventType:      eventType,
+				Payload:        string(payload),
+				Status:         models.DeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryWebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	var claimed []ClaimedDelivery
+	err := r.db.write(func(t *memoryTables) error {
+		now := time.Now()
+		var due []models.WebhookDelivery
+		for _, delivery := range t.deliveries {
+			if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+				due = append(due, delivery)
+			}
+		}
+		slices.SortFunc(due, func(a, b models.WebhookDelivery) int {
+			return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+		})
+
+		for _, delivery := range due[:min(limit, len(due))] {
+			delivery.NextAttemptAt = now.Add(lease)
+			delivery.UpdatedAt = now
+			t.deliveries[delivery.ID] = delivery
+
+			sub := t.webhooks[delivery.SubscriptionID]
+			delivery.URL = sub.URL
+			claimed = append(claimed, ClaimedDelivery{WebhookDelivery: delivery, Secret: sub.Secret})
+		}
+		return nil
+	})
+	slices.SortFunc(claimed, func(a, b ClaimedDelivery) int { return cmp.Compare(a.ID, b.ID) })
+	return claimed, err
+}
+
+func (r *memoryWebhookRepository) MarkDelivered(ctx context.Context, id uint, statusCode int) error {
+	return r.updateDelivery(id, func(delivery *models.WebhookDelivery, now time.Time) {
+		delivery.Status = models.DeliveryDelivered
+		delivery.Attempts++
+		delivery.LastStatusCode = &statusCode
+		delivery.LastError = nil
+		delivery.DeliveredAt = &now
+	})
+}
+
+func (r *memoryWebhookRepository) MarkFailed(ctx context.Context, id uint, statusCode *int, message string, retryAt *time.Time) error {
+	return r.updateDelivery(id, func(delivery *models.WebhookDelivery, now time.Time) {
+		delivery.Status = models.DeliveryDead
+		if retryAt != nil {
+			delivery.Status = models.DeliveryPending
+			delivery.NextAttemptAt = *retryAt
+		}
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = &message
+	})
+}
+
+func (r *memoryWebhookRepository) ListDeliveries(ctx context.Context, filters models.WebhookDeliveryFilters) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	r.db.read(func(t *memoryTables) {
+		for _, delivery := range t.deliveries {
+			if filters.Status != "" && delivery.Status != filters.Status {
+				continue
+			}
+			if filters.SubscriptionID != 0 && delivery.SubscriptionID != filters.SubscriptionID {
+				continue
+			}
+			delivery.URL = t.webhooks[delivery.SubscriptionID].URL
+			deliveries = append(deliveries, delivery)
+		}
+	})
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int { return cmp.Compare(b.ID, a.ID) })
+	total := int64(len(deliveries))
+
+	offset := min((filters.Page-1)*filters.PerPage, len(deliveries))
+	end := min(offset+filters.PerPage, len(deliveries))
+	return append([]models.WebhookDelivery{}, deliveries[offset:end]...), total, nil
+}
+
+func (r *memoryWebhookRepository) Redeliver(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	var redelivered models.WebhookDelivery
+	err := r.updateDelivery(id, func(delivery *models.WebhookDelivery, now time.Time) {
+		delivery.Status = models.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = now
+		redelivered = *delivery
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.db.read(func(t *memoryTables) {
+		redelivered.URL = t.webhooks[redelivered.SubscriptionID].URL
+	})
+	return &redelivered, nil
+}
+
+// updateDelivery applies fn to a stored delivery and bumps its updated_at
+func (r *memoryWebhookRepository) updateDelivery(id uint, fn func(delivery *models.WebhookDelivery, now time.Time)) error {
+	return r.db.write(func(t *memoryTables) error {
+		delivery, found := t.deliveries[id]
+		if !found {
+			return ErrNotFound
+		}
+		now := time.Now()
+		fn(&delivery, now)
+		delivery.UpdatedAt = now
+		t.deliveries[id] = delivery
+		return nil
+	})
+}
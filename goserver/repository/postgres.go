@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// querier is the subset of pgxpool.Pool and pgx.Tx used by the PostgreSQL repositories
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// NewPostgresStore creates a Store backed by a PostgreSQL connection pool
+func NewPostgresStore(db *pgxpool.Pool) *Store {
+	return newPostgresStore(&postgresBackend{db: db}, db)
+}
+
+func newPostgresStore(b backend, q querier) *Store {
+	return &Store{
+		Escorts:          &postgresEscortRepository{q: q},
+		Users:            &postgresUserRepository{q: q},
+		Tokens:           &postgresTokenRepository{q: q},
+		RejectionReasons: &postgresRejectionReasonRepository{q: q},
+		Locations:        &postgresKioskLocationRepository{q: q},
+		Webhooks:         &postgresWebhookRepository{q: q},
+		StorageScans:     &postgresStorageScanRepository{q: q},
+		Idempotency:      &postgresIdempotencyRepository{q: q},
+		RateLimits:       &postgresRateLimitRepository{q: q},
+		Events:           &postgresEventRepository{q: q},
+		backend:          b,
+	}
+}
+
+type postgresBackend struct {
+	db *pgxpool.Pool
+}
+
+func (b *postgresBackend) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(newPostgresStore(&postgresTxBackend{tx: tx}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (b *postgresBackend) ping(ctx context.Context) error {
+	var result int
+	return b.db.QueryRow(ctx, "SELECT 1").Scan(&result)
+}
+
+func (b *postgresBackend) listen(ctx context.Context, fn func(models.EscortEvent)) error {
+	return listenEscortEvents(ctx, b.db, fn)
+}
+
+// postgresTxBackend joins nested units of work to the enclosing transaction
+type postgresTxBackend struct {
+	tx pgx.Tx
+}
+
+func (b *postgresTxBackend) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	return fn(newPostgresStore(b, b.tx))
+}
+
+func (b *postgresTxBackend) ping(ctx context.Context) error {
+	var result int
+	return b.tx.QueryRow(ctx, "SELECT 1").Scan(&result)
+}
+
+func (b *postgresTxBackend) listen(ctx context.Context, fn func(models.EscortEvent)) error {
+	return ErrListenInTransaction
+}
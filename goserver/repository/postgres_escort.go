@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const escortColumns = `
+	id, status, kategori_pengantar, nama_pengantar, jenis_kelamin,
+	nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
+	submission_id, submitted_from_ip, api_submission,
+	rejection_reason, duplicate_of, location_code, created_at, updated_at
+`
+
+type postgresEscortRepository struct {
+	q querier
+}
+
+func scanEscort(row pgx.Row) (*models.Escort, error) {
+	var escort models.Escort
+	err := row.Scan(
+		&escort.ID, &escort.Status, &escort.KategoriPengantar,
+		&escort.NamaPengantar, &escort.JenisKelamin, &escort.NomorHP,
+		&escort.PlatNomor, &escort.NamaPasien, &escort.FotoPengantar,
+		&escort.SubmissionID, &escort.SubmittedFromIP, &escort.APISubmission,
+		&escort.RejectionReason, &escort.DuplicateOf, &escort.LocationCode,
+		&escort.CreatedAt, &escort.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &escort, nil
+}
+
+func (r *postgresEscortRepository) Create(ctx context.Context, escort *models.Escort) error {
+	query := `
+		INSERT INTO escorts (
+			status, kategori_pengantar, nama_pengantar, jenis_kelamin,
+			nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
+			submission_id, submitted_from_ip, api_submission,
+			rejection_reason, duplicate_of, location_code, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW()
+		)
+		ON CONFLICT (submission_id) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.q.QueryRow(ctx, query,
+		escort.Status, escort.KategoriPengantar, escort.NamaPengantar,
+		escort.JenisKelamin, escort.NomorHP, escort.PlatNomor,
+		escort.NamaPasien, escort.FotoPengantar, escort.SubmissionID,
+		escort.SubmittedFromIP, escort.APISubmission, escort.RejectionReason,
+		escort.DuplicateOf, escort.LocationCode,
+	).Scan(&escort.ID, &escort.CreatedAt, &escort.UpdatedAt)
+	if err != nil {
+		// DO NOTHING instead of a unique violation keeps the transaction usable
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSubmissionIDTaken
+		}
+		return fmt.Errorf("failed to create escort: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresEscortRepository) GetBySubmissionID(ctx context.Context, submissionID string) (*models.Escort, error) {
+	escort, err := scanEscort(r.q.QueryRow(ctx, "SELECT "+escortColumns+" FROM escorts WHERE submission_id = $1", submissionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get escort: %w", err)
+	}
+	return escort, nil
+}
+
+func (r *postgresEscortRepository) List(ctx context.Context, query EscortQuery) ([]models.Escort, int64, error) {
+	total, err := r.Count(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereClause, args := buildEscortWhere(query)
+	argCount := len(args)
+	sql := fmt.Sprintf("SELECT %s FROM escorts %s %s LIMIT $%d OFFSET $%d",
+		escortColumns, whereClause, buildEscortOrder(query.EscortFilters), argCount+1, argCount+2)
+	args = append(args, query.PerPage, (query.Page-1)*query.PerPage)
+
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query escorts: %w", err)
+	}
+	defer rows.Close()
+
+	var escorts []models.Escort
+	for rows.Next() {
+		escort, err := scanEscort(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan escort: %w", err)
+		}
+		escorts = append(escorts, *escort)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read escorts: %w", err)
+	}
+
+	return escorts, total, nil
+}
+
+func (r *postgresEscortRepository) Count(ctx context.Context, query EscortQuery) (int64, error) {
+	whereClause, args := buildEscortWhere(query)
+
+	var total int64
+	err := r.q.QueryRow(ctx, "SELECT COUNT(*) FROM escorts "+whereClause, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count escorts: %w", err)
+	}
+	return total, nil
+}
+
+func (r *postgresEscortRepository) Each(ctx context.Context, query EscortQuery, fn func(models.Escort) error) error {
+	whereClause, args := buildEscortWhere(query)
+	sql := fmt.Sprintf("SELECT %s FROM escorts %s %s",
+		escortColumns, whereClause, buildEscortOrder(query.EscortFilters))
+
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query escorts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		escort, err := scanEscort(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan escort: %w", err)
+		}
+		if err := fn(*escort); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *postgresEscortRepository) FindRecentDuplicate(ctx context.Context, escort models.Escort, since time.Time) (*models.Escort, error) {
+	plat, phone, patient := duplicateKey(escort)
+	query := "SELECT " + escortColumns + ` FROM escorts
+		WHERE REPLACE(UPPER(plat_nomor), ' ', '') = $1
+		  AND REPLACE(nomor_hp, ' ', '') = $2
+		  AND LOWER(TRIM(nama_pasien)) = $3
+		  AND created_at >= $4
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+
+	duplicate, err := scanEscort(r.q.QueryRow(ctx, query, plat, phone, patient, since))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find duplicate escort: %w", err)
+	}
+	return duplicate, nil
+}
+
+func (r *postgresEscortRepository) GetByID(ctx context.Context, id uint) (*models.Escort, error) {
+	return r.get(ctx, "SELECT "+escortColumns+" FROM escorts WHERE id = $1", id)
+}
+
+func (r *postgresEscortRepository) GetForUpdate(ctx context.Context, id uint) (*models.Escort, error) {
+	return r.get(ctx, "SELECT "+escortColumns+" FROM escorts WHERE id = $1 FOR UPDATE", id)
+}
+
+func (r *postgresEscortRepository) get(ctx context.Context, query string, id uint) (*models.Escort, error) {
+	escort, err := scanEscort(r.q.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get escort: %w", err)
+	}
+	return escort, nil
+}
+
+func (r *postgresEscortRepository) Update(ctx context.Context, id uint, update EscortUpdate) error {
+	// Build dynamic update query
+	setParts := []string{"updated_at = NOW()"}
+	args := []interface{}{}
+
+	columns := []struct {
+		name  string
+		value *string
+	}{
+		{"kategori_pengantar", update.KategoriPengantar},
+		{"nama_pengantar", update.NamaPengantar},
+		{"jenis_kelamin", update.JenisKelamin},
+		{"nomor_hp", update.NomorHP},
+		{"plat_nomor", update.PlatNomor},
+		{"nama_pasien", update.NamaPasien},
+		{"foto_pengantar", update.FotoPengantar},
+	}
+	for _, column := range columns {
+		if column.value != nil {
+			args = append(args, *column.value)
+			setParts = append(setParts, fmt.Sprintf("%s = $%d", column.name, len(args)))
+		}
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE escorts SET %s WHERE id = $%d", strings.Join(setParts, ", "), len(args))
+
+	result, err := r.q.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update escort: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresEscortRepository) UpdateStatus(ctx context.Context, id uint, status string, rejectionReason *string) error {
+	query := "UPDATE escorts SET status = $1, rejection_reason = $2, updated_at = NOW() WHERE id = $3"
+	result, err := r.q.Exec(ctx, query, status, rejectionReason, id)
+	if err != nil {
+		return fmt.Errorf("failed to update escort status: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresEscortRepository) Delete(ctx context.Context, id uint) error {
+	result, err := r.q.Exec(ctx, "DELETE FROM escorts WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete escort: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresEscortRepository) Stats(ctx context.Context) (*models.DashboardStats, error) {
+	stats := &models.DashboardStats{
+		CategoryStats:   make(map[string]int64),
+		StatusBreakdown: make(map[string]int64),
+	}
+
+	// Totals, status counts and today's submissions in one pass
+	totalsQuery := `
+		SELECT
+			COUNT(*),
+			COUNT(CASE WHEN status = 'pending' THEN 1 END),
+			COUNT(CASE WHEN status = 'verified' THEN 1 END),
+			COUNT(CASE WHEN status = 'rejected' THEN 1 END),
+			COUNT(CASE WHEN DATE(created_at) = CURRENT_DATE THEN 1 END)
+		FROM escorts
+	`
+	err := r.q.QueryRow(ctx, totalsQuery).Scan(
+		&stats.TotalEscorts,
+		&stats.PendingEscorts,
+		&stats.VerifiedEscorts,
+		&stats.RejectedEscorts,
+		&stats.TodaySubmissions,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get escort totals: %w", err)
+	}
+
+	if err := r.countBy(ctx, "kategori_pengantar", stats.CategoryStats); err != nil {
+		return nil, fmt.Errorf("failed to get category stats: %w", err)
+	}
+	if err := r.countBy(ctx, "status", stats.StatusBreakdown); err != nil {
+		return nil, fmt.Errorf("failed to get status breakdown: %w", err)
+	}
+
+	// Get recent escorts (last 5)
+	rows, err := r.q.Query(ctx, "SELECT "+escortColumns+" FROM escorts ORDER BY created_at DESC LIMIT 5")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent escorts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		escort, err := scanEscort(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recent escort: %w", err)
+		}
+		stats.RecentEscorts = append(stats.RecentEscorts, *escort)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recent escorts: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *postgresEscortRepository) CountByLocation(ctx context.Context, query EscortQuery) ([]models.LocationCount, error) {
+	whereClause, args := buildEscortWhere(query)
+	sql := `
+		SELECT
+			location_code,
+			COUNT(*),
+			COUNT(CASE WHEN status = 'pending' THEN 1 END),
+			COUNT(CASE WHEN status = 'verified' THEN 1 END),
+			COUNT(CASE WHEN status = 'rejected' THEN 1 END)
+		FROM escorts ` + whereClause + `
+		GROUP BY location_code
+		ORDER BY location_code NULLS LAST`
+
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count escorts by location: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.LocationCount{}
+	for rows.Next() {
+		var count models.LocationCount
+		if err := rows.Scan(&count.LocationCode, &count.Total, &count.Pending, &count.Verified, &count.Rejected); err != nil {
+			return nil, fmt.Errorf("failed to scan location count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read location counts: %w", err)
+	}
+	return counts, nil
+}
+
+// countBy counts escorts grouped by a trusted column name into counts
+func (r *postgresEscortRepository) countBy(ctx context.Context, column string, counts map[string]int64) error {
+	rows, err := r.q.Query(ctx, fmt.Sprintf("SELECT %s, COUNT(*) FROM escorts GROUP BY %s", column, column))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+		counts[key] = count
+	}
+	return rows.Err()
+}
+
+func (r *postgresEscortRepository) AddStatusHistory(ctx context.Context, entry *models.EscortStatusHistory) error {
+	query := `
+		INSERT INTO escort_status_history (
+			escort_id, old_status, new_status, reason_code, changed_by, note, client_ip, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at
+	`
+
+	err := r.q.QueryRow(ctx, query, entry.EscortID, entry.OldStatus, entry.NewStatus,
+		entry.ReasonCode, entry.ChangedBy, entry.Note, entry.ClientIP,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresEscortRepository) StatusHistory(ctx context.Context, escortID uint) ([]models.EscortStatusHistory, error) {
+	query := `
+		SELECT h.id, h.escort_id, h.old_status, h.new_status, h.reason_code,
+		       r.label, h.changed_by, u.name, h.note, h.client_ip, h.created_at
+		FROM escort_status_history h
+		LEFT JOIN users u ON u.id = h.changed_by
+		LEFT JOIN rejection_reasons r ON r.code = h.reason_code
+		WHERE h.escort_id = $1
+		ORDER BY h.created_at, h.id
+	`
+
+	rows, err := r.q.Query(ctx, query, escortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.EscortStatusHistory{}
+	for rows.Next() {
+		var entry models.EscortStatusHistory
+		err := rows.Scan(
+			&entry.ID, &entry.EscortID, &entry.OldStatus, &entry.NewStatus,
+			&entry.ReasonCode, &entry.ReasonLabel, &entry.ChangedBy, &entry.ChangedByName,
+			&entry.Note, &entry.ClientIP, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// buildEscortWhere builds the WHERE clause and arguments shared by escort listing and export
+func buildEscortWhere(query EscortQuery) (string, []interface{}) {
+	whereClause := "WHERE 1=1"
+	args := []interface{}{}
+
+	if query.Status != "" {
+		args = append(args, query.Status)
+		whereClause += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	if query.KategoriPengantar != "" {
+		args = append(args, query.KategoriPengantar)
+		whereClause += fmt.Sprintf(" AND kategori_pengantar = $%d", len(args))
+	}
+
+	if query.JenisKelamin != "" {
+		args = append(args, query.JenisKelamin)
+		whereClause += fmt.Sprintf(" AND jenis_kelamin = $%d", len(args))
+	}
+
+	if query.Search != "" {
+		args = append(args, "%"+query.Search+"%")
+		n := len(args)
+		whereClause += fmt.Sprintf(" AND (nama_pengantar ILIKE $%d OR nama_pasien ILIKE $%d OR plat_nomor ILIKE $%d)", n, n, n)
+	}
+
+	if query.CreatedFrom != nil {
+		args = append(args, *query.CreatedFrom)
+		whereClause += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+
+	if query.CreatedBefore != nil {
+		args = append(args, *query.CreatedBefore)
+		whereClause += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	return whereClause, args
+}
+
+// escortSortFields whitelists the columns escorts may be sorted by
+var escortSortFields = map[string]bool{
+	"id": true, "status": true, "kategori_pengantar": true,
+	"nama_pengantar": true, "nama_pasien": true, "created_at": true,
+}
+
+// buildEscortOrder builds the ORDER BY clause from whitelisted sort fields
+func buildEscortOrder(filters models.EscortFilters) string {
+	if !escortSortFields[filters.SortBy] {
+		return "ORDER BY created_at DESC"
+	}
+	sortOrder := "DESC"
+	if filters.SortOrder == "asc" {
+		sortOrder = "ASC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s", filters.SortBy, sortOrder)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// escortEventsChannel is the NOTIFY channel escort events are published on
+const escortEventsChannel = "escort_events"
+
+// maxNotifyPayload stays below PostgreSQL's 8000 byte NOTIFY payload limit
+const maxNotifyPayload = 7900
+
+type postgresEventRepository struct {
+	q querier
+}
+
+func (r *postgresEventRepository) Publish(ctx context.Context, event *models.EscortEvent) error {
+	if err := r.q.QueryRow(ctx, "SELECT nextval('escort_events_id_seq')").Scan(&event.ID); err != nil {
+		return fmt.Errorf("failed to allocate event ID: %w", err)
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	// An oversized payload would fail the whole transaction, so send the bare event;
+	// listeners fetch the escort themselves when the snapshot is missing
+	if len(payload) > maxNotifyPayload {
+		bare := *event
+		bare.Escort = nil
+		if payload, err = json.Marshal(bare); err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+
+	// NOTIFY is transactional: listeners receive the payload only when the transaction commits
+	if _, err := r.q.Exec(ctx, "SELECT pg_notify($1, $2)", escortEventsChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// listenEscortEvents holds a dedicated connection in LISTEN mode and passes every escort event to fn
+func listenEscortEvents(ctx context.Context, db *pgxpool.Pool, fn func(models.EscortEvent)) error {
+	pooled, err := db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+	// The connection is taken out of the pool so its LISTEN state never leaks to other queries
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+escortEventsChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", escortEventsChannel, err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for events: %w", err)
+		}
+
+		var event models.EscortEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			// Payloads not written by Publish are ignored
+			continue
+		}
+		fn(event)
+	}
+}
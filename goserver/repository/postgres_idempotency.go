@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresIdempotencyRepository struct {
+	q querier
+}
+
+func (r *postgresIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	reserve := `
+		INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL,
+		    response_body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+		   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $6)`
+
+	stored := `
+		SELECT scope, key, request_hash, status_code, COALESCE(content_type, ''), response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2`
+
+	// The stored record can be released between the two statements; then reserve again
+	for attempt := 0; attempt < 3; attempt++ {
+		result, err := r.q.Exec(ctx, reserve, record.Scope, record.Key, record.RequestHash,
+			record.CreatedAt, record.ExpiresAt, staleBefore)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if result.RowsAffected() > 0 {
+			return record, true, nil
+		}
+
+		var existing models.IdempotencyRecord
+		err = r.q.QueryRow(ctx, stored, record.Scope, record.Key).Scan(
+			&existing.Scope, &existing.Key, &existing.RequestHash, &existing.StatusCode,
+			&existing.ContentType, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		return &existing, false, nil
+	}
+	return nil, false, fmt.Errorf("failed to reserve idempotency key %q: released repeatedly", record.Key)
+}
+
+func (r *postgresIdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3
+		WHERE scope = $4 AND key = $5`
+
+	result, err := r.q.Exec(ctx, query, statusCode, contentType, body, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL"
+	if _, err := r.q.Exec(ctx, query, scope, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresIdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.q.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresKioskLocationRepository struct {
+	q querier
+}
+
+const kioskLocationColumns = "code, name, active, created_at, updated_at"
+
+func scanKioskLocation(row pgx.Row) (*models.KioskLocation, error) {
+	var location models.KioskLocation
+	err := row.Scan(&location.Code, &location.Name, &location.Active, &location.CreatedAt, &location.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (r *postgresKioskLocationRepository) List(ctx context.Context, includeInactive bool) ([]models.KioskLocation, error) {
+	query := "SELECT " + kioskLocationColumns + " FROM kiosk_locations"
+	if !includeInactive {
+		query += " WHERE active"
+	}
+	query += " ORDER BY name"
+
+	rows, err := r.q.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query kiosk locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []models.KioskLocation{}
+	for rows.Next() {
+		location, err := scanKioskLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan kiosk location: %w", err)
+		}
+		locations = append(locations, *location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read kiosk locations: %w", err)
+	}
+
+	return locations, nil
+}
+
+func (r *postgresKioskLocationRepository) Get(ctx context.Context, code string) (*models.KioskLocation, error) {
+	location, err := scanKioskLocation(r.q.QueryRow(ctx,
+		"SELECT "+kioskLocationColumns+" FROM kiosk_locations WHERE code = $1", code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get kiosk location: %w", err)
+	}
+	return location, nil
+}
+
+func (r *postgresKioskLocationRepository) Upsert(ctx context.Context, code, name string) (*models.KioskLocation, error) {
+	query := `
+		INSERT INTO kiosk_locations (code, name, active, created_at, updated_at)
+		VALUES ($1, $2, TRUE, NOW(), NOW())
+		ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, active = TRUE, updated_at = NOW()
+		RETURNING ` + kioskLocationColumns
+
+	location, err := scanKioskLocation(r.q.QueryRow(ctx, query, code, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kiosk location: %w", err)
+	}
+	return location, nil
+}
+
+func (r *postgresKioskLocationRepository) Update(ctx context.Context, code string, name *string, active *bool) (*models.KioskLocation, error) {
+	query := `
+		UPDATE kiosk_locations
+		SET name = COALESCE($1, name), active = COALESCE($2, active), updated_at = NOW()
+		WHERE code = $3
+		RETURNING ` + kioskLocationColumns
+
+	location, err := scanKioskLocation(r.q.QueryRow(ctx, query, name, active, code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update kiosk location: %w", err)
+	}
+	return location, nil
+}
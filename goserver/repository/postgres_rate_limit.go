@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type postgresRateLimitRepository struct {
+	q querier
+}
+
+// refilledTokens is the content of an existing bucket refilled up to now ($4)
+const refilledTokens = `LEAST($2::float8, rate_limits.tokens + GREATEST(EXTRACT(EPOCH FROM ($4 - rate_limits.updated_at)), 0) * $3::float8)`
+
+func (r *postgresRateLimitRepository) Take(ctx context.Context, key string, burst, rate float64, now, expiresAt time.Time) (bool, float64, error) {
+	// One statement, so instances taking from the same bucket at once are serialised by the row lock
+	query := `
+		INSERT INTO rate_limits (key, tokens, allowed, updated_at, expires_at)
+		VALUES ($1, $2::float8 - 1, TRUE, $4, $5)
+		ON CONFLICT (key) DO UPDATE
+		SET allowed = ` + refilledTokens + ` >= 1,
+		    tokens = ` + refilledTokens + ` - CASE WHEN ` + refilledTokens + ` >= 1 THEN 1 ELSE 0 END,
+		    updated_at = GREATEST(rate_limits.updated_at, $4),
+		    expires_at = $5
+		RETURNING allowed, tokens`
+
+	var allowed bool
+	var tokens float64
+	if err := r.q.QueryRow(ctx, query, key, burst, rate, now, expiresAt).Scan(&allowed, &tokens); err != nil {
+		return false, 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return allowed, tokens, nil
+}
+
+func (r *postgresRateLimitRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.q.Exec(ctx, "DELETE FROM rate_limits WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired rate limits: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresRejectionReasonRepository struct {
+	q querier
+}
+
+const rejectionReasonColumns = "code, label, active, created_at, updated_at"
+
+func scanRejectionReason(row pgx.Row) (*models.RejectionReason, error) {
+	var reason models.RejectionReason
+	err := row.Scan(&reason.Code, &reason.Label, &reason.Active, &reason.CreatedAt, &reason.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &reason, nil
+}
+
+func (r *postgresRejectionReasonRepository) List(ctx context.Context, includeInactive bool) ([]models.RejectionReason, error) {
+	query := "SELECT " + rejectionReasonColumns + " FROM rejection_reasons"
+	if !includeInactive {
+		query += " WHERE active"
+	}
+	query += " ORDER BY label"
+
+	rows, err := r.q.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejection reasons: %w", err)
+	}
+	defer rows.Close()
+
+	reasons := []models.RejectionReason{}
+	for rows.Next() {
+		reason, err := scanRejectionReason(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rejection reason: %w", err)
+		}
+		reasons = append(reasons, *reason)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rejection reasons: %w", err)
+	}
+
+	return reasons, nil
+}
+
+func (r *postgresRejectionReasonRepository) Get(ctx context.Context, code string) (*models.RejectionReason, error) {
+	reason, err := scanRejectionReason(r.q.QueryRow(ctx,
+		"SELECT "+rejectionReasonColumns+" FROM rejection_reasons WHERE code = $1", code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get rejection reason: %w", err)
+	}
+	return reason, nil
+}
+
+func (r *postgresRejectionReasonRepository) Create(ctx context.Context, code, label string) (*models.RejectionReason, error) {
+	query := `
+		INSERT INTO rejection_reasons (code, label, active, created_at, updated_at)
+		VALUES ($1, $2, TRUE, NOW(), NOW())
+		ON CONFLICT (code) DO NOTHING
+		RETURNING ` + rejectionReasonColumns
+
+	reason, err := scanRejectionReason(r.q.QueryRow(ctx, query, code, label))
+	if err != nil {
+		// DO NOTHING returns no row for an existing code
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create rejection reason: %w", err)
+	}
+	return reason, nil
+}
+
+func (r *postgresRejectionReasonRepository) Update(ctx context.Context, code string, label *string, active *bool) (*models.RejectionReason, error) {
+	query := `
+		UPDATE rejection_reasons
+		SET label = COALESCE($1, label), active = COALESCE($2, active), updated_at = NOW()
+		WHERE code = $3
+		RETURNING ` + rejectionReasonColumns
+
+	reason, err := scanRejectionReason(r.q.QueryRow(ctx, query, label, active, code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update rejection reason: %w", err)
+	}
+	return reason, nil
+}
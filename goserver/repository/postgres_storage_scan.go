@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresStorageScanRepository struct {
+	q querier
+}
+
+func (r *postgresStorageScanRepository) Save(ctx context.Context, report *models.StorageScanReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode storage scan report: %w", err)
+	}
+
+	query := `
+		INSERT INTO storage_scans (storage, dry_run, report, started_at, finished_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id`
+
+	err = r.q.QueryRow(ctx, query, report.Storage, report.DryRun, data, report.StartedAt, report.FinishedAt).
+		Scan(&report.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save storage scan report: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresStorageScanRepository) Latest(ctx context.Context) (*models.StorageScanReport, error) {
+	query := `SELECT id, report FROM storage_scans ORDER BY id DESC LIMIT 1`
+
+	var id uint
+	var data []byte
+	if err := r.q.QueryRow(ctx, query).Scan(&id, &data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get storage scan report: %w", err)
+	}
+
+	var report models.StorageScanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode storage scan report: %w", err)
+	}
+	report.ID = id
+	return &report, nil
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresUserRepository struct {
+	q querier
+}
+
+const userColumns = "id, name, email, password, role, created_at, updated_at"
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *postgresUserRepository) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.q.Query(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *postgresUserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	return r.get(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+}
+
+func (r *postgresUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.get(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email)
+}
+
+func (r *postgresUserRepository) get(ctx context.Context, query string, arg any) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *postgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+		INSERT INTO users (name, email, password, role, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.q.QueryRow(ctx, query, user.Name, user.Email, user.Password, user.Role).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) Update(ctx context.Context, user *models.User) error {
+	result, err := r.q.Exec(ctx,
+		"UPDATE users SET name = $1, email = $2, role = COALESCE(NULLIF($3, ''), role), updated_at = NOW() WHERE id = $4",
+		user.Name, user.Email, user.Role, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) UpdateRole(ctx context.Context, id uint, role, passwordHash string) error {
+	result, err := r.q.Exec(ctx,
+		"UPDATE users SET role = $1, password = COALESCE(NULLIF($2, ''), password), updated_at = NOW() WHERE id = $3",
+		role, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) Delete(ctx context.Context, id uint) error {
+	result, err := r.q.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type postgresTokenRepository struct {
+	q querier
+}
+
+const tokenColumns = `
+	id, tokenable_type, tokenable_id, name, token, abilities,
+	last_used_at, created_at, updated_at
+`
+
+func (r *postgresTokenRepository) FindByID(ctx context.Context, id uint) (*models.PersonalAccessToken, error) {
+	return r.find(ctx, "SELECT "+tokenColumns+" FROM personal_access_tokens WHERE id = $1", id)
+}
+
+func (r *postgresTokenRepository) FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	return r.find(ctx, "SELECT "+tokenColumns+" FROM personal_access_tokens WHERE token = $1", hash)
+}
+
+func (r *postgresTokenRepository) find(ctx context.Context, query string, arg any) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var abilities *string
+	err := r.q.QueryRow(ctx, query, arg).Scan(
+		&token.ID, &token.TokenableType, &token.TokenableID, &token.Name,
+		&token.Token, &abilities, &token.LastUsedAt, &token.CreatedAt, &token.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to look up access token: %w", err)
+	}
+
+	// Sanctum stores abilities as a JSON array in a text column
+	if abilities != nil && *abilities != "" {
+		if err := json.Unmarshal([]byte(*abilities), &token.Abilities); err != nil {
+			return nil, fmt.Errorf("failed to decode token abilities: %w", err)
+		}
+	}
+
+	return &token, nil
+}
+
+func (r *postgresTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	abilities, err := json.Marshal(token.Abilities)
+	if err != nil {
+		return fmt.Errorf("failed to encode token abilities: %w", err)
+	}
+
+	query := `
+		INSERT INTO personal_access_tokens (tokenable_type, tokenable_id, name, token, abilities, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+	err = r.q.QueryRow(ctx, query, token.TokenableType, token.TokenableID, token.Name, token.Token, string(abilities)).
+		Scan(&token.ID, &token.CreatedAt, &token.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresTokenRepository) Touch(ctx context.Context, id uint) error {
+	_, err := r.q.Exec(ctx,
+		"UPDATE personal_access_tokens SET last_used_at = NOW(), updated_at = NOW() WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to update token usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goserver/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresWebhookRepository struct {
+	q querier
+}
+
+const webhookSubscriptionColumns = "id, url, events, secret, description, active, created_at, updated_at"
+
+// webhookDeliveryColumns are qualified with d. and joined with the subscription as s.
+const webhookDeliveryColumns = `d.id, d.subscription_id, s.url, d.event_id, d.event_type, d.payload, d.status,
+	d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at, d.updated_at`
+
+func scanWebhookSubscription(row pgx.Row) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Events, &sub.Secret, &sub.Description, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// scanWebhookDelivery scans webhookDeliveryColumns followed by any extra destinations
+func scanWebhookDelivery(row pgx.Row, extra ...any) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	dest := append([]any{
+		&delivery.ID, &delivery.SubscriptionID, &delivery.URL, &delivery.EventID, &delivery.EventType,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+		&delivery.LastStatusCode, &delivery.LastError, &delivery.DeliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *postgresWebhookRepository) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := r.q.Query(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subs = append(subs, *sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (r *postgresWebhookRepository) GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	sub, err := scanWebhookSubscription(r.q.QueryRow(ctx,
+		"SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *postgresWebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, events, secret, description, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := r.q.QueryRow(ctx, query, sub.URL, sub.Events, sub.Secret, sub.Description, sub.Active).
+		Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresWebhookRepository) UpdateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = $1, events = $2, description = $3, active = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at`
+
+	err := r.q.QueryRow(ctx, query, sub.URL, sub.Events, sub.Description, sub.Active, sub.ID).Scan(&sub.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresWebhookRepository) DeleteSubscription(ctx context.Context, id uint) error {
+	result, err := r.q.Exec(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresWebhookRepository) Enqueue(ctx context.Context, eventID int64, eventType string, payload []byte) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+		SELECT id, $1, $2, $3, $4, NOW(), NOW(), NOW()
+		FROM webhook_subscriptions
+		WHERE active AND $2 = ANY(events)`
+
+	result, err := r.q.Exec(ctx, query, eventID, eventType, string(payload), models.DeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *postgresWebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	// SKIP LOCKED lets several dispatchers claim disjoint batches; the lease keeps a claimed
+	// delivery from being picked up again until its attempt has been recorded
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), d AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = NOW() + $3::float8 * INTERVAL '1 second', updated_at = NOW()
+			FROM due
+			WHERE webhook_deliveries.id = due.id
+			RETURNING webhook_deliveries.*
+		)
+		SELECT ` + webhookDeliveryColumns + `, s.secret
+		FROM d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		ORDER BY d.id`
+
+	rows, err := r.q.Query(ctx, query, models.DeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []ClaimedDelivery
+	for rows.Next() {
+		var secret string
+		delivery, err := scanWebhookDelivery(rows, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		claimed = append(claimed, ClaimedDelivery{WebhookDelivery: *delivery, Secret: secret})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (r *postgresWebhookRepository) MarkDelivered(ctx context.Context, id uint, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = NULL,
+			delivered_at = NOW(), updated_at = NOW()
+		WHERE id = $3`
+
+	result, err := r.q.Exec(ctx, query, models.DeliveryDelivered, statusCode, id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresWebhookRepository) MarkFailed(ctx context.Context, id uint, statusCode *int, message string, retryAt *time.Time) error {
+	status := models.DeliveryPending
+	if retryAt == nil {
+		status = models.DeliveryDead
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = $3,
+			next_attempt_at = COALESCE($4, next_attempt_at), updated_at = NOW()
+		WHERE id = $5`
+
+	result, err := r.q.Exec(ctx, query, status, statusCode, message, retryAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresWebhookRepository) ListDeliveries(ctx context.Context, filters models.WebhookDeliveryFilters) ([]models.WebhookDelivery, int64, error) {
+	var conditions []string
+	var args []any
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		conditions = append(conditions, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+	if filters.SubscriptionID != 0 {
+		args = append(args, filters.SubscriptionID)
+		conditions = append(conditions, fmt.Sprintf("d.subscription_id = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.q.QueryRow(ctx, "SELECT COUNT(*) FROM webhook_deliveries d"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	args = append(args, filters.PerPage, (filters.Page-1)*filters.PerPage)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id%s
+		ORDER BY d.id DESC
+		LIMIT $%d OFFSET $%d`, webhookDeliveryColumns, where, len(args)-1, len(args))
+
+	rows, err := r.q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+
+	return deliveries, total, nil
+}
+
+func (r *postgresWebhookRepository) Redeliver(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	query := `
+		WITH d AS (
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+			WHERE id = $2
+			RETURNING *
+		)
+		SELECT ` + webhookDeliveryColumns + `
+		FROM d JOIN webhook_subscriptions s ON s.id = d.subscription_id`
+
+	delivery, err := scanWebhookDelivery(r.q.QueryRow(ctx, query, models.DeliveryPending, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
@@ -0,0 +1,252 @@
+// Package repository persists the domain models. Every repository has a PostgreSQL
+// implementation used in production and a thread-safe in-memory one used by tests.
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"goserver/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrSubmissionIDTaken is returned by EscortRepository.Create when another escort has the
+	// same submission ID. The surrounding transaction stays usable, so Create can be retried.
+	ErrSubmissionIDTaken = errors.New("submission ID already taken")
+	// ErrAlreadyExists is returned when creating a record whose key is already taken
+	ErrAlreadyExists = errors.New("record already exists")
+)
+
+// EscortQuery selects escorts for listing and export
+type EscortQuery struct {
+	models.EscortFilters
+
+	// CreatedFrom and CreatedBefore bound created_at (inclusive and exclusive); nil means unbounded
+	CreatedFrom   *time.Time
+	CreatedBefore *time.Time
+}
+
+// EscortUpdate lists the escort columns to change; nil fields are left untouched
+type EscortUpdate struct {
+	KategoriPengantar *string
+	NamaPengantar     *string
+	JenisKelamin      *string
+	NomorHP           *string
+	PlatNomor         *string
+	NamaPasien        *string
+	FotoPengantar     *string
+}
+
+// IsEmpty reports whether the update changes no column
+func (u EscortUpdate) IsEmpty() bool {
+	return u.KategoriPengantar == nil && u.NamaPengantar == nil && u.JenisKelamin == nil &&
+		u.NomorHP == nil && u.PlatNomor == nil && u.NamaPasien == nil && u.FotoPengantar == nil
+}
+
+// duplicateKey normalises the fields that identify a duplicate submission: spaces and letter
+// case in the plate number, spaces in the phone number and case in the patient name do not count
+func duplicateKey(escort models.Escort) (plat, phone, patient string) {
+	return strings.ReplaceAll(strings.ToUpper(escort.PlatNomor), " ", ""),
+		strings.ReplaceAll(escort.NomorHP, " ", ""),
+		strings.ToLower(strings.TrimSpace(escort.NamaPasien))
+}
+
+// EscortRepository stores escorts and their status history
+type EscortRepository interface {
+	// Create inserts an escort and fills in its ID and timestamps
+	Create(ctx context.Context, escort *models.Escort) error
+	// GetBySubmissionID finds an escort by its exact submission ID
+	GetBySubmissionID(ctx context.Context, submissionID string) (*models.Escort, error)
+	// List returns one page of escorts matching the query (Page and PerPage must be set) and the total match count
+	List(ctx context.Context, query EscortQuery) ([]models.Escort, int64, error)
+	// Count counts escorts matching the query
+	Count(ctx context.Context, query EscortQuery) (int64, error)
+	// Each calls fn for every escort matching the query, in listing order, without loading them all at once
+	Each(ctx context.Context, query EscortQuery, fn func(models.Escort) error) error
+	GetByID(ctx context.Context, id uint) (*models.Escort, error)
+	// FindRecentDuplicate returns the latest escort created since the given time with the same
+	// plate number, phone number and patient name as escort (see duplicateKey)
+	FindRecentDuplicate(ctx context.Context, escort models.Escort, since time.Time) (*models.Escort, error)
+	// GetForUpdate reads an escort and locks it until the surrounding transaction ends
+	GetForUpdate(ctx context.Context, id uint) (*models.Escort, error)
+	Update(ctx context.Context, id uint, update EscortUpdate) error
+	UpdateStatus(ctx context.Context, id uint, status string, rejectionReason *string) error
+	Delete(ctx context.Context, id uint) error
+	Stats(ctx context.Context) (*models.DashboardStats, error)
+	// CountByLocation counts escorts matching the query per kiosk location, ordered by
+	// location code with submissions without a location last
+	CountByLocation(ctx context.Context, query EscortQuery) ([]models.LocationCount, error)
+
+	// AddStatusHistory appends an entry to the status timeline and fills in its ID and CreatedAt
+	AddStatusHistory(ctx context.Context, entry *models.EscortStatusHistory) error
+	// StatusHistory returns the timeline of an escort, oldest first, with user names and reason labels resolved
+	StatusHistory(ctx context.Context, escortID uint) ([]models.EscortStatusHistory, error)
+}
+
+// UserRepository stores users
+type UserRepository interface {
+	List(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// Create inserts a user (with an already hashed password) and fills in its ID and timestamps
+	Create(ctx context.Context, user *models.User) error
+	// Update stores name, email and role; an empty role keeps the current one
+	Update(ctx context.Context, user *models.User) error
+	// UpdateRole changes the role and, when passwordHash is not empty, the password
+	UpdateRole(ctx context.Context, id uint, role, passwordHash string) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// TokenRepository stores Sanctum personal access tokens
+type TokenRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.PersonalAccessToken, error)
+	// FindByHash looks a token up by its SHA-256 hash
+	FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error)
+	// Create inserts a token (with an already hashed Token) and fills in its ID and timestamps
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	// Touch records that the token was just used
+	Touch(ctx context.Context, id uint) error
+}
+
+// RejectionReasonRepository stores the managed list of rejection reasons
+type RejectionReasonRepository interface {
+	List(ctx context.Context, includeInactive bool) ([]models.RejectionReason, error)
+	Get(ctx context.Context, code string) (*models.RejectionReason, error)
+	// Create adds an active reason; an existing code, even a deactivated one, is
+	// ErrAlreadyExists because status history keeps referring to it
+	Create(ctx context.Context, code, label string) (*models.RejectionReason, error)
+	// Update changes the label and/or active flag; nil values are left untouched
+	Update(ctx context.Context, code string, label *string, active *bool) (*models.RejectionReason, error)
+}
+
+type KioskLocationRepository interface {
+	List(ctx context.Context, includeInactive bool) ([]models.KioskLocation, error)
+	Get(ctx context.Context, code string) (*models.KioskLocation, error)
+	// Upsert adds a location or, when the code exists, replaces its name and reactivates it
+	Upsert(ctx context.Context, code, name string) (*models.KioskLocation, error)
+	// Update changes the name and/or active flag; nil values are left untouched
+	Update(ctx context.Context, code string, name *string, active *bool) (*models.KioskLocation, error)
+}
+
+// ClaimedDelivery is a webhook delivery claimed for sending, with the secret to sign it
+type ClaimedDelivery struct {
+	models.WebhookDelivery
+	Secret string
+}
+
+// WebhookRepository stores webhook subscriptions and their delivery outbox
+type WebhookRepository interface {
+	ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error)
+	// CreateSubscription inserts a subscription and fills in its ID and timestamps
+	CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	// UpdateSubscription stores the URL, events, description and active flag
+	UpdateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	// DeleteSubscription removes a subscription together with its deliveries
+	DeleteSubscription(ctx context.Context, id uint) error
+
+	// Enqueue adds a pending delivery of payload for every active subscription to eventType
+	// and returns how many were added
+	Enqueue(ctx context.Context, eventID int64, eventType string, payload []byte) (int, error)
+	// ClaimDue returns up to limit pending deliveries that are due, oldest first, and pushes
+	// their next attempt back by lease so no other dispatcher claims them while they are sent
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error)
+	// MarkDelivered records a successful attempt
+	MarkDelivered(ctx context.Context, id uint, statusCode int) error
+	// MarkFailed records a failed attempt and schedules the next one at retryAt;
+	// a nil retryAt moves the delivery to the dead-letter log
+	MarkFailed(ctx context.Context, id uint, statusCode *int, message string, retryAt *time.Time) error
+	// ListDeliveries returns one page of deliveries, newest first, and the total match count
+	ListDeliveries(ctx context.Context, filters models.WebhookDeliveryFilters) ([]models.WebhookDelivery, int64, error)
+	// Redeliver resets a delivery to pending with a fresh set of attempts, due now
+	Redeliver(ctx context.Context, id uint) (*models.WebhookDelivery, error)
+}
+
+// IdempotencyRepository stores requests sent with an Idempotency-Key and their responses
+type IdempotencyRepository interface {
+	// Reserve stores record as in progress unless its scope and key are taken. A taken key is
+	// freed first when its record expired or is still in progress but was created before
+	// staleBefore. It returns the record now stored and whether it is the one passed in.
+	Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (*models.IdempotencyRecord, bool, error)
+	// Complete stores the response of a reserved request
+	Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error
+	// Release deletes a reservation still in progress, so the request can be retried
+	Release(ctx context.Context, scope, key string) error
+	// DeleteExpired removes the records that expired before now and returns how many
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// RateLimitRepository keeps the token buckets of the rate limiter
+type RateLimitRepository interface {
+	// Take refills the bucket for key at rate tokens per second, up to burst tokens (a new
+	// bucket starts full), and then takes one token if a whole one is left. It returns
+	// whether a token was taken and how many tokens remain. The bucket may be deleted after
+	// expiresAt, when it would have refilled anyway.
+	Take(ctx context.Context, key string, burst, rate float64, now, expiresAt time.Time) (bool, float64, error)
+	// DeleteExpired removes the buckets that expired before now and returns how many
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// StorageScanRepository stores the reports of the photo storage consistency scanner
+type StorageScanRepository interface {
+	// Save inserts a report and fills in its ID
+	Save(ctx context.Context, report *models.StorageScanReport) error
+	// Latest returns the most recent report
+	Latest(ctx context.Context) (*models.StorageScanReport, error)
+}
+
+// EventRepository publishes escort events to every listener of the backend
+type EventRepository interface {
+	// Publish assigns the event its ID and broadcasts it. Inside a transaction the event is
+	// delivered only once the transaction commits, and not at all if it rolls back.
+	Publish(ctx context.Context, event *models.EscortEvent) error
+}
+
+// ErrListenInTransaction is returned by Listen on a Store passed to WithTx
+var ErrListenInTransaction = errors.New("cannot listen for events inside a transaction")
+
+// Store groups the repositories of one backend and runs units of work across them
+type Store struct {
+	Escorts          EscortRepository
+	Users            UserRepository
+	Tokens           TokenRepository
+	RejectionReasons RejectionReasonRepository
+	Locations        KioskLocationRepository
+	Webhooks         WebhookRepository
+	StorageScans     StorageScanRepository
+	Idempotency      IdempotencyRepository
+	RateLimits       RateLimitRepository
+	Events           EventRepository
+
+	backend backend
+}
+
+// backend is implemented by each storage engine
+type backend interface {
+	withTx(ctx context.Context, fn func(tx *Store) error) error
+	ping(ctx context.Context) error
+	listen(ctx context.Context, fn func(models.EscortEvent)) error
+}
+
+// WithTx runs fn with a Store whose repositories share one transaction. The transaction
+// commits when fn returns nil and rolls back otherwise. Calling WithTx on the Store passed
+// to fn runs inside the same transaction.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	return s.backend.withTx(ctx, fn)
+}
+
+// Ping checks that the backend is reachable
+func (s *Store) Ping(ctx context.Context) error {
+	return s.backend.ping(ctx)
+}
+
+// Listen calls fn for every event published on the backend (for PostgreSQL, by any server
+// instance) in commit order. It blocks until ctx is cancelled or the connection is lost and
+// always returns a non-nil error. fn runs on the listening goroutine and must not block.
+func (s *Store) Listen(ctx context.Context, fn func(models.EscortEvent)) error {
+	return s.backend.listen(ctx, fn)
+}
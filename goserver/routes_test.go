@@ -0,0 +1,2086 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goserver/filestore"
+	"goserver/logging"
+	"goserver/metrics"
+	"goserver/middleware"
+	"goserver/models"
+	"goserver/repository"
+	"goserver/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/xuri/excelize/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testServer runs the full router against an in-memory store
+type testServer struct {
+	*Server
+	tokens map[string]string // role -> bearer token
+}
+
+// newTestServer creates a server on an in-memory store; configure functions adjust the
+// configuration before the routes are set up
+func newTestServer(t *testing.T, configure ...func(*Config)) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ts := &testServer{
+		Server: &Server{
+			store:  repository.NewMemoryStore(),
+			files:  filestore.NewLocal(t.TempDir()),
+			router: gin.New(),
+			config: &Config{
+				AppURL:             "http://localhost:8080",
+				AppEnv:             "testing",
+				StreamHeartbeat:    time.Minute,
+				StreamReplaySize:   100,
+				IdempotencyTTL:     time.Hour,
+				HealthCheckTimeout: time.Second,
+				Receipts: services.ReceiptConfig{
+					Secret: "test-receipt-secret",
+					TTL:    time.Hour,
+					URL:    "http://localhost:8080/api/receipt",
+				},
+				Kiosks: services.KioskConfig{
+					Secret:  "test-kiosk-secret",
+					TTL:     24 * time.Hour,
+					FormURL: "http://localhost:8000/form",
+				},
+			},
+		},
+		tokens: map[string]string{},
+	}
+	for _, fn := range configure {
+		fn(ts.config)
+	}
+	ts.startEscortFeed(t.Context())
+	ts.startRateLimiter(t.Context())
+	ts.setupRoutes()
+
+	for _, role := range models.Roles {
+		ts.tokens[role] = ts.createToken(t, role, []string{"*"})
+	}
+	return ts
+}
+
+// createToken creates a user with the given role and returns a Sanctum "<id>|<secret>" token for it
+func (ts *testServer) createToken(t *testing.T, role string, abilities []string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	user := &models.User{
+		Name:  "Test " + role,
+		Email: fmt.Sprintf("%s-%d@igd.test", role, len(ts.tokens)+len(abilities)),
+		Role:  role,
+	}
+	if err := ts.store.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	secret := fmt.Sprintf("secret-%s-%d", role, user.ID)
+	token := &models.PersonalAccessToken{
+		TokenableType: services.SanctumUserModel,
+		TokenableID:   user.ID,
+		Name:          "test",
+		Token:         services.HashToken(secret),
+		Abilities:     abilities,
+	}
+	if err := ts.store.Tokens.Create(ctx, token); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	return fmt.Sprintf("%d|%s", token.ID, secret)
+}
+
+// do sends a request as the given role ("" for anonymous) and returns the recorded response
+func (ts *testServer) do(t *testing.T, method, path, role string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	return ts.serve(t, req, role)
+}
+
+// serve sends a prepared request as the given role ("" for anonymous) and returns the recorded response
+func (ts *testServer) serve(t *testing.T, req *http.Request, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	if role != "" {
+		req.Header.Set("Authorization", "Bearer "+ts.tokens[role])
+	}
+
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// createEscort submits an escort through the public endpoint and returns it
+func (ts *testServer) createEscort(t *testing.T, overrides map[string]any) models.Escort {
+	t.Helper()
+
+	body := map[string]any{
+		"kategori_pengantar": "Ambulans",
+		"nama_pengantar":     "Budi Santoso",
+		"jenis_kelamin":      "Laki-laki",
+		"nomor_hp":           "081234567890",
+		"plat_nomor":         "B 1234 CD",
+		"nama_pasien":        "Siti Aminah",
+	}
+	for key, value := range overrides {
+		body[key] = value
+	}
+
+	rec := ts.do(t, http.MethodPost, "/api/escort", "", body)
+	expectStatus(t, rec, http.StatusCreated)
+
+	var escort models.Escort
+	decodeData(t, rec, &escort)
+	return escort
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+// decodeData decodes the Data field of an APIResponse into v
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, v any) models.APIResponse {
+	t.Helper()
+
+	var envelope struct {
+		models.APIResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode response: %v; body: %s", err, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(envelope.Data, v); err != nil {
+			t.Fatalf("decode data: %v; body: %s", err, rec.Body.String())
+		}
+	}
+	return envelope.APIResponse
+}
+
+// pngDataURL returns a tiny PNG as a data URL accepted by the base64 image endpoints
+func pngDataURL(t *testing.T) string {
+	t.Helper()
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBytes(t))
+}
+
+// pngBytes returns a small valid PNG image
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	return pngOfSize(t, 2, 2)
+}
+
+// pngOfSize returns a valid PNG image of the given dimensions
+func pngOfSize(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// expectImageError checks the status and rejection code of a refused photo
+func expectImageError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	expectStatus(t, rec, status)
+	var resp struct {
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Errors["code"] != code || resp.Errors["field"] != "foto_pengantar" {
+		t.Fatalf("errors = %v, want code %s", resp.Errors, code)
+	}
+}
+
+// multipartRequest builds a multipart/form-data request with one file in field
+func multipartRequest(t *testing.T, path, field string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "foto.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestPublicRoutes(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"root", http.MethodGet, "/", nil, http.StatusOK},
+		{"health", http.MethodGet, "/api/health", nil, http.StatusOK},
+		{"db test", http.MethodGet, "/api/db-test", nil, http.StatusOK},
+		{"session stats", http.MethodGet, "/api/session-stats", nil, http.StatusOK},
+		{"qr code png", http.MethodGet, "/api/qr-code/form?url=http://localhost:8000/form&size=200", nil, http.StatusOK},
+		{"qr code png without url", http.MethodGet, "/api/qr-code/form", nil, http.StatusBadRequest},
+		{"qr code json", http.MethodPost, "/api/qr-code/form", map[string]any{"url": "http://localhost:8000/form"}, http.StatusOK},
+		{"qr code json invalid url", http.MethodPost, "/api/qr-code/form", map[string]any{"url": "not a url"}, http.StatusBadRequest},
+		{"qr code png for a kiosk", http.MethodGet, "/api/qr-code/form?url=" + url.QueryEscape("http://localhost:8000/form?kiosk=abc"), nil, http.StatusOK},
+		{"qr code png for another site", http.MethodGet, "/api/qr-code/form?url=https://phishing.example/form", nil, http.StatusBadRequest},
+		{"qr code json for another path", http.MethodPost, "/api/qr-code/form", map[string]any{"url": "http://localhost:8000/login"}, http.StatusBadRequest},
+		{"cors preflight", http.MethodOptions, "/api/escort", nil, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, tt.method, tt.path, "", tt.body)
+			expectStatus(t, rec, tt.want)
+		})
+	}
+}
+
+// TestQRCodeJSON checks that the JSON QR code is a base64 data URL of a PNG of the requested size
+func TestQRCodeJSON(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, size := range []int{0, 300} {
+		t.Run(fmt.Sprintf("size %d", size), func(t *testing.T) {
+			var data struct {
+				QRCode string `json:"qr_code"`
+			}
+			body := map[string]any{"url": "http://localhost:8000/form"}
+			if size != 0 {
+				body["size"] = size
+			}
+			decodeData(t, ts.do(t, http.MethodPost, "/api/qr-code/form", "", body), &data)
+
+			encoded, ok := strings.CutPrefix(data.QRCode, "data:image/png;base64,")
+			if !ok {
+				t.Fatalf("qr_code is not a PNG data URL: %.40q", data.QRCode)
+			}
+			raw, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("qr_code is not base64: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("qr_code is not a PNG: %v", err)
+			}
+
+			want := size
+			if want == 0 {
+				want = 256 // the handler's default
+			}
+			if bounds := img.Bounds(); bounds.Dx() != want || bounds.Dy() != want {
+				t.Errorf("QR code is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), want, want)
+			}
+		})
+	}
+}
+
+func TestCreateEscort(t *testing.T) {
+	ts := newTestServer(t)
+
+	escort := ts.createEscort(t, map[string]any{"foto_pengantar_base64": pngDataURL(t)})
+	if escort.ID == 0 || escort.Status != models.StatusPending {
+		t.Fatalf("unexpected escort: %+v", escort)
+	}
+	if escort.FotoPengantar == nil || escort.SubmissionID == nil {
+		t.Fatalf("expected photo and submission ID, got %+v", escort)
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPost, "/api/escort", "", map[string]any{"kategori_pengantar": "Taksi"})
+		expectStatus(t, rec, http.StatusBadRequest)
+
+		var errs map[string]string
+		raw, _ := json.Marshal(decodeData(t, rec, nil).Errors)
+		json.Unmarshal(raw, &errs)
+		if errs["KategoriPengantar"] == "" || errs["NamaPengantar"] == "" {
+			t.Fatalf("expected field errors, got %v", errs)
+		}
+	})
+
+	t.Run("malformed json", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPost, "/api/escort", "", "{")
+		expectStatus(t, rec, http.StatusBadRequest)
+	})
+
+	t.Run("status is not accepted", func(t *testing.T) {
+		// Verification needs the escort:verify permission; a public submission always starts pending
+		for _, status := range []string{models.StatusVerified, models.StatusRejected} {
+			escort := ts.createEscort(t, map[string]any{"status": status, "nama_pasien": "Pasien " + status})
+			if escort.Status != models.StatusPending {
+				t.Fatalf("submission with status %q stored as %+v", status, escort)
+			}
+		}
+	})
+}
+
+func TestEscortSubmissionLookup(t *testing.T) {
+	ts := newTestServer(t)
+	staff := models.RoleIGDStaff
+
+	first := ts.createEscort(t, nil)
+	// Same plate in the same second used to produce the same submission ID
+	second := ts.createEscort(t, nil)
+
+	ticket := regexp.MustCompile(`^IGD-\d{8}-[0-9A-HJKMNP-TV-Z]{4}$`)
+	for _, escort := range []models.Escort{first, second} {
+		if escort.SubmissionID == nil || !ticket.MatchString(*escort.SubmissionID) {
+			t.Fatalf("submission ID %v is not a ticket code", escort.SubmissionID)
+		}
+	}
+	if *first.SubmissionID == *second.SubmissionID {
+		t.Fatalf("both escorts got submission ID %s", *first.SubmissionID)
+	}
+
+	t.Run("lookup", func(t *testing.T) {
+		// Codes typed at the desk may be lower case and use O/I/L for 0/1
+		cut := strings.LastIndexByte(*second.SubmissionID, '-') + 1
+		code := strings.NewReplacer("0", "o", "1", "l").Replace((*second.SubmissionID)[cut:])
+		typed := strings.ToLower((*second.SubmissionID)[:cut] + code)
+
+		for _, id := range []string{*second.SubmissionID, typed} {
+			rec := ts.do(t, http.MethodGet, "/api/escort/by-submission/"+id, staff, nil)
+			expectStatus(t, rec, http.StatusOK)
+
+			var found models.Escort
+			decodeData(t, rec, &found)
+			if found.ID != second.ID {
+				t.Fatalf("lookup of %q found escort %d, want %d", id, found.ID, second.ID)
+			}
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/escort/by-submission/IGD-20000101-0000", staff, nil)
+		expectStatus(t, rec, http.StatusNotFound)
+	})
+}
+
+func TestEscortReceipt(t *testing.T) {
+	ts := newTestServer(t)
+	staff := models.RoleIGDStaff
+
+	rec := ts.do(t, http.MethodPost, "/api/escort", "", map[string]any{
+		"kategori_pengantar": "Ambulans",
+		"nama_pengantar":     "Budi Santoso",
+		"jenis_kelamin":      "Laki-laki",
+		"nomor_hp":           "081234567890",
+		"plat_nomor":         "B 1234 CD",
+		"nama_pasien":        "Siti Aminah",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	var created struct {
+		models.Escort
+		Receipt models.ReceiptLink `json:"receipt"`
+	}
+	decodeData(t, rec, &created)
+	receipt := created.Receipt
+	if receipt.Token == "" || receipt.URL != "http://localhost:8080/api/receipt/"+receipt.Token {
+		t.Fatalf("unexpected receipt link: %+v", receipt)
+	}
+	if !receipt.ExpiresAt.After(time.Now().Add(50 * time.Minute)) {
+		t.Fatalf("receipt expires at %v, want about an hour from now", receipt.ExpiresAt)
+	}
+	qr, ok := strings.CutPrefix(receipt.QRCode, "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("QR code is not a PNG data URL: %.40s", receipt.QRCode)
+	}
+	raw, err := base64.StdEncoding.DecodeString(qr)
+	if err != nil {
+		t.Fatalf("decode QR code: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("QR code is not a valid PNG: %v", err)
+	}
+
+	path := "/api/receipt/" + receipt.Token
+
+	t.Run("masked view", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, path, "", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Fatalf("Cache-Control = %q, want no-store", got)
+		}
+
+		var view models.EscortReceipt
+		decodeData(t, rec, &view)
+		want := models.EscortReceipt{
+			SubmissionID:      *created.SubmissionID,
+			Status:            models.StatusPending,
+			StatusLabel:       "Menunggu",
+			KategoriPengantar: "Ambulans",
+			NamaPengantar:     "B*** S******",
+			NomorHP:           "0812*****890",
+			PlatNomor:         "B **** CD",
+			NamaPasien:        "S*** A*****",
+		}
+		view.SubmittedAt, view.UpdatedAt, view.ExpiresAt = time.Time{}, time.Time{}, time.Time{}
+		if view != want {
+			t.Fatalf("receipt view = %+v, want %+v", view, want)
+		}
+		for _, secret := range []string{"Budi", "Santoso", "4567", "1234", "Siti"} {
+			if strings.Contains(rec.Body.String(), secret) {
+				t.Fatalf("receipt exposes %q: %s", secret, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("follows status", func(t *testing.T) {
+		statusPath := fmt.Sprintf("/api/escort/%d/status", created.ID)
+		expectStatus(t, ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "verified"}), http.StatusOK)
+
+		var view models.EscortReceipt
+		decodeData(t, ts.do(t, http.MethodGet, path, "", nil), &view)
+		if view.Status != models.StatusVerified || view.StatusLabel != "Terverifikasi" {
+			t.Fatalf("receipt status = %s (%s), want verified", view.Status, view.StatusLabel)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		payload, signature, _ := strings.Cut(receipt.Token, ".")
+		forged := base64.RawURLEncoding.EncodeToString([]byte("IGD-20000101-0000.9999999999"))
+		for _, token := range []string{forged + "." + signature, payload + ".AAAA", "garbage"} {
+			expectStatus(t, ts.do(t, http.MethodGet, "/api/receipt/"+token, "", nil), http.StatusNotFound)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := newTestServer(t, func(c *Config) { c.Receipts.TTL = -time.Minute })
+		rec := expired.do(t, http.MethodPost, "/api/escort", "", map[string]any{
+			"kategori_pengantar": "Polisi",
+			"nama_pengantar":     "Agus Salim",
+			"jenis_kelamin":      "Laki-laki",
+			"nomor_hp":           "081298765432",
+			"plat_nomor":         "D 4321 XY",
+			"nama_pasien":        "Rina Wati",
+		})
+		decodeData(t, rec, &created)
+		expectStatus(t, expired.do(t, http.MethodGet, "/api/receipt/"+created.Receipt.Token, "", nil), http.StatusGone)
+	})
+}
+
+func TestKioskLocations(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+
+	mint := func(t *testing.T, ts *testServer, code string) models.KioskQRCode {
+		t.Helper()
+		rec := ts.do(t, http.MethodPost, "/api/kiosk-locations/"+code+"/qr", admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		var qr models.KioskQRCode
+		decodeData(t, rec, &qr)
+		return qr
+	}
+	submit := func(t *testing.T, ts *testServer, token string, want int) models.Escort {
+		t.Helper()
+		rec := ts.do(t, http.MethodPost, "/api/escort", "", map[string]any{
+			"kategori_pengantar": "Ambulans",
+			"nama_pengantar":     "Budi Santoso",
+			"jenis_kelamin":      "Laki-laki",
+			"nomor_hp":           "081234567890",
+			"plat_nomor":         "B 1234 CD",
+			"nama_pasien":        "Siti Aminah",
+			"kiosk_token":        token,
+		})
+		expectStatus(t, rec, want)
+		var escort models.Escort
+		if want == http.StatusCreated {
+			decodeData(t, rec, &escort)
+		}
+		return escort
+	}
+
+	qr := mint(t, ts, "main_entrance")
+	if qr.Location != "main_entrance" || qr.URL != "http://localhost:8000/form?kiosk="+qr.Token {
+		t.Fatalf("unexpected QR code: %+v", qr)
+	}
+	if !qr.ExpiresAt.After(time.Now().Add(23 * time.Hour)) {
+		t.Fatalf("QR code expires at %v, want a day from now", qr.ExpiresAt)
+	}
+
+	t.Run("png", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/kiosk-locations/ambulance_bay/qr?size=300&days=30", admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		img, err := png.Decode(rec.Body)
+		if err != nil || img.Bounds().Dx() != 300 {
+			t.Fatalf("QR code PNG = %v, %v; want a 300px image", img, err)
+		}
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/kiosk-locations/unknown/qr", admin, nil), http.StatusNotFound)
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/kiosk-locations/ambulance_bay/qr?days=0&size=50", admin, nil), http.StatusBadRequest)
+	})
+
+	t.Run("attribution", func(t *testing.T) {
+		escort := submit(t, ts, qr.Token, http.StatusCreated)
+		if escort.LocationCode == nil || *escort.LocationCode != "main_entrance" {
+			t.Fatalf("location_code = %v, want main_entrance", escort.LocationCode)
+		}
+		if escort := submit(t, ts, "", http.StatusCreated); escort.LocationCode != nil {
+			t.Fatalf("plain form submission got location %s", *escort.LocationCode)
+		}
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		payload, signature, _ := strings.Cut(qr.Token, ".")
+		forged := base64.RawURLEncoding.EncodeToString([]byte("police_post.9999999999"))
+
+		// A receipt token is signed for another purpose
+		var created struct {
+			Receipt models.ReceiptLink `json:"receipt"`
+		}
+		decodeData(t, ts.do(t, http.MethodPost, "/api/escort", "", map[string]any{
+			"kategori_pengantar": "Perorangan",
+			"nama_pengantar":     "Dewi Lestari",
+			"jenis_kelamin":      "Perempuan",
+			"nomor_hp":           "085711112222",
+			"plat_nomor":         "F 9 AB",
+			"nama_pasien":        "Rudi Hartono",
+		}), &created)
+
+		for _, token := range []string{forged + "." + signature, payload + ".AAAA", "garbage", created.Receipt.Token} {
+			submit(t, ts, token, http.StatusForbidden)
+		}
+	})
+
+	t.Run("deactivated location", func(t *testing.T) {
+		police := mint(t, ts, "police_post")
+		submit(t, ts, police.Token, http.StatusCreated)
+
+		expectStatus(t, ts.do(t, http.MethodDelete, "/api/kiosk-locations/police_post", admin, nil), http.StatusOK)
+		submit(t, ts, police.Token, http.StatusForbidden)
+		expectStatus(t, ts.do(t, http.MethodPost, "/api/kiosk-locations/police_post/qr", admin, nil), http.StatusConflict)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/reports/kiosk-locations", models.RoleAuditor, nil)
+		expectStatus(t, rec, http.StatusOK)
+
+		var rows []models.LocationReportRow
+		decodeData(t, rec, &rows)
+		got := map[string]int64{}
+		for _, row := range rows {
+			code := "-"
+			if row.LocationCode != nil {
+				code = *row.LocationCode
+			}
+			got[code] = row.Total
+		}
+		// The deactivated police post stays in the report for the submission made from it
+		want := map[string]int64{"ambulance_bay": 0, "main_entrance": 1, "police_post": 1, "-": 2}
+		if !maps.Equal(got, want) {
+			t.Fatalf("report totals = %v, want %v", got, want)
+		}
+		if last := rows[len(rows)-1]; last.LocationCode != nil || last.Pending != 2 {
+			t.Fatalf("last row = %+v, want the 2 pending submissions without a location", last)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/reports/kiosk-locations?start_date=16-10-2026", models.RoleAuditor, nil), http.StatusBadRequest)
+
+		rec = ts.do(t, http.MethodGet, "/api/reports/kiosk-locations?end_date=2000-01-01", models.RoleAuditor, nil)
+		decodeData(t, rec, &rows)
+		for _, row := range rows {
+			if row.Total != 0 {
+				t.Fatalf("report before any submission has %+v", row)
+			}
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := newTestServer(t, func(c *Config) { c.Kiosks.TTL = -time.Minute })
+		submit(t, expired, mint(t, expired, "main_entrance").Token, http.StatusForbidden)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		required := newTestServer(t, func(c *Config) { c.Kiosks.Required = true })
+		submit(t, required, "", http.StatusForbidden)
+		submit(t, required, mint(t, required, "main_entrance").Token, http.StatusCreated)
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+
+	t.Run("missing token", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/escort", "", nil)
+		expectStatus(t, rec, http.StatusUnauthorized)
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatal("expected WWW-Authenticate header")
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		ts.tokens["bogus"] = "1|wrong"
+		rec := ts.do(t, http.MethodGet, "/api/escort", "bogus", nil)
+		expectStatus(t, rec, http.StatusUnauthorized)
+	})
+
+	t.Run("token without ability", func(t *testing.T) {
+		ts.tokens["limited"] = ts.createToken(t, models.RoleAdmin, []string{"escort:view"})
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort", "limited", nil), http.StatusOK)
+		expectStatus(t, ts.do(t, http.MethodDelete, "/api/escort/1", "limited", nil), http.StatusForbidden)
+	})
+}
+
+// TestRoutePermissions checks every protected route against the role matrix in models.RolePermissions
+func TestRoutePermissions(t *testing.T) {
+	routes := []struct {
+		method     string
+		path       string
+		permission string
+	}{
+		{http.MethodGet, "/api/escort", models.PermissionEscortView},
+		{http.MethodGet, "/api/escort/export", models.PermissionEscortView},
+		{http.MethodGet, "/api/escort/%d", models.PermissionEscortView},
+		{http.MethodPut, "/api/escort/%d", models.PermissionEscortUpdate},
+		{http.MethodPatch, "/api/escort/%d", models.PermissionEscortUpdate},
+		{http.MethodPatch, "/api/escort/%d/status", models.PermissionEscortVerify},
+		{http.MethodGet, "/api/escort/%d/history", models.PermissionEscortView},
+		{http.MethodGet, "/api/escort/by-submission/IGD-20261016-0000", models.PermissionEscortView},
+		{http.MethodGet, "/api/escort/%d/image", models.PermissionEscortView},
+		{http.MethodPost, "/api/escort/%d/image", models.PermissionEscortUpdate},
+		{http.MethodGet, "/api/escort/%d/image/base64", models.PermissionEscortView},
+		{http.MethodPost, "/api/escort/%d/image/base64", models.PermissionEscortUpdate},
+		{http.MethodDelete, "/api/escort/%d", models.PermissionEscortDelete},
+		{http.MethodGet, "/api/rejection-reasons", models.PermissionEscortView},
+		{http.MethodPost, "/api/rejection-reasons", models.PermissionReasonsManage},
+		{http.MethodPut, "/api/rejection-reasons/duplikat", models.PermissionReasonsManage},
+		{http.MethodDelete, "/api/rejection-reasons/duplikat", models.PermissionReasonsManage},
+		{http.MethodGet, "/api/kiosk-locations", models.PermissionEscortView},
+		{http.MethodPost, "/api/kiosk-locations", models.PermissionKiosksManage},
+		{http.MethodPut, "/api/kiosk-locations/unknown", models.PermissionKiosksManage},
+		{http.MethodDelete, "/api/kiosk-locations/unknown", models.PermissionKiosksManage},
+		{http.MethodGet, "/api/kiosk-locations/unknown/qr", models.PermissionKiosksManage},
+		{http.MethodPost, "/api/kiosk-locations/unknown/qr", models.PermissionKiosksManage},
+		{http.MethodGet, "/api/reports/kiosk-locations", models.PermissionDashboardView},
+		{http.MethodGet, "/api/dashboard/stats", models.PermissionDashboardView},
+		{http.MethodGet, "/api/webhooks", models.PermissionWebhooksManage},
+		{http.MethodPost, "/api/webhooks", models.PermissionWebhooksManage},
+		{http.MethodPut, "/api/webhooks/9999", models.PermissionWebhooksManage},
+		{http.MethodDelete, "/api/webhooks/9999", models.PermissionWebhooksManage},
+		{http.MethodGet, "/api/webhooks/deliveries", models.PermissionWebhooksManage},
+		{http.MethodGet, "/api/webhooks/dead-letters", models.PermissionWebhooksManage},
+		{http.MethodPost, "/api/webhooks/deliveries/9999/redeliver", models.PermissionWebhooksManage},
+		{http.MethodGet, "/api/storage/scan", models.PermissionStorageManage},
+		{http.MethodGet, "/api/v1/users", models.PermissionUsersManage},
+		{http.MethodPost, "/api/v1/users", models.PermissionUsersManage},
+		{http.MethodGet, "/api/v1/users/9999", models.PermissionUsersManage},
+		{http.MethodPut, "/api/v1/users/9999", models.PermissionUsersManage},
+		{http.MethodDelete, "/api/v1/users/9999", models.PermissionUsersManage},
+	}
+
+	ts := newTestServer(t)
+	escort := ts.createEscort(t, nil)
+
+	for _, role := range models.Roles {
+		for _, route := range routes {
+			path := route.path
+			if strings.Contains(path, "%d") {
+				path = fmt.Sprintf(path, escort.ID)
+			}
+
+			t.Run(role+" "+route.method+" "+path, func(t *testing.T) {
+				// An empty JSON object fails validation after authorization, so nothing is changed
+				rec := ts.do(t, route.method, path, role, "{}")
+				forbidden := rec.Code == http.StatusForbidden
+				if allowed := models.RoleHasPermission(role, route.permission); allowed == forbidden {
+					t.Fatalf("allowed = %v but status = %d; body: %s", allowed, rec.Code, rec.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestEscortCRUD(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+
+	first := ts.createEscort(t, map[string]any{"nama_pasien": "Ahmad Fauzi", "kategori_pengantar": "Polisi"})
+	second := ts.createEscort(t, nil)
+
+	t.Run("list with filters and pagination", func(t *testing.T) {
+		var escorts []models.Escort
+		rec := ts.do(t, http.MethodGet, "/api/escort?per_page=1&page=2", admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		resp := decodeData(t, rec, &escorts)
+		if len(escorts) != 1 || escorts[0].ID != first.ID {
+			t.Fatalf("page 2 = %+v, want escort %d", escorts, first.ID)
+		}
+		if resp.Meta == nil || resp.Meta.Total != 2 || resp.Meta.TotalPages != 2 {
+			t.Fatalf("unexpected meta: %+v", resp.Meta)
+		}
+
+		rec = ts.do(t, http.MethodGet, "/api/escort?search=fauzi&kategori_pengantar=Polisi", admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &escorts)
+		if len(escorts) != 1 || escorts[0].ID != first.ID {
+			t.Fatalf("search = %+v, want escort %d", escorts, first.ID)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		var escort models.Escort
+		rec := ts.do(t, http.MethodGet, fmt.Sprintf("/api/escort/%d", second.ID), admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &escort)
+		if escort.NamaPasien != second.NamaPasien {
+			t.Fatalf("got %+v", escort)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/9999", admin, nil), http.StatusNotFound)
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/abc", admin, nil), http.StatusBadRequest)
+	})
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		t.Run("update "+method, func(t *testing.T) {
+			var escort models.Escort
+			name := "Pasien " + method
+			rec := ts.do(t, method, fmt.Sprintf("/api/escort/%d", second.ID), admin, map[string]any{"nama_pasien": name})
+			expectStatus(t, rec, http.StatusOK)
+			decodeData(t, rec, &escort)
+			if escort.NamaPasien != name || escort.NamaPengantar != second.NamaPengantar {
+				t.Fatalf("got %+v", escort)
+			}
+
+			rec = ts.do(t, method, fmt.Sprintf("/api/escort/%d", second.ID), admin, map[string]any{"jenis_kelamin": "X"})
+			expectStatus(t, rec, http.StatusBadRequest)
+			expectStatus(t, ts.do(t, method, "/api/escort/9999", admin, map[string]any{"nama_pasien": name}), http.StatusNotFound)
+		})
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/escort/%d", first.ID), admin, nil), http.StatusOK)
+		expectStatus(t, ts.do(t, http.MethodGet, fmt.Sprintf("/api/escort/%d", first.ID), admin, nil), http.StatusNotFound)
+		expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/escort/%d", first.ID), admin, nil), http.StatusNotFound)
+	})
+}
+
+func TestEscortStatusWorkflow(t *testing.T) {
+	ts := newTestServer(t)
+	escort := ts.createEscort(t, nil)
+	statusPath := fmt.Sprintf("/api/escort/%d/status", escort.ID)
+	staff := models.RoleIGDStaff
+
+	t.Run("reject requires a reason", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "rejected"})
+		expectStatus(t, rec, http.StatusBadRequest)
+
+		rec = ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "rejected", "reason_code": "unknown"})
+		expectStatus(t, rec, http.StatusBadRequest)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		var updated models.Escort
+		rec := ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{
+			"status": "rejected", "reason_code": "duplikat", "note": "Sudah terdaftar",
+		})
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &updated)
+		if updated.Status != models.StatusRejected || updated.RejectionReason == nil || *updated.RejectionReason != "duplikat" {
+			t.Fatalf("got %+v", updated)
+		}
+	})
+
+	t.Run("invalid transition", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "verified"})
+		expectStatus(t, rec, http.StatusConflict)
+	})
+
+	t.Run("reopen and verify", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "pending"}), http.StatusOK)
+
+		var updated models.Escort
+		rec := ts.do(t, http.MethodPatch, statusPath, staff, map[string]any{"status": "verified"})
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &updated)
+		if updated.Status != models.StatusVerified || updated.RejectionReason != nil {
+			t.Fatalf("got %+v", updated)
+		}
+	})
+
+	t.Run("unknown escort", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPatch, "/api/escort/9999/status", staff, map[string]any{"status": "verified"})
+		expectStatus(t, rec, http.StatusNotFound)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		var history []models.EscortStatusHistory
+		rec := ts.do(t, http.MethodGet, fmt.Sprintf("/api/escort/%d/history", escort.ID), staff, nil)
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &history)
+
+		want := []string{models.StatusPending, models.StatusRejected, models.StatusPending, models.StatusVerified}
+		if len(history) != len(want) {
+			t.Fatalf("history has %d entries, want %d: %+v", len(history), len(want), history)
+		}
+		for i, entry := range history {
+			if entry.NewStatus != want[i] {
+				t.Fatalf("entry %d status = %s, want %s", i, entry.NewStatus, want[i])
+			}
+		}
+		rejected := history[1]
+		if rejected.ReasonLabel == nil || *rejected.ReasonLabel != "Duplikat" {
+			t.Fatalf("reason label not resolved: %+v", rejected)
+		}
+		if rejected.ChangedByName == nil || *rejected.ChangedByName != "Test "+staff {
+			t.Fatalf("user name not resolved: %+v", rejected)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/9999/history", staff, nil), http.StatusNotFound)
+	})
+
+	t.Run("escort without history", func(t *testing.T) {
+		// Escorts written by Laravel's legacy path or before history existed have no rows
+		legacy := &models.Escort{
+			Status:        models.StatusPending,
+			NamaPengantar: "Budi Santoso",
+			NomorHP:       "081234567890",
+			PlatNomor:     "B 1234 CD",
+			NamaPasien:    "Siti Aminah",
+		}
+		if err := ts.store.Escorts.Create(context.Background(), legacy); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := ts.do(t, http.MethodGet, fmt.Sprintf("/api/escort/%d/history", legacy.ID), staff, nil)
+		expectStatus(t, rec, http.StatusOK)
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || string(resp.Data) != "[]" {
+			t.Fatalf("data = %s (%v), want []", resp.Data, err)
+		}
+	})
+}
+
+func TestEscortImageBase64(t *testing.T) {
+	ts := newTestServer(t)
+	escort := ts.createEscort(t, nil)
+	imagePath := fmt.Sprintf("/api/escort/%d/image/base64", escort.ID)
+	admin := models.RoleAdmin
+
+	expectStatus(t, ts.do(t, http.MethodGet, imagePath, admin, nil), http.StatusNotFound)
+
+	dataURL := pngDataURL(t)
+	expectStatus(t, ts.do(t, http.MethodPost, imagePath, admin, map[string]any{"image_base64": dataURL}), http.StatusOK)
+	expectStatus(t, ts.do(t, http.MethodPost, imagePath, admin, map[string]any{}), http.StatusBadRequest)
+	expectStatus(t, ts.do(t, http.MethodPost, "/api/escort/9999/image/base64", admin, map[string]any{"image_base64": dataURL}), http.StatusNotFound)
+
+	var data struct {
+		ImageBase64 string `json:"image_base64"`
+	}
+	rec := ts.do(t, http.MethodGet, imagePath, admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &data)
+	if data.ImageBase64 != dataURL {
+		t.Fatalf("image round trip mismatch: %q", data.ImageBase64)
+	}
+}
+
+func TestEscortImage(t *testing.T) {
+	ts := newTestServer(t)
+	escort := ts.createEscort(t, nil)
+	imagePath := fmt.Sprintf("/api/escort/%d/image", escort.ID)
+	admin := models.RoleAdmin
+	photo := pngBytes(t)
+
+	expectStatus(t, ts.do(t, http.MethodGet, imagePath, admin, nil), http.StatusNotFound)
+
+	t.Run("upload errors", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodPost, imagePath, admin, map[string]any{}), http.StatusBadRequest)
+		expectStatus(t, ts.serve(t, multipartRequest(t, imagePath, "other", photo), admin), http.StatusBadRequest)
+		expectImageError(t, ts.serve(t, multipartRequest(t, imagePath, "foto_pengantar", []byte("<html>not an image</html>")), admin),
+			http.StatusBadRequest, services.ImageCodeUnsupportedType)
+		expectImageError(t, ts.do(t, http.MethodPost, imagePath+"/base64", admin, map[string]any{"image_base64": "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(photo)}),
+			http.StatusBadRequest, services.ImageCodeTypeMismatch)
+		expectImageError(t, ts.serve(t, multipartRequest(t, imagePath, "foto_pengantar", append(photo, make([]byte, 2*1024*1024)...)), admin),
+			http.StatusRequestEntityTooLarge, services.ImageCodeTooLarge)
+		expectStatus(t, ts.serve(t, multipartRequest(t, "/api/escort/9999/image", "foto_pengantar", photo), admin), http.StatusNotFound)
+	})
+
+	var updated models.Escort
+	rec := ts.serve(t, multipartRequest(t, imagePath, "foto_pengantar", photo), admin)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &updated)
+	if updated.FotoPengantar == nil || !strings.HasSuffix(*updated.FotoPengantar, ".png") {
+		t.Fatalf("foto_pengantar = %v", updated.FotoPengantar)
+	}
+
+	rec = ts.do(t, http.MethodGet, imagePath, models.RoleAuditor, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !bytes.Equal(rec.Body.Bytes(), photo) {
+		t.Fatal("streamed image differs from the upload")
+	}
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Content-Type") != "image/png" || etag == "" || rec.Header().Get("Last-Modified") == "" || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	t.Run("range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, imagePath, nil)
+		req.Header.Set("Range", "bytes=0-3")
+		rec := ts.serve(t, req, admin)
+		expectStatus(t, rec, http.StatusPartialContent)
+		if !bytes.Equal(rec.Body.Bytes(), photo[:4]) || rec.Header().Get("Content-Range") != fmt.Sprintf("bytes 0-3/%d", len(photo)) {
+			t.Fatalf("range response %q, Content-Range %q", rec.Body.Bytes(), rec.Header().Get("Content-Range"))
+		}
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, imagePath, nil)
+		req.Header.Set("If-None-Match", etag)
+		expectStatus(t, ts.serve(t, req, admin), http.StatusNotModified)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		rec := ts.do(t, http.MethodHead, imagePath, admin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		if rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != fmt.Sprint(len(photo)) {
+			t.Fatalf("HEAD body %d bytes, Content-Length %q", rec.Body.Len(), rec.Header().Get("Content-Length"))
+		}
+	})
+
+	t.Run("base64 endpoint serves the same photo", func(t *testing.T) {
+		var data struct {
+			ImageBase64 string `json:"image_base64"`
+		}
+		decodeData(t, ts.do(t, http.MethodGet, imagePath+"/base64", admin, nil), &data)
+		if data.ImageBase64 != pngDataURL(t) {
+			t.Fatalf("base64 image = %q", data.ImageBase64)
+		}
+	})
+}
+
+func TestEscortImageVariants(t *testing.T) {
+	ts := newTestServer(t)
+	escort := ts.createEscort(t, nil)
+	imagePath := fmt.Sprintf("/api/escort/%d/image", escort.ID)
+	admin := models.RoleAdmin
+	ctx := context.Background()
+
+	var updated models.Escort
+	rec := ts.serve(t, multipartRequest(t, imagePath, "foto_pengantar", pngOfSize(t, 800, 400)), admin)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &updated)
+	key := *updated.FotoPengantar
+	thumbKey := strings.TrimSuffix(key, ".png") + ".thumb.jpg"
+
+	// variantSize fetches a variant and returns its dimensions
+	variantSize := func(t *testing.T, variant string) image.Point {
+		t.Helper()
+		rec := ts.do(t, http.MethodGet, imagePath+"?variant="+variant, models.RoleAuditor, nil)
+		expectStatus(t, rec, http.StatusOK)
+		if rec.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("%s Content-Type = %q", variant, rec.Header().Get("Content-Type"))
+		}
+		config, _, err := image.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Fatalf("decode %s: %v", variant, err)
+		}
+		return image.Pt(config.Width, config.Height)
+	}
+
+	if size := variantSize(t, "thumb"); size != image.Pt(160, 80) {
+		t.Fatalf("thumb size = %v, want 160x80", size)
+	}
+	if size := variantSize(t, "medium"); size != image.Pt(640, 320) {
+		t.Fatalf("medium size = %v, want 640x320", size)
+	}
+	expectStatus(t, ts.do(t, http.MethodGet, imagePath+"?variant=huge", admin, nil), http.StatusBadRequest)
+
+	t.Run("missing variants are recreated", func(t *testing.T) {
+		if err := ts.files.Delete(ctx, thumbKey); err != nil {
+			t.Fatal(err)
+		}
+		if size := variantSize(t, "thumb"); size != image.Pt(160, 80) {
+			t.Fatalf("recreated thumb size = %v", size)
+		}
+
+		ts.files.Delete(ctx, thumbKey)
+		created, err := services.NewEscortService(ts.store, ts.files).BackfillImageVariants(ctx)
+		if err != nil || created != 1 {
+			t.Fatalf("backfill created %d, error %v; want 1", created, err)
+		}
+		if _, err := ts.files.Stat(ctx, thumbKey); err != nil {
+			t.Fatalf("backfill did not create the thumbnail: %v", err)
+		}
+	})
+
+	t.Run("delete removes variants", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/escort/%d", escort.ID), admin, nil), http.StatusOK)
+		if _, err := ts.files.Stat(ctx, thumbKey); !errors.Is(err, filestore.ErrNotFound) {
+			t.Fatalf("thumbnail left behind (Stat error %v)", err)
+		}
+	})
+}
+
+func TestEscortExport(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createEscort(t, map[string]any{"nama_pasien": "Pasien Satu"})
+	ts.createEscort(t, map[string]any{"nama_pasien": "Pasien Dua", "kategori_pengantar": "Polisi"})
+	today := time.Now().Format("2006-01-02")
+	staff := models.RoleIGDStaff
+
+	t.Run("csv", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/escort/export?format=csv&start_date="+today+"&end_date="+today, staff, nil)
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+			t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Header().Get("Content-Disposition"), "_sampai_") {
+			t.Fatalf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+		}
+
+		body := strings.TrimPrefix(rec.Body.String(), "\xEF\xBB\xBF")
+		if !strings.Contains(body, strings.Join(services.ExportHeadings, ",")) {
+			t.Fatalf("CSV headings missing:\n%s", body)
+		}
+		if !strings.Contains(body, "Pasien Satu") || !strings.Contains(body, "Pasien Dua") {
+			t.Fatalf("CSV rows missing:\n%s", body)
+		}
+	})
+
+	t.Run("xlsx with filters", func(t *testing.T) {
+		rec := ts.do(t, http.MethodGet, "/api/escort/export?format=xlsx&kategori_pengantar=Polisi", staff, nil)
+		expectStatus(t, rec, http.StatusOK)
+
+		file, err := excelize.OpenReader(rec.Body)
+		if err != nil {
+			t.Fatalf("open xlsx: %v", err)
+		}
+		defer file.Close()
+
+		rows, err := file.GetRows(file.GetSheetName(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("xlsx has %d rows, want heading + 1: %v", len(rows), rows)
+		}
+		if strings.Join(rows[0], ",") != strings.Join(services.ExportHeadings, ",") {
+			t.Fatalf("xlsx headings = %v", rows[0])
+		}
+		if rows[1][1] != "Polisi" || rows[1][6] != "Pasien Dua" || rows[1][4] != "081234567890" {
+			t.Fatalf("xlsx row = %v", rows[1])
+		}
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		for _, query := range []string{
+			"format=pdf",
+			"start_date=16-10-2026",
+			"start_date=2026-10-16&end_date=2026-10-01",
+		} {
+			rec := ts.do(t, http.MethodGet, "/api/escort/export?"+query, staff, nil)
+			expectStatus(t, rec, http.StatusBadRequest)
+			if rec.Header().Get("Content-Disposition") != "" {
+				t.Fatalf("%s: error response kept the attachment header", query)
+			}
+		}
+	})
+}
+
+// sseEvent is one Server-Sent Event read from a stream
+type sseEvent struct {
+	id   string
+	name string
+	data string
+}
+
+// openStream connects to the escort stream as role, resuming from lastEventID when it is not empty
+func openStream(t *testing.T, server *httptest.Server, ts *testServer, role, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/escort/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.tokens[role])
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// nextEvent reads the next named event, skipping the retry hint and pings
+func nextEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event.name != "" && event.name != "ping":
+			return event
+		case line == "":
+			event = sseEvent{}
+		}
+	}
+}
+
+func TestEscortStream(t *testing.T) {
+	ts := newTestServer(t)
+	server := httptest.NewServer(ts.router)
+	// Registered first so it runs after the streams opened below are closed
+	t.Cleanup(server.Close)
+
+	expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/stream", "", nil), http.StatusUnauthorized)
+
+	stream := openStream(t, server, ts, models.RoleAuditor, "")
+
+	expectEvent := func(t *testing.T, r *bufio.Reader, name string, escortID uint) models.EscortEvent {
+		t.Helper()
+		received := nextEvent(t, r)
+		var event models.EscortEvent
+		if err := json.Unmarshal([]byte(received.data), &event); err != nil {
+			t.Fatalf("decode event %q: %v", received.data, err)
+		}
+		if received.name != name || event.Type != name || event.EscortID != escortID || received.id != fmt.Sprint(event.ID) {
+			t.Fatalf("got event %+v (%+v), want %s for escort %d", received, event, name, escortID)
+		}
+		return event
+	}
+
+	escort := ts.createEscort(t, nil)
+	created := expectEvent(t, stream, models.EscortEventCreated, escort.ID)
+	if created.Escort == nil || created.Escort.NamaPengantar != escort.NamaPengantar {
+		t.Fatalf("created event carries %+v", created.Escort)
+	}
+
+	statusPath := fmt.Sprintf("/api/escort/%d/status", escort.ID)
+	expectStatus(t, ts.do(t, http.MethodPatch, statusPath, models.RoleIGDStaff, map[string]any{"status": "verified"}), http.StatusOK)
+	// A rejected transition rolls back and publishes nothing
+	expectStatus(t, ts.do(t, http.MethodPatch, statusPath, models.RoleIGDStaff, map[string]any{"status": "rejected", "reason_code": "duplikat"}), http.StatusConflict)
+	expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/escort/%d", escort.ID), models.RoleAdmin, nil), http.StatusOK)
+
+	changed := expectEvent(t, stream, models.EscortEventStatusChanged, escort.ID)
+	if changed.OldStatus == nil || *changed.OldStatus != models.StatusPending || changed.Escort.Status != models.StatusVerified {
+		t.Fatalf("status event = %+v", changed)
+	}
+	expectEvent(t, stream, models.EscortEventDeleted, escort.ID)
+
+	t.Run("resume from Last-Event-ID", func(t *testing.T) {
+		resumed := openStream(t, server, ts, models.RoleIGDStaff, fmt.Sprint(created.ID))
+		expectEvent(t, resumed, models.EscortEventStatusChanged, escort.ID)
+		expectEvent(t, resumed, models.EscortEventDeleted, escort.ID)
+	})
+
+	t.Run("unknown Last-Event-ID asks for a resync", func(t *testing.T) {
+		resumed := openStream(t, server, ts, models.RoleIGDStaff, "999999")
+		if event := nextEvent(t, resumed); event.name != "resync" {
+			t.Fatalf("got %+v, want resync", event)
+		}
+	})
+
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/stream?last_event_id=abc", models.RoleIGDStaff, nil), http.StatusBadRequest)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+	const secret = "receiver-secret-0001"
+
+	// Local stand-ins for the receiving systems: one checks signatures, one fails until told otherwise
+	var mu sync.Mutex
+	var received []models.EscortEvent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(services.WebhookHeaderTimestamp), 10, 64)
+		if r.Header.Get(services.WebhookHeaderSignature) != services.SignWebhookPayload(secret, timestamp, body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event models.EscortEvent
+		json.Unmarshal(body, &event)
+		if event.Type != r.Header.Get(services.WebhookHeaderEvent) {
+			http.Error(w, "event header mismatch", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	failing := true
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+
+	t.Run("validation", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPost, "/api/webhooks", admin, map[string]any{"url": receiver.URL, "events": []string{"escort.exploded"}})
+		expectStatus(t, rec, http.StatusBadRequest)
+		rec = ts.do(t, http.MethodPost, "/api/webhooks", admin, map[string]any{"url": "not a url", "events": []string{"escort.created"}})
+		expectStatus(t, rec, http.StatusBadRequest)
+	})
+
+	var sub, flakySub models.WebhookSubscription
+	rec := ts.do(t, http.MethodPost, "/api/webhooks", admin, map[string]any{
+		"url": receiver.URL, "events": []string{"escort.created", "escort.verified"}, "secret": secret,
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	decodeData(t, rec, &sub)
+	if sub.Secret != secret {
+		t.Fatalf("created subscription secret = %q", sub.Secret)
+	}
+
+	rec = ts.do(t, http.MethodPost, "/api/webhooks", admin, map[string]any{"url": flaky.URL, "events": []string{"escort.created"}})
+	expectStatus(t, rec, http.StatusCreated)
+	decodeData(t, rec, &flakySub)
+	if len(flakySub.Secret) != 64 {
+		t.Fatalf("generated secret = %q", flakySub.Secret)
+	}
+
+	var subs []models.WebhookSubscription
+	decodeData(t, ts.do(t, http.MethodGet, "/api/webhooks", admin, nil), &subs)
+	if len(subs) != 2 || subs[0].Secret != "" || subs[1].Secret != "" {
+		t.Fatalf("listed subscriptions = %+v", subs)
+	}
+
+	// Only escort.created and escort.verified are subscribed; the update is not delivered
+	escort := ts.createEscort(t, nil)
+	expectStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/api/escort/%d", escort.ID), admin, map[string]any{"nama_pasien": "Siti Rahma"}), http.StatusOK)
+	expectStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/api/escort/%d/status", escort.ID), admin, map[string]any{"status": "verified"}), http.StatusOK)
+
+	dispatcher := services.NewWebhookDispatcher(ts.store.Webhooks, 2, 0)
+	dispatch := func(t *testing.T, want int) {
+		t.Helper()
+		sent, err := dispatcher.DispatchDue(t.Context())
+		if err != nil || sent != want {
+			t.Fatalf("DispatchDue = %d, %v; want %d", sent, err, want)
+		}
+	}
+
+	dispatch(t, 3)
+	mu.Lock()
+	if len(received) != 2 {
+		t.Fatalf("receiver got %d events, want 2", len(received))
+	}
+	for _, event := range received {
+		if event.EscortID != escort.ID || (event.Type != models.EscortEventCreated && event.Type != models.WebhookEventVerified) {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+	mu.Unlock()
+
+	t.Run("dead letter after the last attempt", func(t *testing.T) {
+		dispatch(t, 1)
+		dispatch(t, 0)
+
+		var dead []models.WebhookDelivery
+		decodeData(t, ts.do(t, http.MethodGet, "/api/webhooks/dead-letters", admin, nil), &dead)
+		if len(dead) != 1 {
+			t.Fatalf("dead letters = %+v", dead)
+		}
+		if dead[0].URL != flaky.URL || dead[0].Attempts != 2 || dead[0].LastStatusCode == nil || *dead[0].LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("dead letter = %+v", dead[0])
+		}
+
+		mu.Lock()
+		failing = false
+		mu.Unlock()
+
+		expectStatus(t, ts.do(t, http.MethodPost, fmt.Sprintf("/api/webhooks/deliveries/%d/redeliver", dead[0].ID), admin, nil), http.StatusAccepted)
+		dispatch(t, 1)
+
+		decodeData(t, ts.do(t, http.MethodGet, "/api/webhooks/dead-letters", admin, nil), &dead)
+		if len(dead) != 0 {
+			t.Fatalf("dead letters after redelivery = %+v", dead)
+		}
+		response := decodeData(t, ts.do(t, http.MethodGet, "/api/webhooks/deliveries?status=delivered", admin, nil), nil)
+		if response.Meta == nil || response.Meta.Total != 3 {
+			t.Fatalf("delivered meta = %+v", response.Meta)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodPost, "/api/webhooks/deliveries/9999/redeliver", admin, nil), http.StatusNotFound)
+	})
+
+	t.Run("deactivate and delete", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodPut, fmt.Sprintf("/api/webhooks/%d", sub.ID), admin, map[string]any{"active": false}), http.StatusOK)
+		ts.createEscort(t, nil)
+		dispatch(t, 1) // only the flaky subscription is still active
+
+		expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/webhooks/%d", flakySub.ID), admin, nil), http.StatusOK)
+		expectStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/api/webhooks/%d", flakySub.ID), admin, nil), http.StatusNotFound)
+
+		response := decodeData(t, ts.do(t, http.MethodGet, fmt.Sprintf("/api/webhooks/deliveries?subscription_id=%d", flakySub.ID), admin, nil), nil)
+		if response.Meta.Total != 0 {
+			t.Fatalf("deliveries of a deleted subscription remain: %+v", response.Meta)
+		}
+	})
+}
+
+func TestDashboardStats(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createEscort(t, nil)
+	verified := ts.createEscort(t, map[string]any{"kategori_pengantar": "Polisi"})
+	statusPath := fmt.Sprintf("/api/escort/%d/status", verified.ID)
+	expectStatus(t, ts.do(t, http.MethodPatch, statusPath, models.RoleAdmin, map[string]any{"status": "verified"}), http.StatusOK)
+
+	var stats models.DashboardStats
+	rec := ts.do(t, http.MethodGet, "/api/dashboard/stats", models.RoleAuditor, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &stats)
+
+	if stats.TotalEscorts != 2 || stats.PendingEscorts != 1 || stats.VerifiedEscorts != 1 || stats.TodaySubmissions != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.CategoryStats["Polisi"] != 1 || len(stats.RecentEscorts) != 2 {
+		t.Fatalf("unexpected breakdown: %+v", stats)
+	}
+}
+
+func TestRejectionReasons(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+
+	var reasons []models.RejectionReason
+	rec := ts.do(t, http.MethodGet, "/api/rejection-reasons", models.RoleSecurity, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &reasons)
+	if len(reasons) != 3 {
+		t.Fatalf("expected the 3 seeded reasons, got %+v", reasons)
+	}
+
+	rec = ts.do(t, http.MethodPost, "/api/rejection-reasons", admin, map[string]any{"code": "alamat_salah", "label": "Alamat salah"})
+	expectStatus(t, rec, http.StatusCreated)
+	expectStatus(t, ts.do(t, http.MethodPost, "/api/rejection-reasons", admin, map[string]any{"code": "x"}), http.StatusBadRequest)
+
+	var reason models.RejectionReason
+	rec = ts.do(t, http.MethodPut, "/api/rejection-reasons/alamat_salah", admin, map[string]any{"label": "Alamat tidak sesuai"})
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &reason)
+	if reason.Label != "Alamat tidak sesuai" || !reason.Active {
+		t.Fatalf("got %+v", reason)
+	}
+	expectStatus(t, ts.do(t, http.MethodPut, "/api/rejection-reasons/missing", admin, map[string]any{"label": "Tidak ada"}), http.StatusNotFound)
+
+	expectStatus(t, ts.do(t, http.MethodDelete, "/api/rejection-reasons/alamat_salah", admin, nil), http.StatusOK)
+	expectStatus(t, ts.do(t, http.MethodDelete, "/api/rejection-reasons/missing", admin, nil), http.StatusNotFound)
+
+	rec = ts.do(t, http.MethodGet, "/api/rejection-reasons", admin, nil)
+	decodeData(t, rec, &reasons)
+	if len(reasons) != 3 {
+		t.Fatalf("deactivated reason still listed: %+v", reasons)
+	}
+	rec = ts.do(t, http.MethodGet, "/api/rejection-reasons?include_inactive=true", admin, nil)
+	decodeData(t, rec, &reasons)
+	if len(reasons) != 4 {
+		t.Fatalf("include_inactive should list 4 reasons, got %+v", reasons)
+	}
+
+	t.Run("existing code", func(t *testing.T) {
+		// History refers to the code, so creating it again must not relabel or reactivate it
+		rec := ts.do(t, http.MethodPost, "/api/rejection-reasons", admin, map[string]any{"code": "alamat_salah", "label": "Lain"})
+		expectStatus(t, rec, http.StatusConflict)
+		rec = ts.do(t, http.MethodPost, "/api/rejection-reasons", admin, map[string]any{"code": "foto_tidak_jelas", "label": "Lain"})
+		expectStatus(t, rec, http.StatusConflict)
+
+		rec = ts.do(t, http.MethodPut, "/api/rejection-reasons/alamat_salah", admin, map[string]any{"active": true})
+		expectStatus(t, rec, http.StatusOK)
+		decodeData(t, rec, &reason)
+		if reason.Label != "Alamat tidak sesuai" || !reason.Active {
+			t.Fatalf("reactivated reason = %+v", reason)
+		}
+	})
+}
+
+func TestLegacyUsers(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+
+	rec := ts.do(t, http.MethodPost, "/api/v1/users", admin, map[string]any{
+		"name": "Petugas Baru", "email": "petugas@igd.test", "password": "rahasia123",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	var created struct {
+		ID uint `json:"id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	stored, err := ts.store.Users.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("user not stored: %v", err)
+	}
+	if stored.Role != models.DefaultRole || stored.Password == "rahasia123" {
+		t.Fatalf("expected default role and hashed password, got %+v", stored)
+	}
+
+	expectStatus(t, ts.do(t, http.MethodPost, "/api/v1/users", admin, map[string]any{
+		"name": "X", "email": "x@igd.test", "password": "rahasia123", "role": "janitor",
+	}), http.StatusBadRequest)
+
+	userPath := fmt.Sprintf("/api/v1/users/%d", created.ID)
+	rec = ts.do(t, http.MethodPut, userPath, admin, map[string]any{
+		"name": "Petugas Lama", "email": "petugas@igd.test", "role": models.RoleSecurity,
+	})
+	expectStatus(t, rec, http.StatusOK)
+
+	var user models.User
+	rec = ts.do(t, http.MethodGet, userPath, admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &user)
+	if user.Name != "Petugas Lama" || user.Role != models.RoleSecurity {
+		t.Fatalf("got %+v", user)
+	}
+
+	var users []models.User
+	rec = ts.do(t, http.MethodGet, "/api/v1/users", admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &users)
+	if len(users) != len(models.Roles)+1 {
+		t.Fatalf("expected %d users, got %d", len(models.Roles)+1, len(users))
+	}
+
+	expectStatus(t, ts.do(t, http.MethodDelete, userPath, admin, nil), http.StatusOK)
+	expectStatus(t, ts.do(t, http.MethodGet, userPath, admin, nil), http.StatusNotFound)
+	expectStatus(t, ts.do(t, http.MethodDelete, userPath, admin, nil), http.StatusNotFound)
+}
+
+func TestStorageScanReport(t *testing.T) {
+	ts := newTestServer(t)
+	admin := models.RoleAdmin
+
+	expectStatus(t, ts.do(t, http.MethodGet, "/api/storage/scan", admin, nil), http.StatusNotFound)
+
+	ts.createEscort(t, map[string]any{"foto_pengantar_base64": pngDataURL(t)})
+	missing := "escort_gone.jpg"
+	gone := models.Escort{Status: models.StatusPending, FotoPengantar: &missing}
+	if err := ts.store.Escorts.Create(context.Background(), &gone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.NewStorageScanner(ts.store, ts.files, time.Hour).Scan(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	var report models.StorageScanReport
+	rec := ts.do(t, http.MethodGet, "/api/storage/scan", admin, nil)
+	expectStatus(t, rec, http.StatusOK)
+	decodeData(t, rec, &report)
+	if !report.DryRun || report.PhotosReferenced != 2 || len(report.Missing) != 1 || report.Missing[0].EscortID != gone.ID {
+		t.Fatalf("report = %+v", report)
+	}
+	// The fresh upload and its variants are referenced, so nothing is an orphan
+	if report.FilesScanned != 3 || len(report.Orphans) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestIdempotentEscortSubmission(t *testing.T) {
+	ts := newTestServer(t)
+	body := map[string]any{
+		"kategori_pengantar": "Polisi",
+		"nama_pengantar":     "Budi Santoso",
+		"jenis_kelamin":      "Laki-laki",
+		"nomor_hp":           "081234567890",
+		"plat_nomor":         "B 1234 CD",
+		"nama_pasien":        "Siti Aminah",
+	}
+	submit := func(key string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		encoded, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/escort", bytes.NewReader(encoded))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		return ts.serve(t, req, "")
+	}
+	countEscorts := func() int64 {
+		t.Helper()
+		count, err := ts.store.Escorts.Count(context.Background(), repository.EscortQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	first := submit("form-42", body)
+	expectStatus(t, first, http.StatusCreated)
+
+	retry := submit("form-42", body)
+	expectStatus(t, retry, http.StatusCreated)
+	if retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry was not replayed: %s", retry.Body.String())
+	}
+	if count := countEscorts(); count != 1 {
+		t.Fatalf("escorts = %d, want 1", count)
+	}
+
+	t.Run("key reused for another request", func(t *testing.T) {
+		changed := maps.Clone(body)
+		changed["nama_pasien"] = "Ahmad Fauzi"
+		expectStatus(t, submit("form-42", changed), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		expectStatus(t, submit(strings.Repeat("k", 256), body), http.StatusBadRequest)
+	})
+
+	t.Run("first request still in progress", func(t *testing.T) {
+		encoded, _ := json.Marshal(body)
+		hash := sha256.Sum256(encoded)
+		now := time.Now()
+		_, _, err := ts.store.Idempotency.Reserve(context.Background(), &models.IdempotencyRecord{
+			Scope: "POST /api/escort", Key: "form-43", RequestHash: hex.EncodeToString(hash[:]),
+			CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+		}, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := submit("form-43", body)
+		expectStatus(t, rec, http.StatusConflict)
+		if rec.Header().Get("Retry-After") == "" {
+			t.Fatal("missing Retry-After")
+		}
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		oversized := maps.Clone(body)
+		oversized["foto_pengantar"] = strings.Repeat("A", middleware.MaxRequestBody)
+		expectStatus(t, submit("form-45", oversized), http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("rejected requests are replayed too", func(t *testing.T) {
+		invalid := map[string]any{"nama_pengantar": "X"}
+		expectStatus(t, submit("form-44", invalid), http.StatusBadRequest)
+		rec := submit("form-44", invalid)
+		expectStatus(t, rec, http.StatusBadRequest)
+		if rec.Header().Get(middleware.IdempotentReplayedHeader) != "true" {
+			t.Fatal("validation error was not replayed")
+		}
+	})
+
+	if count := countEscorts(); count != 1 {
+		t.Fatalf("escorts = %d, want 1", count)
+	}
+}
+
+func TestDuplicateEscortDetection(t *testing.T) {
+	t.Run("link", func(t *testing.T) {
+		ts := newTestServer(t, func(config *Config) {
+			config.Duplicates = services.DuplicatePolicy{Window: 10 * time.Minute, Action: services.DuplicateActionLink}
+		})
+
+		original := ts.createEscort(t, nil)
+		if original.DuplicateOf != nil {
+			t.Fatalf("first submission flagged as duplicate of %d", *original.DuplicateOf)
+		}
+
+		// Spacing and letter case do not hide a repeat; every repeat links to the first submission
+		repeat := ts.createEscort(t, map[string]any{"plat_nomor": "b1234cd", "nama_pasien": "SITI AMINAH"})
+		again := ts.createEscort(t, nil)
+		for _, escort := range []models.Escort{repeat, again} {
+			if escort.DuplicateOf == nil || *escort.DuplicateOf != original.ID {
+				t.Fatalf("escort %d duplicate_of = %v, want %d", escort.ID, escort.DuplicateOf, original.ID)
+			}
+		}
+
+		if other := ts.createEscort(t, map[string]any{"nama_pasien": "Ahmad Fauzi"}); other.DuplicateOf != nil {
+			t.Fatal("different patient flagged as duplicate")
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		ts := newTestServer(t, func(config *Config) {
+			config.Duplicates = services.DuplicatePolicy{Window: 10 * time.Minute, Action: services.DuplicateActionReject}
+		})
+
+		original := ts.createEscort(t, nil)
+		body := map[string]any{
+			"kategori_pengantar": "Ambulans",
+			"nama_pengantar":     "Budi Santoso",
+			"jenis_kelamin":      "Laki-laki",
+			"nomor_hp":           "081234567890",
+			"plat_nomor":         "B 1234 CD",
+			"nama_pasien":        "Siti Aminah",
+		}
+
+		// Anonymous callers are not told which submission it duplicates
+		rec := ts.do(t, http.MethodPost, "/api/escort", "", body)
+		expectStatus(t, rec, http.StatusConflict)
+		var anonymous struct {
+			Errors map[string]any `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &anonymous); err != nil || anonymous.Errors != nil {
+			t.Fatalf("anonymous duplicate response has errors %v (%v): %s", anonymous.Errors, err, rec.Body.String())
+		}
+
+		// Staff are
+		rec = ts.do(t, http.MethodPost, "/api/escort", "admin", body)
+		expectStatus(t, rec, http.StatusConflict)
+		var resp struct {
+			Errors struct {
+				DuplicateOf  uint   `json:"duplicate_of"`
+				SubmissionID string `json:"submission_id"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Errors.DuplicateOf != original.ID {
+			t.Fatalf("duplicate_of = %d (%v), want %d", resp.Errors.DuplicateOf, err, original.ID)
+		}
+		if original.SubmissionID == nil || resp.Errors.SubmissionID != *original.SubmissionID {
+			t.Errorf("submission_id = %q, want the original's", resp.Errors.SubmissionID)
+		}
+	})
+}
+
+func TestRateLimiting(t *testing.T) {
+	ts := newTestServer(t, func(config *Config) {
+		config.RateLimitEnabled = true
+		config.RateLimitStore = "memory"
+		config.RateLimits = services.RatePolicies{
+			Submit: services.RatePolicy{Name: "submit", Limit: 2, Window: time.Hour},
+			QRCode: services.RatePolicy{Name: "qr", Limit: 1, Window: time.Hour},
+			Public: services.RatePolicy{Name: "public", Limit: 1, Window: time.Hour},
+			API:    services.RatePolicy{Name: "api", Limit: 2, Window: time.Minute},
+		}
+	})
+	fromIP := func(method, path, ip string, body any) *httptest.ResponseRecorder {
+		encoded, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(encoded))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":50000"
+		return ts.serve(t, req, "")
+	}
+	submission := func(kioskToken string) map[string]any {
+		return map[string]any{
+			"kategori_pengantar": "Ambulans",
+			"nama_pengantar":     "Budi Santoso",
+			"jenis_kelamin":      "Laki-laki",
+			"nomor_hp":           "081234567890",
+			"plat_nomor":         "B 1234 CD",
+			"nama_pasien":        "Siti Aminah",
+			"kiosk_token":        kioskToken,
+		}
+	}
+
+	t.Run("public per IP", func(t *testing.T) {
+		rec := fromIP(http.MethodGet, "/api/session-stats", "198.51.100.1", nil)
+		expectStatus(t, rec, http.StatusOK)
+		for header, want := range map[string]string{
+			"RateLimit-Limit":     "1",
+			"RateLimit-Remaining": "0",
+			"RateLimit-Reset":     "3600",
+			"RateLimit-Policy":    "1;w=3600",
+		} {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+
+		rec = fromIP(http.MethodGet, "/api/session-stats", "198.51.100.1", nil)
+		expectStatus(t, rec, http.StatusTooManyRequests)
+		if got := rec.Header().Get("Retry-After"); got != "3600" {
+			t.Fatalf("Retry-After = %q, want 3600", got)
+		}
+		var resp models.APIResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Status != "error" {
+			t.Fatalf("429 body = %s", rec.Body)
+		}
+
+		// Other clients and other policies have their own allowance
+		expectStatus(t, fromIP(http.MethodGet, "/api/session-stats", "198.51.100.2", nil), http.StatusOK)
+		expectStatus(t, fromIP(http.MethodGet, "/api/qr-code/form?url=http://localhost:8000/form", "198.51.100.1", nil), http.StatusOK)
+		expectStatus(t, fromIP(http.MethodGet, "/api/qr-code/form?url=http://localhost:8000/form", "198.51.100.1", nil), http.StatusTooManyRequests)
+	})
+
+	t.Run("submissions per kiosk", func(t *testing.T) {
+		rec := ts.do(t, http.MethodPost, "/api/kiosk-locations/main_entrance/qr", models.RoleAdmin, nil)
+		expectStatus(t, rec, http.StatusOK)
+		var qr models.KioskQRCode
+		decodeData(t, rec, &qr)
+
+		// Kiosk submissions are counted per kiosk and client IP: the token is on a public
+		// poster, so one visitor using it up must not block the others at that entrance
+		for range 2 {
+			expectStatus(t, fromIP(http.MethodPost, "/api/escort", "203.0.113.1", submission(qr.Token)), http.StatusCreated)
+			expectStatus(t, fromIP(http.MethodPost, "/api/escort", "203.0.113.9", submission("")), http.StatusCreated)
+		}
+		expectStatus(t, fromIP(http.MethodPost, "/api/escort", "203.0.113.1", submission(qr.Token)), http.StatusTooManyRequests)
+		expectStatus(t, fromIP(http.MethodPost, "/api/escort", "203.0.113.9", submission("")), http.StatusTooManyRequests)
+		expectStatus(t, fromIP(http.MethodPost, "/api/escort", "203.0.113.2", submission(qr.Token)), http.StatusCreated)
+
+		// The submission still reaches the handler intact after the limiter read kiosk_token
+		escort := ts.createEscort(t, map[string]any{"nama_pasien": "Ahmad Fauzi"})
+		if escort.NamaPasien != "Ahmad Fauzi" {
+			t.Fatalf("nama_pasien = %q, want Ahmad Fauzi", escort.NamaPasien)
+		}
+	})
+
+	t.Run("oversized submission", func(t *testing.T) {
+		body := submission("")
+		body["foto_pengantar"] = strings.Repeat("A", middleware.MaxRequestBody)
+		rec := fromIP(http.MethodPost, "/api/escort", "203.0.113.50", body)
+		expectStatus(t, rec, http.StatusRequestEntityTooLarge)
+		if rec.Header().Get("RateLimit-Remaining") != "" {
+			t.Fatal("oversized body reached the limiter")
+		}
+	})
+
+	t.Run("protected per token", func(t *testing.T) {
+		for range 2 {
+			expectStatus(t, ts.do(t, http.MethodGet, "/api/escort", models.RoleAuditor, nil), http.StatusOK)
+		}
+		rec := ts.do(t, http.MethodGet, "/api/escort", models.RoleAuditor, nil)
+		expectStatus(t, rec, http.StatusTooManyRequests)
+		if got := rec.Header().Get("Retry-After"); got != "30" {
+			t.Fatalf("Retry-After = %q, want 30", got)
+		}
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort", models.RoleSupervisor, nil), http.StatusOK)
+
+		// Unauthenticated requests are refused before they count against anyone
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort", "", nil), http.StatusUnauthorized)
+	})
+}
+
+func TestRequestLogging(t *testing.T) {
+	ts := newTestServer(t)
+
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&out, slog.LevelInfo, logging.FormatJSON))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	lastRecord := func(t *testing.T) map[string]any {
+		t.Helper()
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		var record map[string]any
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+			t.Fatalf("decode log record: %v", err)
+		}
+		return record
+	}
+
+	t.Run("client request ID", func(t *testing.T) {
+		escort := ts.createEscort(t, nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/escort/"+strconv.Itoa(int(escort.ID)), nil)
+		req.Header.Set(middleware.RequestIDHeader, "laravel-7f3a")
+		rec := ts.serve(t, req, models.RoleAdmin)
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get(middleware.RequestIDHeader); got != "laravel-7f3a" {
+			t.Fatalf("%s = %q, want laravel-7f3a", middleware.RequestIDHeader, got)
+		}
+
+		record := lastRecord(t)
+		for key, want := range map[string]any{
+			"msg":        "HTTP request",
+			"request_id": "laravel-7f3a",
+			"method":     "GET",
+			"route":      "/api/escort/:id",
+			"status":     float64(http.StatusOK),
+		} {
+			if record[key] != want {
+				t.Errorf("%s = %v, want %v", key, record[key], want)
+			}
+		}
+		for _, key := range []string{"latency_ms", "user_id", "db_queries", "db_ms"} {
+			if _, ok := record[key]; !ok {
+				t.Errorf("log record has no %s: %v", key, record)
+			}
+		}
+	})
+
+	t.Run("generated request ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/session-stats", nil)
+		req.Header.Set(middleware.RequestIDHeader, "not a valid id\n")
+		rec := ts.serve(t, req, "")
+		id := rec.Header().Get(middleware.RequestIDHeader)
+		if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) {
+			t.Fatalf("%s = %q, want a generated ID", middleware.RequestIDHeader, id)
+		}
+		if record := lastRecord(t); record["request_id"] != id {
+			t.Fatalf("logged request_id = %v, want %s", record["request_id"], id)
+		}
+	})
+
+	t.Run("no PII", func(t *testing.T) {
+		ts.createEscort(t, map[string]any{"nama_pasien": "Ahmad Fauzi", "nomor_hp": "089876543210"})
+		if strings.Contains(out.String(), "Ahmad Fauzi") || strings.Contains(out.String(), "089876543210") {
+			t.Fatalf("PII in log output: %s", out.String())
+		}
+		if record := lastRecord(t); record["status"] != float64(http.StatusCreated) || record["level"] != "INFO" {
+			t.Fatalf("unexpected log record: %v", record)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t, func(config *Config) {
+		config.MetricsToken = "scrape-token"
+	})
+
+	created := testutil.ToFloat64(metrics.EscortsCreated.WithLabelValues("Polisi"))
+	verified := testutil.ToFloat64(metrics.EscortStatusTransitions.WithLabelValues("pending", "verified"))
+	formQRCodes := testutil.ToFloat64(metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeForm))
+	receipts := testutil.ToFloat64(metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeReceipt))
+
+	escort := ts.createEscort(t, map[string]any{"kategori_pengantar": "Polisi"})
+	statusPath := fmt.Sprintf("/api/escort/%d/status", escort.ID)
+	expectStatus(t, ts.do(t, http.MethodPatch, statusPath, models.RoleAdmin, map[string]any{"status": "verified"}), http.StatusOK)
+	expectStatus(t, ts.do(t, http.MethodGet, "/api/qr-code/form?url=http://localhost:8000/form", "", nil), http.StatusOK)
+
+	for name, tt := range map[string]struct {
+		counter prometheus.Counter
+		before  float64
+	}{
+		"escorts created":    {metrics.EscortsCreated.WithLabelValues("Polisi"), created},
+		"status transitions": {metrics.EscortStatusTransitions.WithLabelValues("pending", "verified"), verified},
+		"form QR codes":      {metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeForm), formQRCodes},
+		"receipt QR codes":   {metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeReceipt), receipts},
+	} {
+		if got := testutil.ToFloat64(tt.counter) - tt.before; got != 1 {
+			t.Errorf("%s increased by %v, want 1", name, got)
+		}
+	}
+
+	expectStatus(t, ts.do(t, http.MethodGet, "/metrics", "", nil), http.StatusUnauthorized)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+	rec := ts.serve(t, req, "")
+	expectStatus(t, rec, http.StatusOK)
+	for _, want := range []string{
+		`goserver_http_requests_total{method="PATCH",route="/api/escort/:id/status",status="200"}`,
+		`goserver_http_request_duration_seconds_bucket{method="GET",route="/api/qr-code/form",status="200",le="0.005"}`,
+		`goserver_escorts_created_total{kategori_pengantar="Polisi"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics have no %s", want)
+		}
+	}
+
+	t.Run("not exposed without a token", func(t *testing.T) {
+		ts := newTestServer(t)
+		expectStatus(t, ts.do(t, http.MethodGet, "/metrics", "", nil), http.StatusNotFound)
+	})
+}
+
+func TestTracing(t *testing.T) {
+	ts := newTestServer(t)
+
+	spans := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	escort := ts.createEscort(t, nil)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/escort/%d", escort.ID), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	expectStatus(t, ts.serve(t, req, models.RoleAdmin), http.StatusOK)
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = span
+	}
+	server, service := byName["GET /api/escort/:id"], byName["EscortService.GetEscortByID"]
+	if server == nil || service == nil {
+		t.Fatalf("spans = %v, want the request and EscortService.GetEscortByID", slices.Collect(maps.Keys(byName)))
+	}
+	if server.SpanContext().TraceID().String() != traceID || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("request span is not part of the incoming trace: %v", server.SpanContext())
+	}
+	if service.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("EscortService span is not a child of the request span")
+	}
+	if created := byName["EscortService.CreateEscort"]; created == nil || created.SpanContext().TraceID() == server.SpanContext().TraceID() {
+		t.Fatal("request without traceparent did not start its own trace")
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		expectStatus(t, ts.do(t, http.MethodGet, "/api/escort/999999", models.RoleAdmin, nil), http.StatusNotFound)
+		for _, span := range spans.Ended() {
+			if span.Name() == "EscortService.GetEscortByID" && span.Status().Code == codes.Error {
+				return
+			}
+		}
+		t.Fatal("failed EscortService call not recorded as an error")
+	})
+}
+
+func TestHealthProbes(t *testing.T) {
+	ts := newTestServer(t, func(config *Config) {
+		config.MetricsToken = "ops-token"
+	})
+
+	rec := ts.do(t, http.MethodGet, "/livez", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var live models.HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &live); err != nil {
+		t.Fatal(err)
+	}
+	if live.Status != models.HealthOK || live.Version != version || live.Commit == "" || live.StartedAt.IsZero() || len(live.Components) != 0 {
+		t.Fatalf("/livez = %+v", live)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/readyz", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var ready models.HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, component := range ready.Components {
+		names = append(names, component.Name)
+		if component.Status != models.HealthOK {
+			t.Errorf("component %s = %+v", component.Name, component)
+		}
+	}
+	if !slices.Equal(names, []string{services.HealthCheckDatabase, services.HealthCheckStorage}) {
+		t.Fatalf("components = %v, want database and storage (the memory store has no migrations)", names)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/api/health", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var health struct {
+		Status    string `json:"status"`
+		Database  string `json:"database"`
+		Timestamp string `json:"timestamp"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(time.RFC3339, health.Timestamp); health.Status != "healthy" || health.Database != "connected" || err != nil {
+		t.Fatalf("/api/health = %s", rec.Body)
+	}
+
+	t.Run("storage not writable", func(t *testing.T) {
+		// Replace the storage directory with a file, so nothing can be written below it
+		root := strings.TrimPrefix(ts.files.String(), filestore.DriverLocal+":")
+		if err := os.RemoveAll(root); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(root, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodGet, "/livez", "", nil), http.StatusOK)
+
+		rec := ts.do(t, http.MethodGet, "/readyz", "", nil)
+		expectStatus(t, rec, http.StatusServiceUnavailable)
+		var ready models.HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+			t.Fatal(err)
+		}
+		if ready.Status != models.HealthFail || ready.Components[1].Status != models.HealthFail {
+			t.Fatalf("/readyz = %+v", ready)
+		}
+		// The public probe does not tell anonymous callers where the photos are stored
+		if strings.Contains(rec.Body.String(), root) || ready.Components[1].Error != "" {
+			t.Fatalf("/readyz exposes the storage error: %s", rec.Body)
+		}
+
+		expectStatus(t, ts.do(t, http.MethodGet, "/readyz/details", "", nil), http.StatusUnauthorized)
+		req := httptest.NewRequest(http.MethodGet, "/readyz/details", nil)
+		req.Header.Set("Authorization", "Bearer ops-token")
+		rec = ts.serve(t, req, "")
+		expectStatus(t, rec, http.StatusServiceUnavailable)
+		if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+			t.Fatal(err)
+		}
+		if ready.Components[1].Error == "" {
+			t.Fatalf("/readyz/details = %s, want the storage error", rec.Body)
+		}
+
+		rec = ts.do(t, http.MethodGet, "/api/health", "", nil)
+		expectStatus(t, rec, http.StatusServiceUnavailable)
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+		if health.Status != "unhealthy" || health.Database != "connected" || strings.Contains(rec.Body.String(), root) {
+			t.Fatalf("/api/health = %s", rec.Body)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+// SanctumUserModel is the tokenable_type Laravel stores for tokens issued to App\Models\User
+const SanctumUserModel = `App\Models\User`
+
+var (
+	// ErrInvalidToken is returned when a bearer token does not match any personal access token
+	ErrInvalidToken = errors.New("invalid access token")
+	// ErrTokenExpired is returned when a token is older than the configured Sanctum expiration
+	ErrTokenExpired = errors.New("access token expired")
+)
+
+type AuthService struct {
+	tokens     repository.TokenRepository
+	users      repository.UserRepository
+	expiration time.Duration
+}
+
+// NewAuthService creates an AuthService. An expiration of zero means tokens never expire,
+// matching Sanctum's `'expiration' => null` default.
+func NewAuthService(tokens repository.TokenRepository, users repository.UserRepository, expiration time.Duration) *AuthService {
+	return &AuthService{tokens: tokens, users: users, expiration: expiration}
+}
+
+// AuthenticateToken validates a plain-text Sanctum token ("<id>|<token>" or a bare token),
+// records its usage and returns the owning user
+func (s *AuthService) AuthenticateToken(ctx context.Context, plainToken string) (*models.AuthUser, *models.PersonalAccessToken, error) {
+	token, err := s.findToken(ctx, plainToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if token.TokenableType != SanctumUserModel {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if s.expiration > 0 && !token.CreatedAt.After(time.Now().Add(-s.expiration)) {
+		return nil, nil, ErrTokenExpired
+	}
+
+	user, err := s.users.GetByID(ctx, token.TokenableID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, fmt.Errorf("failed to load token owner: %w", err)
+	}
+
+	// Sanctum touches last_used_at on every authenticated request
+	if err := s.tokens.Touch(ctx, token.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return user.AuthUser(), token, nil
+}
+
+// findToken looks a token up the same way Laravel\Sanctum\PersonalAccessToken::findToken does
+func (s *AuthService) findToken(ctx context.Context, plainToken string) (*models.PersonalAccessToken, error) {
+	var token *models.PersonalAccessToken
+	var err error
+
+	secret := plainToken
+	if id, rest, found := strings.Cut(plainToken, "|"); found {
+		tokenID, parseErr := strconv.ParseUint(id, 10, 64)
+		if parseErr != nil {
+			return nil, ErrInvalidToken
+		}
+		secret = rest
+		token, err = s.tokens.FindByID(ctx, uint(tokenID))
+	} else {
+		token, err = s.tokens.FindByHash(ctx, HashToken(secret))
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.Token), []byte(HashToken(secret))) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// HashToken hashes a plain-text token the way Sanctum stores it (hex encoded SHA-256)
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
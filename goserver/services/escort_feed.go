@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+// escortSubscriberBuffer is how many events a stream client may fall behind before it is disconnected
+const escortSubscriberBuffer = 64
+
+// EscortFeed fans escort events out to the clients of GET /api/escort/stream. The last
+// events are kept in a replay buffer so a reconnecting client can resume from its
+// Last-Event-ID instead of reloading everything.
+type EscortFeed struct {
+	mu          sync.Mutex
+	replay      []models.EscortEvent // ring buffer in delivery order
+	start       int                  // index of the oldest event in replay
+	size        int
+	subscribers map[*EscortSubscription]struct{}
+}
+
+// EscortSubscription receives the events published after it was created
+type EscortSubscription struct {
+	// Events is closed when the subscriber falls too far behind or the feed is reset;
+	// the client should reconnect with its Last-Event-ID
+	Events <-chan models.EscortEvent
+
+	events chan models.EscortEvent
+	feed   *EscortFeed
+}
+
+// NewEscortFeed creates a feed that can replay the last replaySize events
+func NewEscortFeed(replaySize int) *EscortFeed {
+	return &EscortFeed{
+		replay:      make([]models.EscortEvent, max(replaySize, 1)),
+		subscribers: map[*EscortSubscription]struct{}{},
+	}
+}
+
+// Run relays events published on the store to subscribers until ctx is cancelled. When the
+// listen connection drops it reconnects with backoff and resets the feed, since events
+// published in the meantime are lost and clients must reload.
+func (f *EscortFeed) Run(ctx context.Context, store *repository.Store) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := store.Listen(ctx, f.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.WarnContext(ctx, "Escort event listener stopped, reconnecting", "error", err, "backoff", backoff)
+		f.Reset()
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// Publish records an event in the replay buffer and sends it to every subscriber.
+// It never blocks: subscribers whose buffer is full are disconnected.
+func (f *EscortFeed) Publish(event models.EscortEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size < len(f.replay) {
+		f.replay[(f.start+f.size)%len(f.replay)] = event
+		f.size++
+	} else {
+		f.replay[f.start] = event
+		f.start = (f.start + 1) % len(f.replay)
+	}
+
+	for sub := range f.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			f.unsubscribe(sub)
+		}
+	}
+}
+
+// Reset empties the replay buffer and disconnects every subscriber
+func (f *EscortFeed) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.start, f.size = 0, 0
+	for sub := range f.subscribers {
+		f.unsubscribe(sub)
+	}
+}
+
+// Subscribe registers a stream client. lastEventID is the ID of the last event the client
+// received (0 for a new connection). The returned backlog holds the buffered events after
+// it; complete is false when lastEventID is no longer buffered, in which case events were
+// missed and the client has to reload its data.
+func (f *EscortFeed) Subscribe(lastEventID int64) (sub *EscortSubscription, backlog []models.EscortEvent, complete bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := make(chan models.EscortEvent, escortSubscriberBuffer)
+	sub = &EscortSubscription{Events: events, events: events, feed: f}
+	f.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+
+	// Events are buffered in delivery (commit) order, which is not always ID order
+	for i := 0; i < f.size; i++ {
+		if f.replay[(f.start+i)%len(f.replay)].ID != lastEventID {
+			continue
+		}
+		for j := i + 1; j < f.size; j++ {
+			backlog = append(backlog, f.replay[(f.start+j)%len(f.replay)])
+		}
+		return sub, backlog, true
+	}
+	return sub, nil, false
+}
+
+// Close unregisters the subscription
+func (s *EscortSubscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.feed.unsubscribe(s)
+}
+
+// unsubscribe removes sub and closes its channel; f.mu must be held
+func (f *EscortFeed) unsubscribe(sub *EscortSubscription) {
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+	"goserver/telemetry"
+)
+
+// maxImageSize is the largest accepted photo, the same 2MB limit as the Laravel form
+const maxImageSize = 2 * 1024 * 1024
+
+// imageExtensions maps the accepted photo MIME types to the extension they are stored with
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var (
+	// ErrInvalidImage matches every *ImageError, the rejections of uploaded data that is not an accepted photo
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrImageTooLarge is returned when a photo exceeds maxImageSize
+	ErrImageTooLarge = &ImageError{Code: ImageCodeTooLarge, Message: "image too large (max 2MB)"}
+)
+
+// ImageFile is an opened escort photo, ready to be served with http.ServeContent
+type ImageFile struct {
+	io.ReadSeekCloser
+	Name        string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// ETag identifies the photo content. Stored photos are never rewritten, so the
+// modification time and size are enough.
+func (f *ImageFile) ETag() string {
+	return `"` + strconv.FormatInt(f.ModTime.UnixNano(), 16) + "-" + strconv.FormatInt(f.Size, 16) + `"`
+}
+
+// UploadImage stores a photo sent as raw bytes (multipart upload) and attaches it to the escort.
+// The type is taken from the file content, not from what the client claims.
+func (s *EscortService) UploadImage(ctx context.Context, id uint, content io.Reader) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.UploadImage", escortIDAttr(id))
+	defer telemetry.End(span, &err)
+
+	if _, err := s.GetEscortByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	images := s.newImageUnit()
+	filename, err := images.stage(ctx, content, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.applyUpdate(ctx, id, repository.EscortUpdate{FotoPengantar: &filename}, images)
+}
+
+// OpenImage opens the photo of an escort, or one of its ImageVariants when variant is not
+// empty. The caller must close the returned file.
+func (s *EscortService) OpenImage(ctx context.Context, id uint, variant string) (_ *ImageFile, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.OpenImage", escortIDAttr(id))
+	defer telemetry.End(span, &err)
+
+	if _, known := imageVariantSizes[variant]; variant != "" && !known {
+		return nil, fmt.Errorf("%w %q (expected %s)", ErrUnknownImageVariant, variant, strings.Join(ImageVariants, " or "))
+	}
+
+	escort, err := s.GetEscortByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if escort.FotoPengantar == nil || *escort.FotoPengantar == "" {
+		return nil, ErrImageNotFound
+	}
+
+	key := imageKey(*escort.FotoPengantar)
+	var file io.ReadSeekCloser
+	var info *filestore.Info
+	if variant == "" {
+		file, info, err = s.files.Open(ctx, key)
+	} else {
+		file, info, err = s.openImageVariant(ctx, key, variant)
+	}
+	if err != nil {
+		if errors.Is(err, filestore.ErrNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, err
+	}
+
+	return &ImageFile{
+		ReadSeekCloser: file,
+		Name:           info.Key,
+		ContentType:    info.ContentType,
+		Size:           info.Size,
+		ModTime:        info.ModTime,
+	}, nil
+}
+
+// imageKey is the storage key of a foto_pengantar value. Only the base name is used, so
+// paths written by the Laravel app ("uploads/escort_1.jpg") resolve to the same key.
+func imageKey(fotoPengantar string) string {
+	return path.Base(fotoPengantar)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"time"
+
+	"goserver/filestore"
+)
+
+// stagingPrefix holds photos written for an escort change whose transaction has not committed
+// yet. A staged photo that its escort row refers to is promoted by the storage scan when the
+// server stopped before promoting it; any other is an orphan.
+const stagingPrefix = "staging/"
+
+// imageUnit keeps the photo files of one escort mutation in step with its transaction.
+// New photos are staged before the transaction, so a failed INSERT or UPDATE leaves nothing
+// behind; once it commits they are promoted to their final key and the photos they replace
+// are deleted. Files are only ever deleted after the rows referring to them are gone.
+type imageUnit struct {
+	files filestore.Storage
+	// staged are the photos to promote, by final key
+	staged []stagedImage
+	// obsolete are the foto_pengantar values to delete once the transaction commits
+	obsolete []string
+}
+
+type stagedImage struct {
+	key string
+	// variants are the encoded variants by name, nil when they could not be created. They are
+	// encoded while the photo is decoded, so the decoded photo is not kept past its image slot.
+	variants map[string][]byte
+}
+
+func (s *EscortService) newImageUnit() *imageUnit {
+	return &imageUnit{files: s.files}
+}
+
+// stage validates and re-encodes a photo (see sanitizeImage) and writes it to the staging
+// area. claimedType is the MIME type declared by the client, or "" when there is none.
+// It returns the key to store in foto_pengantar. Rejected photos return an *ImageError.
+func (u *imageUnit) stage(ctx context.Context, content io.Reader, claimedType string) (string, error) {
+	// Read everything first so an oversized photo never reaches the storage backend
+	data, err := io.ReadAll(io.LimitReader(content, maxImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	release, err := acquireImageSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	img, encoded, ext, err := sanitizeImage(data, claimedType)
+	if err != nil {
+		release()
+		return "", err
+	}
+	variants, variantErr := encodeImageVariants(img)
+	release()
+	if variantErr != nil {
+		// Variants missing now are created on first request or by BackfillImageVariants
+		slog.WarnContext(ctx, "Failed to create photo variants", "error", variantErr)
+	}
+
+	// Generate unique filename
+	key := fmt.Sprintf("escort_%d%s", time.Now().UnixNano(), ext)
+
+	if err := u.files.Put(ctx, stagingPrefix+key, bytes.NewReader(encoded), int64(len(encoded)), mime.TypeByExtension(ext)); err != nil {
+		return "", err
+	}
+	u.staged = append(u.staged, stagedImage{key: key, variants: variants})
+	return key, nil
+}
+
+// stageBase64 stages a photo sent as a data URL (see decodeBase64Image)
+func (u *imageUnit) stageBase64(ctx context.Context, dataURL string) (string, error) {
+	data, claimedType, err := decodeBase64Image(dataURL)
+	if err != nil {
+		return "", err
+	}
+	return u.stage(ctx, bytes.NewReader(data), claimedType)
+}
+
+// discard marks a photo, with its variants, for deletion once the transaction commits
+func (u *imageUnit) discard(fotoPengantar *string) {
+	if fotoPengantar == nil || *fotoPengantar == "" {
+		return
+	}
+	for _, staged := range u.staged {
+		if staged.key == imageKey(*fotoPengantar) {
+			return // the photo being stored has the same name
+		}
+	}
+	u.obsolete = append(u.obsolete, *fotoPengantar)
+}
+
+// complete finishes the unit with the outcome of its transaction and returns txErr. When the
+// transaction failed the staged photos are removed; otherwise they are promoted and the
+// discarded photos deleted. The change is committed by then, so file errors are only logged.
+// Detached from cancellation: a client hanging up must not leave the files half done.
+func (u *imageUnit) complete(ctx context.Context, txErr error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	if txErr != nil {
+		for _, staged := range u.staged {
+			if err := u.files.Delete(ctx, stagingPrefix+staged.key); err != nil {
+				slog.WarnContext(ctx, "Failed to remove staged photo", "key", staged.key, "error", err)
+			}
+		}
+		return txErr
+	}
+
+	for _, staged := range u.staged {
+		if err := promoteImage(ctx, u.files, staged.key); err != nil {
+			slog.WarnContext(ctx, "Failed to promote staged photo; the storage scan retries", "key", staged.key, "error", err)
+			continue
+		}
+		if staged.variants == nil {
+			continue
+		}
+		// Variants missing now are created on first request or by BackfillImageVariants
+		if err := storeImageVariants(ctx, u.files, staged.key, staged.variants); err != nil {
+			slog.WarnContext(ctx, "Failed to create photo variants", "key", staged.key, "error", err)
+		}
+	}
+
+	for _, foto := range u.obsolete {
+		key := imageKey(foto)
+		for _, stored := range append([]string{key}, imageVariantKeys(key)...) {
+			if err := u.files.Delete(ctx, stored); err != nil {
+				slog.WarnContext(ctx, "Failed to delete replaced photo", "key", stored, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// promoteImage moves a staged photo to its final key
+func promoteImage(ctx context.Context, files filestore.Storage, key string) error {
+	err := filestore.Move(ctx, files, stagingPrefix+key, key)
+	if errors.Is(err, filestore.ErrNotFound) {
+		// Already promoted, e.g. by a storage scan running at the same time
+		if _, statErr := files.Stat(ctx, key); statErr == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+)
+
+func TestEscortMutationsKeepPhotosInStep(t *testing.T) {
+	ctx := context.Background()
+	files := filestore.NewLocal(t.TempDir())
+	service := NewEscortService(repository.NewMemoryStore(), files)
+
+	// stored lists every key in the storage
+	stored := func() []string {
+		t.Helper()
+		keys := []string{}
+		err := files.Walk(ctx, func(info filestore.Info) error {
+			keys = append(keys, info.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+	withVariants := func(key string) []string {
+		keys := append([]string{key}, imageVariantKeys(key)...)
+		slices.Sort(keys)
+		return keys
+	}
+	photo := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngImage(t, 4, 4))
+
+	escort, err := service.CreateEscort(ctx, models.CreateEscortRequest{
+		KategoriPengantar: "Polisi", NamaPengantar: "Budi", JenisKelamin: "Laki-laki",
+		NomorHP: "081234567890", PlatNomor: "B1234XY", NamaPasien: "Ahmad", FotoPengantarB64: photo,
+	}, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := *escort.FotoPengantar
+	if got := stored(); !slices.Equal(got, withVariants(first)) {
+		t.Fatalf("after create: %v", got)
+	}
+
+	// Replacing the photo deletes the old one once the update commits
+	escort, err = service.UpdateEscort(ctx, escort.ID, models.UpdateEscortRequest{FotoPengantarB64: &photo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := *escort.FotoPengantar
+	if got := stored(); second == first || !slices.Equal(got, withVariants(second)) {
+		t.Fatalf("after replace: %v", got)
+	}
+
+	// A failed update removes the staged photo and leaves the current one alone
+	if _, err := service.UpdateEscort(ctx, escort.ID+1, models.UpdateEscortRequest{FotoPengantarB64: &photo}); !errors.Is(err, ErrEscortNotFound) {
+		t.Fatalf("error = %v, want ErrEscortNotFound", err)
+	}
+	if _, err := service.UploadImage(ctx, escort.ID+1, bytes.NewReader(pngImage(t, 4, 4))); !errors.Is(err, ErrEscortNotFound) {
+		t.Fatalf("error = %v, want ErrEscortNotFound", err)
+	}
+	if got := stored(); !slices.Equal(got, withVariants(second)) {
+		t.Fatalf("after failed update: %v", got)
+	}
+
+	// A failed transaction removes what was staged for it
+	images := service.newImageUnit()
+	if _, err := images.stage(ctx, bytes.NewReader(pngImage(t, 4, 4)), ""); err != nil {
+		t.Fatal(err)
+	}
+	txErr := errors.New("insert failed")
+	if err := images.complete(ctx, txErr); err != txErr {
+		t.Fatalf("complete = %v, want the transaction error", err)
+	}
+	if got := stored(); !slices.Equal(got, withVariants(second)) {
+		t.Fatalf("after failed transaction: %v", got)
+	}
+
+	if err := service.DeleteEscort(ctx, escort.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(); len(got) != 0 {
+		t.Fatalf("after delete: %v", got)
+	}
+	if err := service.DeleteEscort(ctx, escort.ID); !errors.Is(err, ErrEscortNotFound) {
+		t.Fatalf("error = %v, want ErrEscortNotFound", err)
+	}
+}
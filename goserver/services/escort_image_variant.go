@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+	"goserver/telemetry"
+
+	"golang.org/x/image/draw"
+)
+
+// Photo variants: downscaled JPEG copies stored next to the original as
+// "<name>.<variant>.jpg", e.g. escort_1.jpg -> escort_1.thumb.jpg
+const (
+	ImageVariantThumb  = "thumb"
+	ImageVariantMedium = "medium"
+)
+
+// ImageVariants lists every variant, smallest first
+var ImageVariants = []string{ImageVariantThumb, ImageVariantMedium}
+
+// imageVariantSizes is the bounding box of each variant in pixels; photos are never enlarged
+var imageVariantSizes = map[string]int{
+	ImageVariantThumb:  160,
+	ImageVariantMedium: 640,
+}
+
+const imageVariantQuality = 80
+
+// ErrUnknownImageVariant is returned for a variant name not in ImageVariants
+var ErrUnknownImageVariant = errors.New("unknown image variant")
+
+// variantKey is the storage key of a variant of the photo stored under key
+func variantKey(key, variant string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "." + variant + ".jpg"
+}
+
+// imageVariantKeys lists the storage keys of every variant of the photo stored under key
+func imageVariantKeys(key string) []string {
+	keys := make([]string, len(ImageVariants))
+	for i, variant := range ImageVariants {
+		keys[i] = variantKey(key, variant)
+	}
+	return keys
+}
+
+// encodeImageVariants encodes every variant of src as JPEG, by variant name. Callers hold an
+// image slot for src; the few kilobytes returned can be kept after it is released.
+func encodeImageVariants(src image.Image) (map[string][]byte, error) {
+	variants := make(map[string][]byte, len(ImageVariants))
+	for _, variant := range ImageVariants {
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, resizeToFit(src, imageVariantSizes[variant]), &jpeg.Options{Quality: imageVariantQuality})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", variant, err)
+		}
+		variants[variant] = buf.Bytes()
+	}
+	return variants, nil
+}
+
+// storeImageVariants stores the encoded variants of the photo stored under key
+func storeImageVariants(ctx context.Context, files filestore.Storage, key string, variants map[string][]byte) error {
+	for _, variant := range ImageVariants {
+		data := variants[variant]
+		if err := files.Put(ctx, variantKey(key, variant), bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createImageVariants reads the photo stored under key and stores its variants
+func (s *EscortService) createImageVariants(ctx context.Context, key string) error {
+	file, _, err := s.files.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	original, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	release, err := acquireImageSlot(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Photos stored before uploads were re-encoded may still need their EXIF orientation applied
+	src, err := decodeImage(original)
+	if err != nil {
+		release()
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	variants, err := encodeImageVariants(src)
+	release()
+	if err != nil {
+		return err
+	}
+	return storeImageVariants(ctx, s.files, key, variants)
+}
+
+// openImageVariant opens a variant of the photo stored under key, creating the variants
+// first when they do not exist yet. When the photo cannot be decoded the original is
+// opened instead, so clients always get something to show.
+func (s *EscortService) openImageVariant(ctx context.Context, key, variant string) (io.ReadSeekCloser, *filestore.Info, error) {
+	file, info, err := s.files.Open(ctx, variantKey(key, variant))
+	if !errors.Is(err, filestore.ErrNotFound) {
+		return file, info, err
+	}
+
+	if err := s.createImageVariants(ctx, key); err != nil {
+		if errors.Is(err, filestore.ErrNotFound) {
+			return nil, nil, err
+		}
+		slog.WarnContext(ctx, "Failed to create image variants", "key", key, "error", err)
+		return s.files.Open(ctx, key)
+	}
+	return s.files.Open(ctx, variantKey(key, variant))
+}
+
+// BackfillImageVariants creates the missing variants of every escort photo. Photos that
+// cannot be read or decoded are logged and skipped. It returns how many photos got variants.
+func (s *EscortService) BackfillImageVariants(ctx context.Context) (_ int, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.BackfillImageVariants")
+	defer telemetry.End(span, &err)
+
+	var keys []string
+	err = s.store.Escorts.Each(ctx, repository.EscortQuery{}, func(escort models.Escort) error {
+		if escort.FotoPengantar != nil && *escort.FotoPengantar != "" {
+			keys = append(keys, imageKey(*escort.FotoPengantar))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+
+		complete, err := s.hasImageVariants(ctx, key)
+		if err != nil {
+			return created, err
+		}
+		if complete {
+			continue
+		}
+
+		if err := s.createImageVariants(ctx, key); err != nil {
+			if ctx.Err() != nil {
+				return created, ctx.Err()
+			}
+			slog.WarnContext(ctx, "Failed to create image variants", "key", key, "error", err)
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// hasImageVariants reports whether every variant of the photo stored under key exists
+func (s *EscortService) hasImageVariants(ctx context.Context, key string) (bool, error) {
+	for _, stored := range imageVariantKeys(key) {
+		if _, err := s.files.Stat(ctx, stored); err != nil {
+			if errors.Is(err, filestore.ErrNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// resizeToFit scales src down to fit in a size x size box, keeping its aspect ratio.
+// Transparent areas are flattened onto white since JPEG has no alpha channel.
+func resizeToFit(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
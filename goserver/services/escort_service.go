@@ -3,32 +3,117 @@ package services
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math"
-	"mime"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"goserver/filestore"
+	"goserver/metrics"
 	"goserver/models"
+	"goserver/repository"
+	"goserver/telemetry"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type EscortService struct {
-	db *pgxpool.Pool
+	store      *repository.Store
+	files      filestore.Storage
+	duplicates DuplicatePolicy
+	kiosks     *KioskService
 }
 
-func NewEscortService(db *pgxpool.Pool) *EscortService {
-	return &EscortService{db: db}
+// NewEscortService creates the service; escort photos are kept in files
+func NewEscortService(store *repository.Store, files filestore.Storage) *EscortService {
+	return &EscortService{store: store, files: files}
+}
+
+// Actions taken on a duplicate submission (DUPLICATE_ACTION)
+const (
+	DuplicateActionLink   = "link"   // store it with duplicate_of pointing at the original
+	DuplicateActionReject = "reject" // refuse it with a *DuplicateEscortError
+)
+
+// DuplicatePolicy configures the detection of submissions with the same plate number, phone
+// number and patient name as an earlier escort
+type DuplicatePolicy struct {
+	// Window is how far back an earlier escort is looked for; 0 disables detection
+	Window time.Duration
+	Action string
+}
+
+// SetDuplicatePolicy enables duplicate detection on CreateEscort
+func (s *EscortService) SetDuplicatePolicy(policy DuplicatePolicy) {
+	s.duplicates = policy
+}
+
+// SetKiosks makes CreateEscort attribute submissions to the kiosk location in their token
+func (s *EscortService) SetKiosks(kiosks *KioskService) {
+	s.kiosks = kiosks
+}
+
+// escortIDAttr tags the span of a method with the escort it works on
+func escortIDAttr(id uint) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.Int64("escort.id", int64(id)))
+}
+
+var (
+	// ErrEscortNotFound is returned when no escort has the requested ID
+	ErrEscortNotFound = errors.New("escort not found")
+	// ErrImageNotFound is returned when an escort has no photo
+	ErrImageNotFound = errors.New("no image found for escort")
+	// ErrRejectionReasonRequired is returned when an escort is rejected without a reason code
+	ErrRejectionReasonRequired = errors.New("rejection reason is required")
+	// ErrUnknownRejectionReason is returned when a reason code is missing or inactive
+	ErrUnknownRejectionReason = errors.New("unknown rejection reason")
+	// ErrDuplicateEscort matches every *DuplicateEscortError
+	ErrDuplicateEscort = errors.New("duplicate escort submission")
+)
+
+// DuplicateEscortError is returned when a submission repeats an earlier escort and
+// duplicates are rejected
+type DuplicateEscortError struct {
+	Original *models.Escort
+}
+
+func (e *DuplicateEscortError) Error() string {
+	return fmt.Sprintf("duplicate of escort %d", e.Original.ID)
+}
+
+func (e *DuplicateEscortError) Is(target error) bool {
+	return target == ErrDuplicateEscort
+}
+
+// InvalidTransitionError is returned when a status change is not allowed by models.StatusTransitions
+type InvalidTransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change status from %s to %s", e.From, e.To)
+}
+
+// escortError translates repository.ErrNotFound into ErrEscortNotFound
+func escortError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrEscortNotFound
+	}
+	return err
 }
 
 // CreateEscort creates a new escort record
-func (s *EscortService) CreateEscort(ctx context.Context, req models.CreateEscortRequest, clientIP string) (*models.Escort, error) {
+func (s *EscortService) CreateEscort(ctx context.Context, req models.CreateEscortRequest, clientIP string) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.CreateEscort")
+	defer telemetry.End(span, &err)
+
 	escort := &models.Escort{
-		Status:            "pending",
+		Status:            models.StatusPending, // public submissions always wait for verification
 		KategoriPengantar: req.KategoriPengantar,
 		NamaPengantar:     req.NamaPengantar,
 		JenisKelamin:      req.JenisKelamin,
@@ -39,157 +124,126 @@ func (s *EscortService) CreateEscort(ctx context.Context, req models.CreateEscor
 		APISubmission:     true,
 	}
 
-	// Set custom status if provided
-	if req.Status != "" {
-		escort.Status = req.Status
+	if s.kiosks != nil {
+		location, err := s.kiosks.ResolveToken(ctx, req.KioskToken)
+		if err != nil {
+			return nil, err
+		}
+		escort.LocationCode = location
 	}
 
-	// Handle base64 image upload
+	// Handle base64 image upload; the photo is staged until the row is committed
+	images := s.newImageUnit()
 	if req.FotoPengantarB64 != "" {
-		filename, err := s.saveBase64Image(req.FotoPengantarB64)
+		filename, err := images.stageBase64(ctx, req.FotoPengantarB64)
 		if err != nil {
 			return nil, fmt.Errorf("failed to save image: %w", err)
 		}
 		escort.FotoPengantar = &filename
 	}
 
-	// Generate submission ID
-	submissionID := fmt.Sprintf("ESC_%d_%s", time.Now().Unix(), strings.ToUpper(escort.PlatNomor))
-	escort.SubmissionID = &submissionID
-
-	// Insert into database
-	query := `
-		INSERT INTO escorts (
-			status, kategori_pengantar, nama_pengantar, jenis_kelamin,
-			nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
-			submission_id, submitted_from_ip, api_submission,
-			created_at, updated_at
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW()
-		) RETURNING id, created_at, updated_at
-	`
-
-	err := s.db.QueryRow(ctx, query,
-		escort.Status, escort.KategoriPengantar, escort.NamaPengantar,
-		escort.JenisKelamin, escort.NomorHP, escort.PlatNomor,
-		escort.NamaPasien, escort.FotoPengantar, escort.SubmissionID,
-		escort.SubmittedFromIP, escort.APISubmission,
-	).Scan(&escort.ID, &escort.CreatedAt, &escort.UpdatedAt)
+	err = s.store.WithTx(ctx, func(tx *repository.Store) error {
+		if err := s.checkDuplicate(ctx, tx, escort); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create escort: %w", err)
+		if err := createWithSubmissionID(ctx, tx, escort); err != nil {
+			return err
+		}
+
+		// The submission itself is the first entry of the status timeline
+		err := tx.Escorts.AddStatusHistory(ctx, newStatusHistory(escort.ID, nil, models.StatusChange{
+			Status:   escort.Status,
+			ClientIP: clientIP,
+		}))
+		if err != nil {
+			return err
+		}
+
+		return publishEscortEvent(ctx, tx, &models.EscortEvent{
+			Type:     models.EscortEventCreated,
+			EscortID: escort.ID,
+			Escort:   escort,
+		})
+	})
+	if err := images.complete(ctx, err); err != nil {
+		return nil, err
 	}
 
+	metrics.EscortsCreated.WithLabelValues(escort.KategoriPengantar).Inc()
 	return escort, nil
 }
 
-// GetEscorts retrieves escorts with pagination and filtering
-func (s *EscortService) GetEscorts(ctx context.Context, filters models.EscortFilters) ([]models.Escort, *models.Meta, error) {
-	// Set default pagination
-	if filters.Page <= 0 {
-		filters.Page = 1
-	}
-	if filters.PerPage <= 0 {
-		filters.PerPage = 10
-	}
-	if filters.PerPage > 100 {
-		filters.PerPage = 100
-	}
+// createWithSubmissionID inserts escort under a newly generated ticket code, drawing another
+// code when the first one is already taken
+func createWithSubmissionID(ctx context.Context, tx *repository.Store, escort *models.Escort) error {
+	for attempt := 1; ; attempt++ {
+		submissionID := newSubmissionID(time.Now())
+		escort.SubmissionID = &submissionID
 
-	// Build WHERE clause
-	whereClause := "WHERE 1=1"
-	args := []interface{}{}
-	argCount := 0
-
-	if filters.Status != "" {
-		argCount++
-		whereClause += fmt.Sprintf(" AND status = $%d", argCount)
-		args = append(args, filters.Status)
+		err := tx.Escorts.Create(ctx, escort)
+		if !errors.Is(err, repository.ErrSubmissionIDTaken) || attempt == submissionIDAttempts {
+			return err
+		}
 	}
+}
 
-	if filters.KategoriPengantar != "" {
-		argCount++
-		whereClause += fmt.Sprintf(" AND kategori_pengantar = $%d", argCount)
-		args = append(args, filters.KategoriPengantar)
+// checkDuplicate looks for an earlier escort that escort repeats. Depending on the policy it
+// links escort to the original or returns a *DuplicateEscortError.
+func (s *EscortService) checkDuplicate(ctx context.Context, tx *repository.Store, escort *models.Escort) error {
+	if s.duplicates.Window <= 0 {
+		return nil
 	}
 
-	if filters.JenisKelamin != "" {
-		argCount++
-		whereClause += fmt.Sprintf(" AND jenis_kelamin = $%d", argCount)
-		args = append(args, filters.JenisKelamin)
+	earlier, err := tx.Escorts.FindRecentDuplicate(ctx, *escort, time.Now().Add(-s.duplicates.Window))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
 	}
-
-	if filters.Search != "" {
-		argCount++
-		whereClause += fmt.Sprintf(" AND (nama_pengantar ILIKE $%d OR nama_pasien ILIKE $%d OR plat_nomor ILIKE $%d)", argCount, argCount, argCount)
-		args = append(args, "%"+filters.Search+"%")
+	if err != nil {
+		return err
 	}
 
-	// Build ORDER BY clause
-	orderClause := "ORDER BY created_at DESC"
-	if filters.SortBy != "" {
-		validSortFields := map[string]bool{
-			"id": true, "status": true, "kategori_pengantar": true,
-			"nama_pengantar": true, "nama_pasien": true, "created_at": true,
-		}
-		if validSortFields[filters.SortBy] {
-			sortOrder := "DESC"
-			if filters.SortOrder == "asc" {
-				sortOrder = "ASC"
-			}
-			orderClause = fmt.Sprintf("ORDER BY %s %s", filters.SortBy, sortOrder)
+	// Link every repeat to the first submission rather than to the previous repeat
+	original := earlier
+	if earlier.DuplicateOf != nil {
+		if first, err := tx.Escorts.GetByID(ctx, *earlier.DuplicateOf); err == nil {
+			original = first
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
 		}
 	}
 
-	// Get total count
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM escorts %s", whereClause)
-	var total int64
-	err := s.db.QueryRow(ctx, countQuery, args...).Scan(&total)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get total count: %w", err)
+	if s.duplicates.Action == DuplicateActionReject {
+		return &DuplicateEscortError{Original: original}
 	}
+	escort.DuplicateOf = &original.ID
+	return nil
+}
 
-	// Calculate pagination
-	offset := (filters.Page - 1) * filters.PerPage
-	totalPages := int(math.Ceil(float64(total) / float64(filters.PerPage)))
-
-	// Get escorts
-	query := fmt.Sprintf(`
-		SELECT id, status, kategori_pengantar, nama_pengantar, jenis_kelamin,
-		       nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
-		       submission_id, submitted_from_ip, api_submission,
-		       created_at, updated_at
-		FROM escorts %s %s
-		LIMIT $%d OFFSET $%d
-	`, whereClause, orderClause, argCount+1, argCount+2)
+// GetEscorts retrieves escorts with pagination and filtering
+func (s *EscortService) GetEscorts(ctx context.Context, filters models.EscortFilters) (_ []models.Escort, _ *models.Meta, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetEscorts")
+	defer telemetry.End(span, &err)
 
-	args = append(args, filters.PerPage, offset)
+	// Set default pagination
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PerPage <= 0 {
+		filters.PerPage = 10
+	}
+	if filters.PerPage > 100 {
+		filters.PerPage = 100
+	}
 
-	rows, err := s.db.Query(ctx, query, args...)
+	escorts, total, err := s.store.Escorts.List(ctx, repository.EscortQuery{EscortFilters: filters})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to query escorts: %w", err)
-	}
-	defer rows.Close()
-
-	var escorts []models.Escort
-	for rows.Next() {
-		var escort models.Escort
-		err := rows.Scan(
-			&escort.ID, &escort.Status, &escort.KategoriPengantar,
-			&escort.NamaPengantar, &escort.JenisKelamin, &escort.NomorHP,
-			&escort.PlatNomor, &escort.NamaPasien, &escort.FotoPengantar,
-			&escort.SubmissionID, &escort.SubmittedFromIP, &escort.APISubmission,
-			&escort.CreatedAt, &escort.UpdatedAt,
-		)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to scan escort: %w", err)
-		}
-		escorts = append(escorts, escort)
+		return nil, nil, err
 	}
 
 	meta := &models.Meta{
 		CurrentPage: filters.Page,
-		TotalPages:  totalPages,
+		TotalPages:  int(math.Ceil(float64(total) / float64(filters.PerPage))),
 		PerPage:     filters.PerPage,
 		Total:       total,
 	}
@@ -198,331 +252,293 @@ func (s *EscortService) GetEscorts(ctx context.Context, filters models.EscortFil
 }
 
 // GetEscortByID retrieves a single escort by ID
-func (s *EscortService) GetEscortByID(ctx context.Context, id uint) (*models.Escort, error) {
-	var escort models.Escort
-
-	query := `
-		SELECT id, status, kategori_pengantar, nama_pengantar, jenis_kelamin,
-		       nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
-		       submission_id, submitted_from_ip, api_submission,
-		       created_at, updated_at
-		FROM escorts WHERE id = $1
-	`
-
-	err := s.db.QueryRow(ctx, query, id).Scan(
-		&escort.ID, &escort.Status, &escort.KategoriPengantar,
-		&escort.NamaPengantar, &escort.JenisKelamin, &escort.NomorHP,
-		&escort.PlatNomor, &escort.NamaPasien, &escort.FotoPengantar,
-		&escort.SubmissionID, &escort.SubmittedFromIP, &escort.APISubmission,
-		&escort.CreatedAt, &escort.UpdatedAt,
-	)
+func (s *EscortService) GetEscortByID(ctx context.Context, id uint) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetEscortByID", escortIDAttr(id))
+	defer telemetry.End(span, &err)
 
+	escort, err := s.store.Escorts.GetByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get escort: %w", err)
+		return nil, escortError(err)
 	}
-
-	return &escort, nil
+	return escort, nil
 }
 
-// UpdateEscort updates an existing escort record
-func (s *EscortService) UpdateEscort(ctx context.Context, id uint, req models.UpdateEscortRequest) (*models.Escort, error) {
-	// Build dynamic update query
-	setParts := []string{"updated_at = NOW()"}
-	args := []interface{}{}
-	argCount := 0
-
-	if req.KategoriPengantar != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("kategori_pengantar = $%d", argCount))
-		args = append(args, *req.KategoriPengantar)
-	}
+// GetEscortBySubmissionID retrieves the escort with the given submission ID (ticket code). The
+// ID is normalized first, so codes typed in lower case or with O for 0 are still found.
+func (s *EscortService) GetEscortBySubmissionID(ctx context.Context, submissionID string) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetEscortBySubmissionID")
+	defer telemetry.End(span, &err)
 
-	if req.NamaPengantar != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("nama_pengantar = $%d", argCount))
-		args = append(args, *req.NamaPengantar)
-	}
-
-	if req.JenisKelamin != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("jenis_kelamin = $%d", argCount))
-		args = append(args, *req.JenisKelamin)
-	}
-
-	if req.NomorHP != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("nomor_hp = $%d", argCount))
-		args = append(args, *req.NomorHP)
+	escort, err := s.store.Escorts.GetBySubmissionID(ctx, normalizeSubmissionID(submissionID))
+	if err != nil {
+		return nil, escortError(err)
 	}
+	return escort, nil
+}
 
-	if req.PlatNomor != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("plat_nomor = $%d", argCount))
-		args = append(args, *req.PlatNomor)
-	}
+// UpdateEscort updates an existing escort record
+func (s *EscortService) UpdateEscort(ctx context.Context, id uint, req models.UpdateEscortRequest) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.UpdateEscort", escortIDAttr(id))
+	defer telemetry.End(span, &err)
 
-	if req.NamaPasien != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("nama_pasien = $%d", argCount))
-		args = append(args, *req.NamaPasien)
+	update := repository.EscortUpdate{
+		KategoriPengantar: req.KategoriPengantar,
+		NamaPengantar:     req.NamaPengantar,
+		JenisKelamin:      req.JenisKelamin,
+		NomorHP:           req.NomorHP,
+		PlatNomor:         req.PlatNomor,
+		NamaPasien:        req.NamaPasien,
 	}
 
-	// Handle image update
+	// Handle image update; the replaced photo is deleted once the update commits
+	images := s.newImageUnit()
 	if req.FotoPengantarB64 != nil && *req.FotoPengantarB64 != "" {
-		filename, err := s.saveBase64Image(*req.FotoPengantarB64)
+		filename, err := images.stageBase64(ctx, *req.FotoPengantarB64)
 		if err != nil {
 			return nil, fmt.Errorf("failed to save image: %w", err)
 		}
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("foto_pengantar = $%d", argCount))
-		args = append(args, filename)
+		update.FotoPengantar = &filename
 	}
 
-	if len(setParts) == 1 { // Only updated_at
+	if update.IsEmpty() {
 		return s.GetEscortByID(ctx, id)
 	}
 
-	argCount++
-	query := fmt.Sprintf("UPDATE escorts SET %s WHERE id = $%d", strings.Join(setParts, ", "), argCount)
-	args = append(args, id)
+	return s.applyUpdate(ctx, id, update, images)
+}
 
-	_, err := s.db.Exec(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update escort: %w", err)
-	}
+// applyUpdate stores an update and publishes it, returning the updated escort. images holds
+// the photo staged for update.FotoPengantar, if any; the photo it replaces is discarded.
+func (s *EscortService) applyUpdate(ctx context.Context, id uint, update repository.EscortUpdate, images *imageUnit) (*models.Escort, error) {
+	var escort *models.Escort
+	err := s.store.WithTx(ctx, func(tx *repository.Store) error {
+		if update.FotoPengantar != nil {
+			// Lock the row so a concurrent replacement cannot discard the photo stored here
+			current, err := tx.Escorts.GetForUpdate(ctx, id)
+			if err != nil {
+				return escortError(err)
+			}
+			images.discard(current.FotoPengantar)
+		}
 
-	return s.GetEscortByID(ctx, id)
-}
+		if err := tx.Escorts.Update(ctx, id, update); err != nil {
+			return escortError(err)
+		}
 
-// UpdateEscortStatus updates the status of an escort
-func (s *EscortService) UpdateEscortStatus(ctx context.Context, id uint, status string) (*models.Escort, error) {
-	query := "UPDATE escorts SET status = $1, updated_at = NOW() WHERE id = $2"
-	_, err := s.db.Exec(ctx, query, status, id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update escort status: %w", err)
+		var err error
+		if escort, err = tx.Escorts.GetByID(ctx, id); err != nil {
+			return escortError(err)
+		}
+
+		return publishEscortEvent(ctx, tx, &models.EscortEvent{
+			Type:     models.EscortEventUpdated,
+			EscortID: id,
+			Escort:   escort,
+		})
+	})
+	if err := images.complete(ctx, err); err != nil {
+		return nil, err
 	}
 
-	return s.GetEscortByID(ctx, id)
+	return escort, nil
 }
 
-// DeleteEscort deletes an escort record
-func (s *EscortService) DeleteEscort(ctx context.Context, id uint) error {
-	// First get the escort to check if it has an image
-	escort, err := s.GetEscortByID(ctx, id)
-	if err != nil {
-		return fmt.Errorf("failed to get escort for deletion: %w", err)
-	}
+// UpdateEscortStatus updates the status of an escort and records the transition in its history
+func (s *EscortService) UpdateEscortStatus(ctx context.Context, id uint, change models.StatusChange) (_ *models.Escort, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.UpdateEscortStatus", escortIDAttr(id))
+	defer telemetry.End(span, &err)
 
-	// Delete image file if exists
-	if escort.FotoPengantar != nil && *escort.FotoPengantar != "" {
-		s.deleteImageFile(*escort.FotoPengantar)
+	// Only rejections carry a reason; any other transition clears it
+	var rejectionReason *string
+	if change.Status == models.StatusRejected {
+		if change.ReasonCode == "" {
+			return nil, ErrRejectionReasonRequired
+		}
+		reason, err := s.store.RejectionReasons.Get(ctx, change.ReasonCode)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && !reason.Active) {
+			return nil, ErrUnknownRejectionReason
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check rejection reason: %w", err)
+		}
+		rejectionReason = &change.ReasonCode
+	} else {
+		change.ReasonCode = ""
 	}
 
-	query := "DELETE FROM escorts WHERE id = $1"
-	result, err := s.db.Exec(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete escort: %w", err)
-	}
+	var escort *models.Escort
+	var oldStatus string
+	err = s.store.WithTx(ctx, func(tx *repository.Store) error {
+		// Lock the row so concurrent transitions are checked and recorded in order
+		current, err := tx.Escorts.GetForUpdate(ctx, id)
+		if err != nil {
+			return escortError(err)
+		}
+		oldStatus = current.Status
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("escort not found")
-	}
+		if !models.CanTransition(oldStatus, change.Status) {
+			return &InvalidTransitionError{
+				From:    oldStatus,
+				To:      change.Status,
+				Allowed: models.AllowedTransitions(oldStatus),
+			}
+		}
 
-	return nil
-}
+		if err := tx.Escorts.UpdateStatus(ctx, id, change.Status, rejectionReason); err != nil {
+			return escortError(err)
+		}
 
-// GetDashboardStats retrieves dashboard statistics
-func (s *EscortService) GetDashboardStats(ctx context.Context) (*models.DashboardStats, error) {
-	stats := &models.DashboardStats{
-		CategoryStats:   make(map[string]int64),
-		StatusBreakdown: make(map[string]int64),
-	}
+		if err := tx.Escorts.AddStatusHistory(ctx, newStatusHistory(id, &oldStatus, change)); err != nil {
+			return err
+		}
 
-	// Get total counts
-	totalQuery := "SELECT COUNT(*) FROM escorts"
-	err := s.db.QueryRow(ctx, totalQuery).Scan(&stats.TotalEscorts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total escorts: %w", err)
-	}
-
-	// Get status counts
-	statusQuery := `
-		SELECT 
-			COUNT(CASE WHEN status = 'pending' THEN 1 END) as pending,
-			COUNT(CASE WHEN status = 'verified' THEN 1 END) as verified,
-			COUNT(CASE WHEN status = 'rejected' THEN 1 END) as rejected
-		FROM escorts
-	`
-	err = s.db.QueryRow(ctx, statusQuery).Scan(
-		&stats.PendingEscorts,
-		&stats.VerifiedEscorts,
-		&stats.RejectedEscorts,
-	)
+		if escort, err = tx.Escorts.GetByID(ctx, id); err != nil {
+			return escortError(err)
+		}
+
+		return publishEscortEvent(ctx, tx, &models.EscortEvent{
+			Type:      models.EscortEventStatusChanged,
+			EscortID:  id,
+			OldStatus: &oldStatus,
+			Escort:    escort,
+		})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get status counts: %w", err)
+		return nil, err
 	}
 
-	// Get today's submissions
-	todayQuery := "SELECT COUNT(*) FROM escorts WHERE DATE(created_at) = CURRENT_DATE"
-	err = s.db.QueryRow(ctx, todayQuery).Scan(&stats.TodaySubmissions)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get today's submissions: %w", err)
+	metrics.EscortStatusTransitions.WithLabelValues(oldStatus, escort.Status).Inc()
+	return escort, nil
+}
+
+// GetStatusHistory retrieves the status timeline of an escort, oldest first. History outlives
+// deleted escorts, and escorts created before history was recorded have none, so
+// ErrEscortNotFound is returned only when there is neither history nor an escort.
+func (s *EscortService) GetStatusHistory(ctx context.Context, id uint) (_ []models.EscortStatusHistory, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetStatusHistory", escortIDAttr(id))
+	defer telemetry.End(span, &err)
+
+	history, err := s.store.Escorts.StatusHistory(ctx, id)
+	if err != nil || len(history) > 0 {
+		return history, err
+	}
+	if _, err := s.store.Escorts.GetByID(ctx, id); err != nil {
+		return nil, escortError(err)
 	}
+	return []models.EscortStatusHistory{}, nil
+}
 
-	// Get category breakdown
-	categoryQuery := "SELECT kategori_pengantar, COUNT(*) FROM escorts GROUP BY kategori_pengantar"
-	rows, err := s.db.Query(ctx, categoryQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get category stats: %w", err)
+// publishEscortEvent sends an event to the real-time feed and queues its webhook deliveries.
+// It must be called with the Store of the transaction making the change.
+func publishEscortEvent(ctx context.Context, tx *repository.Store, event *models.EscortEvent) error {
+	if err := tx.Events.Publish(ctx, event); err != nil {
+		return err
 	}
-	defer rows.Close()
+	return enqueueWebhooks(ctx, tx.Webhooks, *event)
+}
 
-	for rows.Next() {
-		var category string
-		var count int64
-		err := rows.Scan(&category, &count)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan category stats: %w", err)
-		}
-		stats.CategoryStats[category] = count
+// newStatusHistory builds the escort_status_history entry for a transition
+func newStatusHistory(escortID uint, oldStatus *string, change models.StatusChange) *models.EscortStatusHistory {
+	return &models.EscortStatusHistory{
+		EscortID:   escortID,
+		OldStatus:  oldStatus,
+		NewStatus:  change.Status,
+		ReasonCode: nilIfEmpty(change.ReasonCode),
+		ChangedBy:  change.ChangedBy,
+		Note:       nilIfEmpty(change.Note),
+		ClientIP:   nilIfEmpty(change.ClientIP),
 	}
+}
 
-	// Get status breakdown
-	statusBreakdownQuery := "SELECT status, COUNT(*) FROM escorts GROUP BY status"
-	rows, err = s.db.Query(ctx, statusBreakdownQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get status breakdown: %w", err)
+func nilIfEmpty(value string) *string {
+	if value == "" {
+		return nil
 	}
-	defer rows.Close()
+	return &value
+}
+
+// DeleteEscort deletes an escort record. Its photo is deleted once the row is gone.
+func (s *EscortService) DeleteEscort(ctx context.Context, id uint) (err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.DeleteEscort", escortIDAttr(id))
+	defer telemetry.End(span, &err)
 
-	for rows.Next() {
-		var status string
-		var count int64
-		err := rows.Scan(&status, &count)
+	images := s.newImageUnit()
+	err = s.store.WithTx(ctx, func(tx *repository.Store) error {
+		escort, err := tx.Escorts.GetForUpdate(ctx, id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan status breakdown: %w", err)
+			return escortError(err)
 		}
-		stats.StatusBreakdown[status] = count
-	}
-
-	// Get recent escorts (last 5)
-	recentQuery := `
-		SELECT id, status, kategori_pengantar, nama_pengantar, jenis_kelamin,
-		       nomor_hp, plat_nomor, nama_pasien, foto_pengantar,
-		       submission_id, submitted_from_ip, api_submission,
-		       created_at, updated_at
-		FROM escorts 
-		ORDER BY created_at DESC 
-		LIMIT 5
-	`
-	rows, err = s.db.Query(ctx, recentQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get recent escorts: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var escort models.Escort
-		err := rows.Scan(
-			&escort.ID, &escort.Status, &escort.KategoriPengantar,
-			&escort.NamaPengantar, &escort.JenisKelamin, &escort.NomorHP,
-			&escort.PlatNomor, &escort.NamaPasien, &escort.FotoPengantar,
-			&escort.SubmissionID, &escort.SubmittedFromIP, &escort.APISubmission,
-			&escort.CreatedAt, &escort.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan recent escort: %w", err)
+		images.discard(escort.FotoPengantar)
+
+		if err := tx.Escorts.Delete(ctx, id); err != nil {
+			return escortError(err)
 		}
-		stats.RecentEscorts = append(stats.RecentEscorts, escort)
-	}
 
-	return stats, nil
+		return publishEscortEvent(ctx, tx, &models.EscortEvent{
+			Type:      models.EscortEventDeleted,
+			EscortID:  id,
+			OldStatus: &escort.Status,
+		})
+	})
+	return images.complete(ctx, err)
+}
+
+// GetDashboardStats retrieves dashboard statistics
+func (s *EscortService) GetDashboardStats(ctx context.Context) (_ *models.DashboardStats, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetDashboardStats")
+	defer telemetry.End(span, &err)
+
+	return s.store.Escorts.Stats(ctx)
 }
 
 // GetImageAsBase64 returns an image as base64 string
-func (s *EscortService) GetImageAsBase64(ctx context.Context, id uint) (string, error) {
+func (s *EscortService) GetImageAsBase64(ctx context.Context, id uint) (_ string, err error) {
+	ctx, span := telemetry.Start(ctx, "EscortService.GetImageAsBase64", escortIDAttr(id))
+	defer telemetry.End(span, &err)
+
 	escort, err := s.GetEscortByID(ctx, id)
 	if err != nil {
 		return "", err
 	}
 
 	if escort.FotoPengantar == nil || *escort.FotoPengantar == "" {
-		return "", fmt.Errorf("no image found for escort")
+		return "", ErrImageNotFound
 	}
 
-	return s.loadImageAsBase64(*escort.FotoPengantar)
+	return s.loadImageAsBase64(ctx, *escort.FotoPengantar)
 }
 
-// saveBase64Image saves a base64 encoded image to file system
-func (s *EscortService) saveBase64Image(base64Data string) (string, error) {
+// decodeBase64Image decodes a base64 encoded photo and returns it with the MIME type
+// declared by its data URL prefix, which must match the content when it is stored
+func decodeBase64Image(base64Data string) ([]byte, string, error) {
 	// Parse data URL (data:image/jpeg;base64,...)
 	parts := strings.SplitN(base64Data, ",", 2)
 	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid base64 data format")
+		return nil, "", imageError(ImageCodeMalformed, "invalid base64 data format")
 	}
 
-	// Extract MIME type
-	mimeType := "image/jpeg" // default
+	// Declared MIME type, checked against the content
+	claimedType := ""
 	if strings.HasPrefix(parts[0], "data:") {
-		mimeType = strings.TrimPrefix(strings.Split(parts[0], ";")[0], "data:")
-	}
-
-	// Validate MIME type
-	validTypes := map[string]string{
-		"image/jpeg": ".jpg",
-		"image/jpg":  ".jpg",
-		"image/png":  ".png",
-		"image/gif":  ".gif",
+		claimedType = strings.TrimPrefix(strings.Split(parts[0], ";")[0], "data:")
 	}
 
-	ext, valid := validTypes[mimeType]
-	if !valid {
-		return "", fmt.Errorf("unsupported image format: %s", mimeType)
+	// Reject oversized payloads before decoding them
+	if base64.StdEncoding.DecodedLen(len(parts[1])) > maxImageSize+3 {
+		return nil, "", ErrImageTooLarge
 	}
 
 	// Decode base64
 	data, err := base64.StdEncoding.DecodeString(parts[1])
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %w", err)
-	}
-
-	// Check file size (2MB limit)
-	if len(data) > 2*1024*1024 {
-		return "", fmt.Errorf("image too large (max 2MB)")
-	}
-
-	// Create uploads directory if not exists
-	uploadDir := "storage/uploads"
-	err = os.MkdirAll(uploadDir, 0755)
-	if err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %w", err)
+		return nil, "", imageError(ImageCodeMalformed, "failed to decode base64: %v", err)
 	}
-
-	// Generate unique filename
-	filename := fmt.Sprintf("escort_%d%s", time.Now().UnixNano(), ext)
-	filepath := filepath.Join(uploadDir, filename)
-
-	// Save file
-	file, err := os.Create(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	_, err = file.Write(data)
-	if err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return filename, nil
+	return data, claimedType, nil
 }
 
 // loadImageAsBase64 loads an image file and returns it as base64
-func (s *EscortService) loadImageAsBase64(filename string) (string, error) {
-	filepath := filepath.Join("storage/uploads", filename)
-
-	file, err := os.Open(filepath)
+func (s *EscortService) loadImageAsBase64(ctx context.Context, filename string) (string, error) {
+	file, info, err := s.files.Open(ctx, imageKey(filename))
 	if err != nil {
 		return "", fmt.Errorf("failed to open image file: %w", err)
 	}
@@ -534,24 +550,7 @@ func (s *EscortService) loadImageAsBase64(filename string) (string, error) {
 		return "", fmt.Errorf("failed to read image file: %w", err)
 	}
 
-	// Detect MIME type based on file extension
-	lastDot := strings.LastIndex(filename, ".")
-	ext := ""
-	if lastDot >= 0 {
-		ext = filename[lastDot:]
-	}
-	mimeType := mime.TypeByExtension(ext)
-	if mimeType == "" {
-		mimeType = "image/jpeg" // default
-	}
-
 	// Encode as base64 data URL
 	encoded := base64.StdEncoding.EncodeToString(data)
-	return fmt.Sprintf("data:%s;base64,%s", mimeType, encoded), nil
-}
-
-// deleteImageFile deletes an image file from file system
-func (s *EscortService) deleteImageFile(filename string) {
-	filepath := filepath.Join("storage/uploads", filename)
-	os.Remove(filepath) // Ignore errors for cleanup
+	return fmt.Sprintf("data:%s;base64,%s", info.ContentType, encoded), nil
 }
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+// ErrInvalidExportFilter is returned when export date filters cannot be parsed
+var ErrInvalidExportFilter = errors.New("invalid export filter")
+
+// ExportHeadings are the column headings used by Laravel's EscortExport and CsvExportService
+var ExportHeadings = []string{
+	"No",
+	"Kategori Pengantar",
+	"Nama Pengantar",
+	"Jenis Kelamin",
+	"Nomor HP",
+	"Plat Nomor",
+	"Nama Pasien",
+	"Status",
+	"Tanggal Masuk",
+	"Waktu Masuk",
+	"Submission ID",
+	"IP Address",
+}
+
+const exportDateLayout = "2006-01-02"
+
+// Export formats accepted by the export endpoint and the `escort export` command
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// ExportContentTypes maps each export format to its response Content-Type
+var ExportContentTypes = map[string]string{
+	ExportFormatCSV:  "text/csv; charset=UTF-8",
+	ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type ExportService struct {
+	escorts repository.EscortRepository
+}
+
+func NewExportService(escorts repository.EscortRepository) *ExportService {
+	return &ExportService{escorts: escorts}
+}
+
+// Write exports escorts matching filters in the given format (see ExportContentTypes)
+func (s *ExportService) Write(ctx context.Context, w io.Writer, format string, filters models.ExportFilters) (int, error) {
+	switch format {
+	case ExportFormatCSV:
+		return s.WriteCSV(ctx, w, filters)
+	case ExportFormatXLSX:
+		return s.WriteXLSX(ctx, w, filters)
+	default:
+		return 0, fmt.Errorf("%w: format must be csv or xlsx", ErrInvalidExportFilter)
+	}
+}
+
+// WriteCSV streams escorts matching filters as CSV in the same layout as CsvExportService
+func (s *ExportService) WriteCSV(ctx context.Context, w io.Writer, filters models.ExportFilters) (int, error) {
+	total, err := s.CountEscorts(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+
+	// BOM for UTF-8 Excel compatibility
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"# Data Escort IGD - Periode: " + exportPeriod(filters)})
+	writer.Write([]string{"# Diekspor pada: " + time.Now().Format("02/01/2006 15:04:05")})
+	writer.Write([]string{fmt.Sprintf("# Total data: %d record", total)})
+	writer.Write([]string{""})
+	writer.Write(ExportHeadings)
+
+	count := 0
+	err = s.EachEscort(ctx, filters, func(escort models.Escort) error {
+		count++
+		row := ExportRow(count, escort)
+		row[4] = "'" + row[4] // Preserve leading zeros of phone numbers in Excel
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		// Flush regularly so large exports reach the client as they are read
+		if count%500 == 0 {
+			writer.Flush()
+			return writer.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return count, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountEscorts counts escorts matching export filters
+func (s *ExportService) CountEscorts(ctx context.Context, filters models.ExportFilters) (int64, error) {
+	query, err := exportQuery(filters)
+	if err != nil {
+		return 0, err
+	}
+	return s.escorts.Count(ctx, query)
+}
+
+// EachEscort calls fn for every escort matching filters, newest first, reading rows as they arrive
+func (s *ExportService) EachEscort(ctx context.Context, filters models.ExportFilters, fn func(models.Escort) error) error {
+	query, err := exportQuery(filters)
+	if err != nil {
+		return err
+	}
+	return s.escorts.Each(ctx, query, fn)
+}
+
+// ExportRow maps an escort to the export columns listed in ExportHeadings
+func ExportRow(number int, escort models.Escort) []string {
+	return []string{
+		strconv.Itoa(number),
+		escort.KategoriPengantar,
+		escort.NamaPengantar,
+		escort.JenisKelamin,
+		escort.NomorHP,
+		escort.PlatNomor,
+		escort.NamaPasien,
+		models.StatusDisplayName(escort.Status),
+		escort.CreatedAt.Format("02/01/2006"),
+		escort.CreatedAt.Format("15:04:05"),
+		valueOrDash(escort.SubmissionID),
+		valueOrDash(escort.SubmittedFromIP),
+	}
+}
+
+// exportQuery extends the listing filters with an inclusive created_at date range
+func exportQuery(filters models.ExportFilters) (repository.EscortQuery, error) {
+	query := repository.EscortQuery{EscortFilters: filters.EscortFilters}
+
+	if filters.StartDate != "" {
+		start, err := time.Parse(exportDateLayout, filters.StartDate)
+		if err != nil {
+			return query, fmt.Errorf("%w: start_date must be YYYY-MM-DD", ErrInvalidExportFilter)
+		}
+		query.CreatedFrom = &start
+	}
+
+	if filters.EndDate != "" {
+		end, err := time.Parse(exportDateLayout, filters.EndDate)
+		if err != nil {
+			return query, fmt.Errorf("%w: end_date must be YYYY-MM-DD", ErrInvalidExportFilter)
+		}
+		before := end.AddDate(0, 0, 1)
+		query.CreatedBefore = &before
+	}
+
+	if query.CreatedFrom != nil && query.CreatedBefore != nil && !query.CreatedFrom.Before(*query.CreatedBefore) {
+		return query, fmt.Errorf("%w: end_date must not be before start_date", ErrInvalidExportFilter)
+	}
+
+	return query, nil
+}
+
+// exportPeriod describes the exported date range for the CSV metadata header
+func exportPeriod(filters models.ExportFilters) string {
+	if filters.StartDate == "" && filters.EndDate == "" {
+		return "Semua data"
+	}
+	return displayDate(filters.StartDate) + " - " + displayDate(filters.EndDate)
+}
+
+func displayDate(value string) string {
+	if value == "" {
+		return "..."
+	}
+	date, err := time.Parse(exportDateLayout, value)
+	if err != nil {
+		return value
+	}
+	return date.Format("02/01/2006")
+}
+
+func valueOrDash(value *string) string {
+	if value == nil || *value == "" {
+		return "-"
+	}
+	return *value
+}
+
+// ExportFilename builds the download name used by EscortDataController, e.g.
+// Data_Escort_IGD_01-01-2025_sampai_31-01-2025_142501.xlsx
+func ExportFilename(filters models.ExportFilters, format string, now time.Time) string {
+	period := "Semua_Data"
+	if filters.StartDate != "" || filters.EndDate != "" {
+		period = orDefault(fileDate(filters.StartDate), "awal") + "_sampai_" + orDefault(fileDate(filters.EndDate), "sekarang")
+	}
+	return fmt.Sprintf("Data_Escort_IGD_%s_%s.%s", period, now.Format("150405"), format)
+}
+
+// fileDate formats a YYYY-MM-DD filter date as dd-mm-yyyy
+func fileDate(value string) string {
+	if value == "" {
+		return ""
+	}
+	date, err := time.Parse(exportDateLayout, value)
+	if err != nil {
+		return value
+	}
+	return date.Format("02-01-2006")
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
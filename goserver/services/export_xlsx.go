@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"goserver/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// exportColumnWidths are the column widths of EscortExport::columnWidths, in ExportHeadings order
+var exportColumnWidths = []float64{8, 18, 25, 15, 18, 15, 25, 12, 15, 12, 20, 18}
+
+// WriteXLSX writes escorts matching filters as an Excel workbook styled like Laravel's EscortExport.
+// Rows are streamed from the database into excelize's stream writer, which spills to a temporary
+// file once the sheet grows large, so memory use stays bounded. The workbook is written to w
+// only after every row has been read, so a failing query leaves w untouched.
+func (s *ExportService) WriteXLSX(ctx context.Context, w io.Writer, filters models.ExportFilters) (int, error) {
+	if _, err := exportQuery(filters); err != nil {
+		return 0, err
+	}
+
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := exportSheetName(filters)
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return 0, fmt.Errorf("failed to create worksheet: %w", err)
+	}
+
+	headerStyle, dataStyle, err := exportStyles(file)
+	if err != nil {
+		return 0, err
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create worksheet: %w", err)
+	}
+
+	// Column widths must be set before the first row is written
+	for i, width := range exportColumnWidths {
+		if err := stream.SetColWidth(i+1, i+1, width); err != nil {
+			return 0, fmt.Errorf("failed to set column width: %w", err)
+		}
+	}
+
+	header := make([]interface{}, len(ExportHeadings))
+	for i, heading := range ExportHeadings {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: heading}
+	}
+	if err := stream.SetRow("A1", header, excelize.RowOpts{Height: 20}); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	count := 0
+	err = s.EachEscort(ctx, filters, func(escort models.Escort) error {
+		count++
+		values := ExportRow(count, escort)
+
+		row := make([]interface{}, len(values))
+		row[0] = excelize.Cell{StyleID: dataStyle, Value: count}
+		for i := 1; i < len(values); i++ {
+			row[i] = excelize.Cell{StyleID: dataStyle, Value: values[i]}
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, count+1)
+		if err != nil {
+			return err
+		}
+		return stream.SetRow(cell, row)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if err := stream.Flush(); err != nil {
+		return count, fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := file.Write(w); err != nil {
+		return count, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return count, nil
+}
+
+// exportStyles registers the header and data cell styles of EscortExport::styles
+func exportStyles(file *excelize.File) (int, int, error) {
+	borders := []excelize.Border{
+		{Type: "left", Color: "000000", Style: 1},
+		{Type: "top", Color: "000000", Style: 1},
+		{Type: "right", Color: "000000", Style: 1},
+		{Type: "bottom", Color: "000000", Style: 1},
+	}
+
+	headerStyle, err := file.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF", Size: 12},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"4472C4"}},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true},
+		Border:    borders,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create header style: %w", err)
+	}
+
+	dataStyle, err := file.NewStyle(&excelize.Style{
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"F8F9FA"}},
+		Alignment: &excelize.Alignment{Vertical: "center", WrapText: true},
+		Border:    borders,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create data style: %w", err)
+	}
+
+	return headerStyle, dataStyle, nil
+}
+
+// exportSheetName mirrors EscortExport::title. Excel limits sheet names to 31 characters,
+// which "Data IGD dd-mm-yyyy - dd-mm-yyyy" exceeds, so ranged titles drop the "Data" prefix.
+func exportSheetName(filters models.ExportFilters) string {
+	if filters.StartDate == "" && filters.EndDate == "" {
+		return "Data IGD"
+	}
+	return fmt.Sprintf("IGD %s - %s", orDefault(fileDate(filters.StartDate), "..."), orDefault(fileDate(filters.EndDate), "..."))
+}
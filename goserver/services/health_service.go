@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"goserver/database"
+	"goserver/filestore"
+	"goserver/logging"
+	"goserver/models"
+	"goserver/repository"
+)
+
+// Names of the readiness checks
+const (
+	HealthCheckDatabase   = "database"
+	HealthCheckStorage    = "storage"
+	HealthCheckMigrations = "migrations"
+)
+
+// HealthCheck checks that one dependency of the server is usable
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthService answers the liveness and readiness probes
+type HealthService struct {
+	build     models.BuildInfo
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	checks    []*healthCheckState
+	startedAt time.Time
+}
+
+// healthCheckState holds the last result of a check
+type healthCheckState struct {
+	HealthCheck
+	mu        sync.Mutex
+	result    models.ComponentHealth
+	checkedAt time.Time
+}
+
+// NewHealthService creates the probes of a server started now. Each readiness check gets
+// timeout to finish, and its result is reused for cacheTTL: the probes are public, and
+// checks such as writing to an S3 bucket are too expensive to run on every request.
+func NewHealthService(build models.BuildInfo, timeout, cacheTTL time.Duration, checks ...HealthCheck) *HealthService {
+	s := &HealthService{build: build, timeout: timeout, cacheTTL: cacheTTL, startedAt: time.Now()}
+	for _, check := range checks {
+		s.checks = append(s.checks, &healthCheckState{HealthCheck: check})
+	}
+	return s
+}
+
+// Live reports that the process is up, without checking any dependency
+func (s *HealthService) Live() *models.HealthReport {
+	return &models.HealthReport{
+		Status:        models.HealthOK,
+		BuildInfo:     s.build,
+		StartedAt:     s.startedAt,
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+	}
+}
+
+// Ready runs every check whose cached result is too old, all at once, and reports whether
+// the server can handle requests: it is ready only when all checks pass
+func (s *HealthService) Ready(ctx context.Context) *models.HealthReport {
+	report := s.Live()
+	report.Components = make([]models.ComponentHealth, len(s.checks))
+
+	var wg sync.WaitGroup
+	for i, state := range s.checks {
+		wg.Go(func() {
+			report.Components[i] = s.result(ctx, state)
+		})
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Status != models.HealthOK {
+			report.Status = models.HealthFail
+		}
+	}
+	return report
+}
+
+// result returns the cached result of a check, running it again once it is older than the
+// cache TTL. Probes arriving while the check runs wait for it and share its result.
+func (s *HealthService) result(ctx context.Context, state *healthCheckState) models.ComponentHealth {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if !state.checkedAt.IsZero() && time.Since(state.checkedAt) < s.cacheTTL {
+		return state.result
+	}
+	state.result = s.run(ctx, state.HealthCheck)
+	state.checkedAt = time.Now()
+	return state.result
+}
+
+// run runs check within the timeout. A prober hanging up does not cut the check short, as
+// its result is shared with the other probes.
+func (s *HealthService) run(ctx context.Context, check HealthCheck) models.ComponentHealth {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	component := models.ComponentHealth{
+		Name:      check.Name,
+		Status:    models.HealthOK,
+		LatencyMS: logging.Milliseconds(time.Since(start)),
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("no answer within %v", s.timeout)
+		}
+		component.Status = models.HealthFail
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// DatabaseReachable checks that the store answers a ping
+func DatabaseReachable(store *repository.Store) HealthCheck {
+	return HealthCheck{Name: HealthCheckDatabase, Check: store.Ping}
+}
+
+// MigrationsApplied checks that the schema is up to date; until `goserver migrate up` has
+// run, queries against new tables and columns would fail. It only reads schema_migrations.
+func MigrationsApplied(migrator *database.Migrator) HealthCheck {
+	return HealthCheck{
+		Name: HealthCheckMigrations,
+		Check: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d migration(s) pending, starting with %s", len(pending), pending[0].Name)
+			}
+			return nil
+		},
+	}
+}
+
+// healthProbePrefix names the files written by StorageWritable; the storage scan skips
+// files whose name starts with a dot
+const healthProbePrefix = ".readyz-"
+
+// StorageWritable checks that files can be stored by writing and deleting a small file
+func StorageWritable(files filestore.Storage) HealthCheck {
+	return HealthCheck{
+		Name: HealthCheckStorage,
+		Check: func(ctx context.Context) error {
+			b := make([]byte, 8)
+			rand.Read(b)
+			key := healthProbePrefix + hex.EncodeToString(b)
+
+			if err := files.Put(ctx, key, strings.NewReader("ok"), 2, "text/plain"); err != nil {
+				return fmt.Errorf("%s is not writable: %w", files, err)
+			}
+			if err := files.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to delete the probe file from %s: %w", files, err)
+			}
+			return nil
+		},
+	}
+}
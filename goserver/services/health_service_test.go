@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goserver/filestore"
+	"goserver/models"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	ok := HealthCheck{Name: "ok", Check: func(context.Context) error { return nil }}
+	failing := HealthCheck{Name: "failing", Check: func(context.Context) error { return errors.New("connection refused") }}
+	slow := HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	health := NewHealthService(models.BuildInfo{Version: "1.2.3", Commit: "abc123"}, 50*time.Millisecond, 0, ok, failing, slow)
+
+	report := health.Ready(t.Context())
+	if report.Status != models.HealthFail || report.Version != "1.2.3" || report.Commit != "abc123" {
+		t.Fatalf("report = %+v", report)
+	}
+	want := map[string]string{
+		"ok":      "",
+		"failing": "connection refused",
+		"slow":    "no answer within 50ms",
+	}
+	for i, component := range report.Components {
+		if component.Name != []string{"ok", "failing", "slow"}[i] {
+			t.Fatalf("components are not in check order: %+v", report.Components)
+		}
+		if component.Error != want[component.Name] || (component.Error == "") != (component.Status == models.HealthOK) {
+			t.Errorf("%s = %+v", component.Name, component)
+		}
+	}
+	if slowest := report.Components[2].LatencyMS; slowest < 50 {
+		t.Errorf("slow check latency = %vms, want at least the 50ms timeout", slowest)
+	}
+
+	if report := NewHealthService(models.BuildInfo{}, time.Second, 0, ok).Ready(t.Context()); report.Status != models.HealthOK {
+		t.Errorf("report with passing checks = %+v", report)
+	}
+	if live := health.Live(); live.Status != models.HealthOK || len(live.Components) != 0 {
+		t.Errorf("Live() = %+v, want ok without running the checks", live)
+	}
+}
+
+func TestHealthServiceCachesResults(t *testing.T) {
+	var calls atomic.Int32
+	counted := HealthCheck{Name: "counted", Check: func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return errors.New("bucket unreachable")
+	}}
+	health := NewHealthService(models.BuildInfo{}, time.Second, time.Hour, counted)
+
+	// Probes arriving together share one run, later ones reuse its result
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() { health.Ready(t.Context()) })
+	}
+	wg.Wait()
+	report := health.Ready(t.Context())
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("check ran %d times, want once", got)
+	}
+	if report.Status != models.HealthFail || report.Components[0].Error != "bucket unreachable" {
+		t.Fatalf("cached report = %+v", report)
+	}
+
+	// A prober that hung up does not turn the shared result into a failure
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	passing := NewHealthService(models.BuildInfo{}, time.Second, time.Hour, HealthCheck{Name: "ok", Check: func(ctx context.Context) error {
+		return ctx.Err()
+	}})
+	if report := passing.Ready(ctx); report.Status != models.HealthOK {
+		t.Fatalf("report for a cancelled probe = %+v", report)
+	}
+}
+
+func TestStorageWritable(t *testing.T) {
+	dir := t.TempDir()
+	files := filestore.NewLocal(dir)
+	if err := StorageWritable(files).Check(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The probe file is removed again
+	var left []string
+	files.Walk(t.Context(), func(info filestore.Info) error {
+		left = append(left, info.Key)
+		return nil
+	})
+	if len(left) != 0 {
+		t.Errorf("storage holds %v after the check", left)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+const (
+	// idempotencyLease is how long a request may stay in progress before its key is
+	// considered abandoned (e.g. the server stopped) and can be reserved again
+	idempotencyLease = time.Minute
+	// idempotencyCleanupInterval is how often expired records are deleted
+	idempotencyCleanupInterval = time.Hour
+	maxIdempotencyKeyLength    = 255
+)
+
+var (
+	// ErrInvalidIdempotencyKey is returned for a key that is too long or not printable ASCII
+	ErrInvalidIdempotencyKey = errors.New("Idempotency-Key must be 1-255 printable ASCII characters")
+	// ErrIdempotencyKeyInProgress is returned while the first request with a key is still handled
+	ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still in progress")
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request body
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+)
+
+// IdempotencyService lets clients retry a request safely: the response to the first request
+// with an Idempotency-Key is stored and replayed for every retry within the TTL
+type IdempotencyService struct {
+	keys repository.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService creates the service; responses are replayed for ttl
+func NewIdempotencyService(keys repository.IdempotencyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{keys: keys, ttl: ttl}
+}
+
+// Begin reserves key within scope (the method and route) for a request with the given body.
+// It returns nil when the request should be handled, and the stored record when its response
+// should be replayed instead.
+func (s *IdempotencyService) Begin(ctx context.Context, scope, key string, body []byte) (*models.IdempotencyRecord, error) {
+	if !validIdempotencyKey(key) {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	hash := sha256.Sum256(body)
+	now := time.Now()
+	record := &models.IdempotencyRecord{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+
+	stored, reserved, err := s.keys.Reserve(ctx, record, now.Add(-idempotencyLease))
+	switch {
+	case err != nil:
+		return nil, err
+	case reserved:
+		return nil, nil
+	case stored.RequestHash != record.RequestHash:
+		return nil, ErrIdempotencyKeyReused
+	case !stored.Completed():
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return stored, nil
+}
+
+// Complete stores the response of a request reserved with Begin
+func (s *IdempotencyService) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	if err := s.keys.Complete(ctx, scope, key, statusCode, contentType, body); err != nil {
+		return fmt.Errorf("failed to store response for Idempotency-Key %q: %w", key, err)
+	}
+	return nil
+}
+
+// Release gives up a reservation, so a retry is handled as a new request
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) error {
+	return s.keys.Release(ctx, scope, key)
+}
+
+// Run deletes expired records every hour until ctx is cancelled
+func (s *IdempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.keys.DeleteExpired(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to delete expired idempotency keys", "error", err)
+			}
+		}
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
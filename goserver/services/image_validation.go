@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"runtime"
+
+	"golang.org/x/image/draw"
+)
+
+// Codes of rejected photos, returned to clients in ImageError.Code
+const (
+	ImageCodeEmpty           = "image_empty"
+	ImageCodeMalformed       = "image_malformed"        // not a data URL or not valid base64
+	ImageCodeUnsupportedType = "image_unsupported_type" // content is not JPEG, PNG or GIF
+	ImageCodeTypeMismatch    = "image_type_mismatch"    // declared type differs from the content
+	ImageCodeUndecodable     = "image_undecodable"      // looks like an image but does not decode
+	ImageCodeTooLarge        = "image_too_large"        // more than maxImageSize bytes
+	ImageCodeDimensions      = "image_dimensions_exceeded"
+)
+
+// Pixel limits, checked from the image header before the pixels are decoded. 16 megapixels
+// covers phone cameras and decodes to at most 64 MB of RGBA.
+const (
+	maxImageDimension = 6000
+	maxImagePixels    = 16_000_000
+)
+
+// imageSlots bounds how many photos are decoded, re-encoded and scaled into variants at once,
+// so parallel uploads cannot hold more than a few decoded photos in memory. Only encoded bytes
+// are kept once a slot is freed. The work is CPU-bound anyway.
+var imageSlots = make(chan struct{}, min(runtime.GOMAXPROCS(0), 4))
+
+// acquireImageSlot waits for a free slot in imageSlots; the returned function frees it
+func acquireImageSlot(ctx context.Context) (func(), error) {
+	select {
+	case imageSlots <- struct{}{}:
+		return func() { <-imageSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+const imageJPEGQuality = 90
+
+// ImageError is a rejected photo. It matches ErrInvalidImage with errors.Is.
+type ImageError struct {
+	Code    string
+	Message string
+}
+
+func (e *ImageError) Error() string {
+	return e.Message
+}
+
+func (e *ImageError) Is(target error) bool {
+	return target == ErrInvalidImage
+}
+
+func imageError(code, format string, args ...any) error {
+	return &ImageError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// imageSignatures are the magic bytes of the accepted formats
+var imageSignatures = []struct {
+	magic    string
+	mimeType string
+}{
+	{"\xFF\xD8\xFF", "image/jpeg"},
+	{"\x89PNG\r\n\x1A\n", "image/png"},
+	{"GIF87a", "image/gif"},
+	{"GIF89a", "image/gif"},
+}
+
+// sniffImageType returns the MIME type of data from its magic bytes, or "" for other content
+func sniffImageType(data []byte) string {
+	for _, signature := range imageSignatures {
+		if bytes.HasPrefix(data, []byte(signature.magic)) {
+			return signature.mimeType
+		}
+	}
+	return ""
+}
+
+// sanitizeImage checks that data really is an accepted photo within the size and pixel limits
+// and re-encodes it, which drops EXIF (including GPS position) and every other metadata, as
+// well as anything appended to the image. JPEG orientation is applied to the pixels first.
+// claimedType is the MIME type the client declared, if any; it must match the content.
+// It returns the decoded image, the encoded bytes and their file extension. Callers hold an
+// image slot (see acquireImageSlot).
+func sanitizeImage(data []byte, claimedType string) (image.Image, []byte, string, error) {
+	if len(data) == 0 {
+		return nil, nil, "", imageError(ImageCodeEmpty, "image is empty")
+	}
+	if len(data) > maxImageSize {
+		return nil, nil, "", ErrImageTooLarge
+	}
+
+	mimeType := sniffImageType(data)
+	if mimeType == "" {
+		return nil, nil, "", imageError(ImageCodeUnsupportedType, "unsupported image format: content is not JPEG, PNG or GIF")
+	}
+	if claimedType != "" {
+		claimedExt, known := imageExtensions[claimedType]
+		if !known {
+			return nil, nil, "", imageError(ImageCodeUnsupportedType, "unsupported image format: %s", claimedType)
+		}
+		if claimedExt != imageExtensions[mimeType] {
+			return nil, nil, "", imageError(ImageCodeTypeMismatch, "image declared as %s but content is %s", claimedType, mimeType)
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, "", imageError(ImageCodeUndecodable, "image cannot be decoded: %v", err)
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension || config.Width*config.Height > maxImagePixels {
+		return nil, nil, "", imageError(ImageCodeDimensions, "image is %dx%d pixels (max %dx%d, %d megapixels)",
+			config.Width, config.Height, maxImageDimension, maxImageDimension, maxImagePixels/1_000_000)
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, nil, "", imageError(ImageCodeUndecodable, "image cannot be decoded: %v", err)
+	}
+
+	var buf bytes.Buffer
+	switch mimeType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJPEGQuality})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	case "image/gif":
+		// Only the first frame is kept
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	if buf.Len() > maxImageSize {
+		return nil, nil, "", ErrImageTooLarge
+	}
+
+	return img, buf.Bytes(), imageExtensions[mimeType], nil
+}
+
+// decodeImage decodes a photo, turning JPEGs upright according to their EXIF orientation
+func decodeImage(data []byte) (image.Image, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when it has none
+func jpegOrientation(data []byte) int {
+	// Walk the segments up to the start of the image data, looking for the APP1 Exif segment
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan, end of image
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos = end
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag (0x0112) of IFD0 from a TIFF-structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips img so that EXIF orientation 1 (upright) applies
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// JPEGs decode to YCbCr and are read as they are; only other color models (such as
+	// grayscale or CMYK JPEGs) are converted to RGBA first
+	src, isRGBA := img.(*image.RGBA)
+	ycbcr, isYCbCr := img.(*image.YCbCr)
+	if !isRGBA && !isYCbCr {
+		src = image.NewRGBA(bounds)
+		draw.Draw(src, bounds, img, bounds.Min, draw.Src)
+	}
+
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 { // rotated a quarter turn
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = width-1-x, y
+			case 3: // upside down
+				dx, dy = width-1-x, height-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, height-1-y
+			case 5: // mirrored, rotated
+				dx, dy = y, x
+			case 6: // needs a quarter turn clockwise
+				dx, dy = height-1-y, x
+			case 7: // mirrored, rotated the other way
+				dx, dy = height-1-y, width-1-x
+			case 8: // needs a quarter turn counter-clockwise
+				dx, dy = y, width-1-x
+			}
+			to := dst.PixOffset(dx, dy)
+			if isYCbCr {
+				c := ycbcr.YCbCrAt(bounds.Min.X+x, bounds.Min.Y+y)
+				r, g, b := color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+				dst.Pix[to], dst.Pix[to+1], dst.Pix[to+2], dst.Pix[to+3] = r, g, b, 0xFF
+				continue
+			}
+			from := src.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			copy(dst.Pix[to:to+4], src.Pix[from:from+4])
+		}
+	}
+	return dst
+}
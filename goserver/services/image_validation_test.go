@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// jpegWithOrientation encodes a width x height JPEG, red in the top-left pixel area, and
+// inserts an EXIF block with the given orientation and a GPS marker
+func jpegWithOrientation(t *testing.T, width, height, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Big-endian TIFF with one IFD0 entry: Orientation (SHORT)
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPSLatitude -6.2088"...)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	encoded := buf.Bytes()
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, encoded[2:]...)
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSanitizeImageAppliesOrientationAndStripsExif(t *testing.T) {
+	photo := jpegWithOrientation(t, 64, 32, 6)
+	if jpegOrientation(photo) != 6 {
+		t.Fatalf("orientation = %d, want 6", jpegOrientation(photo))
+	}
+
+	img, encoded, ext, err := sanitizeImage(photo, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext != ".jpg" {
+		t.Fatalf("ext = %q", ext)
+	}
+	if bytes.Contains(encoded, []byte("Exif")) || bytes.Contains(encoded, []byte("GPSLatitude")) {
+		t.Fatal("re-encoded photo still carries EXIF data")
+	}
+
+	// A quarter turn clockwise: 64x32 becomes 32x64 and the red corner moves to the top right
+	if size := img.Bounds().Size(); size != image.Pt(32, 64) {
+		t.Fatalf("size = %v, want 32x64", size)
+	}
+	if r, g, _, _ := img.At(28, 3).RGBA(); r>>8 < 200 || g>>8 > 80 {
+		t.Fatalf("top-right pixel is not red: r=%d g=%d", r>>8, g>>8)
+	}
+	if jpegOrientation(encoded) != 1 {
+		t.Fatal("re-encoded photo still has an orientation")
+	}
+}
+
+func TestSanitizeImageRejections(t *testing.T) {
+	photo := pngImage(t, 4, 4)
+	tests := []struct {
+		name    string
+		data    []byte
+		claimed string
+		code    string
+	}{
+		{"empty", nil, "", ImageCodeEmpty},
+		{"html renamed to png", []byte("<html><script>alert(1)</script></html>"), "image/png", ImageCodeUnsupportedType},
+		{"declared type not an image", photo, "text/html", ImageCodeUnsupportedType},
+		{"declared jpeg but png", photo, "image/jpeg", ImageCodeTypeMismatch},
+		{"truncated", photo[:20], "", ImageCodeUndecodable},
+		{"too many pixels", pngImage(t, maxImageDimension+1, 1), "", ImageCodeDimensions},
+		{"too large", append(append([]byte{}, photo...), make([]byte, maxImageSize)...), "", ImageCodeTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := sanitizeImage(tt.data, tt.claimed)
+			var imageErr *ImageError
+			if !errors.As(err, &imageErr) || imageErr.Code != tt.code {
+				t.Fatalf("error = %v, want code %s", err, tt.code)
+			}
+			if !errors.Is(err, ErrInvalidImage) {
+				t.Fatal("rejection does not match ErrInvalidImage")
+			}
+		})
+	}
+}
+
+func TestSanitizeImageDropsAppendedData(t *testing.T) {
+	// A PNG with an HTML payload appended still decodes; only the image survives re-encoding
+	polyglot := append(pngImage(t, 4, 4), "<html><script>alert(1)</script></html>"...)
+	_, encoded, ext, err := sanitizeImage(polyglot, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext != ".png" || bytes.Contains(encoded, []byte("<script>")) {
+		t.Fatalf("re-encoded polyglot (%s) kept the appended payload", ext)
+	}
+}
+
+func TestApplyOrientationColorModels(t *testing.T) {
+	// The same 3x2 gray gradient as RGBA, YCbCr (how JPEGs decode) and Gray, off the origin
+	bounds := image.Rect(10, 20, 13, 22)
+	rgba := image.NewRGBA(bounds)
+	ycbcr := image.NewYCbCr(bounds, image.YCbCrSubsampleRatio444)
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := uint8(40*(x-bounds.Min.X) + 120*(y-bounds.Min.Y))
+			rgba.Set(x, y, color.RGBA{v, v, v, 255})
+			ycbcr.Y[ycbcr.YOffset(x, y)] = v
+			ycbcr.Cb[ycbcr.COffset(x, y)], ycbcr.Cr[ycbcr.COffset(x, y)] = 128, 128
+			gray.SetGray(x, y, color.Gray{v})
+		}
+	}
+
+	want := applyOrientation(rgba, 6).(*image.RGBA)
+	if size := want.Bounds().Size(); size != image.Pt(2, 3) {
+		t.Fatalf("size = %v, want 2x3", size)
+	}
+	// A quarter turn clockwise moves the top-left pixel to the top right
+	if got := want.RGBAAt(1, 0); got.R != 0 {
+		t.Fatalf("top-right pixel = %v, want the original top-left", got)
+	}
+	for name, img := range map[string]image.Image{"ycbcr": ycbcr, "gray": gray} {
+		if got := applyOrientation(img, 6).(*image.RGBA); !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s: pixels = %v, want %v", name, got.Pix, want.Pix)
+		}
+	}
+}
+
+func TestAcquireImageSlot(t *testing.T) {
+	var releases []func()
+	for range cap(imageSlots) {
+		release, err := acquireImageSlot(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := acquireImageSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire with every slot taken = %v, want a deadline error", err)
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	release, err := acquireImageSlot(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"goserver/metrics"
+	"goserver/models"
+	"goserver/repository"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// kioskTokenPurpose is signed into kiosk tokens
+	kioskTokenPurpose = "kiosk"
+	// kioskTokenParam is the form URL query parameter carrying the kiosk token
+	kioskTokenParam = "kiosk"
+	// defaultKioskQRSize is the width in pixels of a kiosk QR code unless one is requested
+	defaultKioskQRSize = 256
+)
+
+var (
+	// ErrKioskLocationNotFound is returned when a location code does not exist
+	ErrKioskLocationNotFound = errors.New("kiosk location not found")
+	// ErrKioskLocationInactive is returned when minting a QR code for a deactivated location
+	ErrKioskLocationInactive = errors.New("kiosk location is inactive")
+	// ErrInvalidKioskToken is returned for a kiosk token that is malformed, badly signed or
+	// names a location that is no longer active
+	ErrInvalidKioskToken = errors.New("invalid kiosk token")
+	// ErrKioskTokenExpired is returned for a kiosk token past its expiry
+	ErrKioskTokenExpired = errors.New("kiosk QR code has expired")
+	// ErrKioskTokenRequired is returned for a submission without a kiosk token when one is required
+	ErrKioskTokenRequired = errors.New("kiosk token is required")
+)
+
+// unattributedLocationName labels the report line for submissions without a kiosk QR code
+const unattributedLocationName = "Tanpa QR lokasi"
+
+// KioskConfig configures the QR codes posted at kiosk locations (KIOSK_*)
+type KioskConfig struct {
+	// Secret signs kiosk tokens
+	Secret string
+	// TTL is how long a kiosk QR code stays valid unless another period is requested
+	TTL time.Duration
+	// FormURL is the escort form; the token is added as the "kiosk" query parameter
+	FormURL string
+	// Required refuses submissions that do not carry a kiosk token
+	Required bool
+}
+
+// KioskService manages kiosk locations, mints their QR codes and attributes submissions to
+// them. A QR code opens the form with a signed, expiring token naming the location; the form
+// sends it back as kiosk_token.
+type KioskService struct {
+	locations repository.KioskLocationRepository
+	escorts   repository.EscortRepository
+	config    KioskConfig
+}
+
+func NewKioskService(store *repository.Store, config KioskConfig) *KioskService {
+	return &KioskService{locations: store.Locations, escorts: store.Escorts, config: config}
+}
+
+// ListLocations retrieves kiosk locations, optionally including deactivated ones
+func (s *KioskService) ListLocations(ctx context.Context, includeInactive bool) ([]models.KioskLocation, error) {
+	return s.locations.List(ctx, includeInactive)
+}
+
+// CreateLocation registers a location, reactivating it if the code already exists
+func (s *KioskService) CreateLocation(ctx context.Context, req models.CreateKioskLocationRequest) (*models.KioskLocation, error) {
+	return s.locations.Upsert(ctx, req.Code, req.Name)
+}
+
+// UpdateLocation changes the name or active flag of a location
+func (s *KioskService) UpdateLocation(ctx context.Context, code string, req models.UpdateKioskLocationRequest) (*models.KioskLocation, error) {
+	location, err := s.locations.Update(ctx, code, req.Name, req.Active)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrKioskLocationNotFound
+	}
+	return location, err
+}
+
+// DeactivateLocation revokes a location's QR codes while keeping it for past submissions
+func (s *KioskService) DeactivateLocation(ctx context.Context, code string) error {
+	active := false
+	_, err := s.locations.Update(ctx, code, nil, &active)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrKioskLocationNotFound
+	}
+	return err
+}
+
+// MintQRCode creates a QR code opening the form with a token for the location
+func (s *KioskService) MintQRCode(ctx context.Context, code string, req models.KioskQRCodeRequest) (*models.KioskQRCode, error) {
+	location, err := s.locations.Get(ctx, code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrKioskLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !location.Active {
+		return nil, ErrKioskLocationInactive
+	}
+
+	formURL, err := url.Parse(s.config.FormURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kiosk form URL: %w", err)
+	}
+
+	ttl := s.config.TTL
+	if req.Days > 0 {
+		ttl = time.Duration(req.Days) * 24 * time.Hour
+	}
+	size := req.Size
+	if size == 0 {
+		size = defaultKioskQRSize
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	token := signToken(s.config.Secret, kioskTokenPurpose, location.Code, expiresAt)
+	query := formURL.Query()
+	query.Set(kioskTokenParam, token)
+	formURL.RawQuery = query.Encode()
+
+	png, err := qrcode.Encode(formURL.String(), qrcode.Medium, size)
+	if err != nil {
+		return nil, err
+	}
+	metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeKiosk).Inc()
+
+	return &models.KioskQRCode{
+		Location:  location.Code,
+		Token:     token,
+		URL:       formURL.String(),
+		QRCode:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		PNG:       png,
+		Size:      size,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// ResolveToken returns the code of the location a submission's kiosk token was minted for.
+// Without a token it returns nil, unless tokens are required.
+func (s *KioskService) ResolveToken(ctx context.Context, token string) (*string, error) {
+	if token == "" {
+		if s.config.Required {
+			return nil, ErrKioskTokenRequired
+		}
+		return nil, nil
+	}
+
+	code, expiresAt, ok := verifyToken(s.config.Secret, kioskTokenPurpose, token)
+	if !ok {
+		return nil, ErrInvalidKioskToken
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrKioskTokenExpired
+	}
+
+	// Deactivating a location revokes every QR code minted for it
+	location, err := s.locations.Get(ctx, code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidKioskToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !location.Active {
+		return nil, ErrInvalidKioskToken
+	}
+	return &location.Code, nil
+}
+
+// TokenLocation returns the location code of a correctly signed, unexpired kiosk token
+// without checking that the location is still active
+func (s *KioskService) TokenLocation(token string) (string, bool) {
+	code, expiresAt, ok := verifyToken(s.config.Secret, kioskTokenPurpose, token)
+	if !ok || time.Now().After(expiresAt) {
+		return "", false
+	}
+	return code, true
+}
+
+// Report counts submissions per location within the filters (ErrInvalidExportFilter for bad
+// dates). Every active location is listed, with submissions without a location last.
+func (s *KioskService) Report(ctx context.Context, filters models.ExportFilters) ([]models.LocationReportRow, error) {
+	query, err := exportQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.escorts.CountByLocation(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := s.locations.List(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.LocationCount, len(counts))
+	var unattributed models.LocationCount
+	for _, count := range counts {
+		if count.LocationCode == nil {
+			unattributed = count
+			continue
+		}
+		byCode[*count.LocationCode] = count
+	}
+
+	rows := []models.LocationReportRow{}
+	for _, location := range locations {
+		count, found := byCode[location.Code]
+		// Deactivated locations only show up for the period they were used in
+		if !found && !location.Active {
+			continue
+		}
+		count.LocationCode = &location.Code
+		rows = append(rows, models.LocationReportRow{LocationCount: count, LocationName: location.Name, Active: location.Active})
+	}
+	rows = append(rows, models.LocationReportRow{LocationCount: unattributed, LocationName: unattributedLocationName})
+	return rows, nil
+}
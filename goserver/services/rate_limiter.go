@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"goserver/repository"
+)
+
+// rateLimitCleanupInterval is how often refilled buckets are deleted
+const rateLimitCleanupInterval = 10 * time.Minute
+
+// RatePolicy allows Limit requests per Window and client. Clients can use the whole limit
+// at once; it then comes back gradually (a token bucket of Limit tokens refilled at
+// Limit/Window).
+type RatePolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRatePolicy reads a policy written as "<requests>/<window>", where the window is s, m
+// or h, optionally with a count such as "100/15m"
+func ParseRatePolicy(name, spec string) (RatePolicy, error) {
+	limit, window, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return RatePolicy{}, fmt.Errorf("%q is not <requests>/<window>", spec)
+	}
+	requests, err := strconv.Atoi(limit)
+	if err != nil || requests <= 0 {
+		return RatePolicy{}, fmt.Errorf("%q: requests must be a positive number", spec)
+	}
+	if window != "" && window[0] >= 'a' {
+		window = "1" + window
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return RatePolicy{}, fmt.Errorf("%q: window must be like s, m, h or 15m", spec)
+	}
+	return RatePolicy{Name: name, Limit: requests, Window: duration}, nil
+}
+
+// rate is the refill rate in tokens per second
+func (p RatePolicy) rate() float64 {
+	return float64(p.Limit) / p.Window.Seconds()
+}
+
+// String formats the policy for the RateLimit-Policy header, e.g. "10;w=60"
+func (p RatePolicy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(math.Ceil(p.Window.Seconds())))
+}
+
+// RatePolicies are the policies applied to the API routes (RATE_LIMIT_*)
+type RatePolicies struct {
+	Submit RatePolicy // escort form submissions
+	QRCode RatePolicy // QR code generation
+	Public RatePolicy // other public endpoints
+	API    RatePolicy // authenticated endpoints, per token
+}
+
+// RateDecision is the outcome of a rate limited request
+type RateDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the client has its whole limit again
+	Reset time.Duration
+	// RetryAfter is how long a refused client has to wait for the next request
+	RetryAfter time.Duration
+}
+
+// RateLimiter applies rate policies to clients using token buckets kept in buckets
+type RateLimiter struct {
+	buckets repository.RateLimitRepository
+	kiosks  *KioskService
+	now     func() time.Time
+}
+
+// NewRateLimiter creates the limiter. With kiosks, submissions made from a kiosk QR code are
+// limited per kiosk location and client IP.
+func NewRateLimiter(buckets repository.RateLimitRepository, kiosks *KioskService) *RateLimiter {
+	return &RateLimiter{buckets: buckets, kiosks: kiosks, now: time.Now}
+}
+
+// KioskSubject returns the rate limit subject for a kiosk token sent from clientIP, if the
+// token is valid. The token is printed on a public poster, so the client IP stays part of
+// the subject: one visitor cannot use up the allowance of everyone at that entrance.
+func (l *RateLimiter) KioskSubject(token, clientIP string) (string, bool) {
+	if l.kiosks == nil || token == "" {
+		return "", false
+	}
+	code, ok := l.kiosks.TokenLocation(token)
+	if !ok {
+		return "", false
+	}
+	return "kiosk:" + code + ":ip:" + clientIP, true
+}
+
+// Allow takes one request from subject's allowance under policy
+func (l *RateLimiter) Allow(ctx context.Context, policy RatePolicy, subject string) (RateDecision, error) {
+	now := l.now()
+	burst, rate := float64(policy.Limit), policy.rate()
+	allowed, tokens, err := l.buckets.Take(ctx, policy.Name+":"+subject, burst, rate, now, now.Add(policy.Window))
+	if err != nil {
+		return RateDecision{}, err
+	}
+
+	decision := RateDecision{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Max(tokens, 0)),
+		Reset:     secondsUntil(burst-tokens, rate),
+	}
+	if !allowed {
+		decision.RetryAfter = secondsUntil(1-tokens, rate)
+	}
+	return decision, nil
+}
+
+// secondsUntil returns how long it takes to refill tokens at rate, in whole seconds
+func secondsUntil(tokens, rate float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens/rate)) * time.Second
+}
+
+// Run deletes refilled buckets every ten minutes until ctx is cancelled
+func (l *RateLimiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := l.buckets.DeleteExpired(ctx, l.now()); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to delete expired rate limits", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goserver/repository"
+)
+
+func TestParseRatePolicy(t *testing.T) {
+	tests := map[string]struct {
+		limit  int
+		window time.Duration
+	}{
+		"10/m":     {10, time.Minute},
+		"100/15m":  {100, 15 * time.Minute},
+		" 5/30s ":  {5, 30 * time.Second},
+		"1000/h":   {1000, time.Hour},
+		"600/1m0s": {600, time.Minute},
+	}
+	for spec, want := range tests {
+		policy, err := ParseRatePolicy("submit", spec)
+		if err != nil || policy.Limit != want.limit || policy.Window != want.window {
+			t.Errorf("ParseRatePolicy(%q) = %+v, %v; want %d per %v", spec, policy, err, want.limit, want.window)
+		}
+	}
+
+	for _, spec := range []string{"", "10", "0/m", "-1/m", "ten/m", "10/", "10/0m", "10/week"} {
+		if _, err := ParseRatePolicy("submit", spec); err == nil {
+			t.Errorf("ParseRatePolicy(%q) accepted", spec)
+		}
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(repository.NewMemoryRateLimitRepository(), nil)
+	limiter.now = func() time.Time { return now }
+	policy := RatePolicy{Name: "submit", Limit: 3, Window: time.Minute}
+
+	// The whole limit can be used at once
+	for i := range 3 {
+		decision, err := limiter.Allow(ctx, policy, "ip:198.51.100.1")
+		if err != nil || !decision.Allowed || decision.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, %v; want allowed with %d remaining", i+1, decision, err, 2-i)
+		}
+	}
+
+	decision, _ := limiter.Allow(ctx, policy, "ip:198.51.100.1")
+	if decision.Allowed || decision.RetryAfter != 20*time.Second || decision.Reset != time.Minute {
+		t.Fatalf("over the limit: %+v; want refused, retry after 20s, reset in 1m", decision)
+	}
+	if other, _ := limiter.Allow(ctx, policy, "ip:198.51.100.2"); !other.Allowed {
+		t.Fatal("another client was refused")
+	}
+
+	// One request comes back every 20 seconds
+	now = now.Add(20 * time.Second)
+	if decision, _ := limiter.Allow(ctx, policy, "ip:198.51.100.1"); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("after 20s: %+v; want allowed with 0 remaining", decision)
+	}
+	now = now.Add(time.Hour)
+	if decision, _ := limiter.Allow(ctx, policy, "ip:198.51.100.1"); decision.Remaining != 2 {
+		t.Fatalf("after an hour: %d remaining, want the limit minus one", decision.Remaining)
+	}
+}
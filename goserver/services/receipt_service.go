@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"goserver/metrics"
+	"goserver/models"
+	"goserver/repository"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// receiptQRSize is the width in pixels of the receipt QR code
+	receiptQRSize = 256
+	// receiptTokenPurpose is signed into receipt tokens
+	receiptTokenPurpose = "receipt"
+)
+
+var (
+	// ErrInvalidReceipt is returned for a receipt token that is malformed or badly signed
+	ErrInvalidReceipt = errors.New("invalid receipt token")
+	// ErrReceiptExpired is returned for a correctly signed receipt token past its expiry
+	ErrReceiptExpired = errors.New("receipt has expired")
+)
+
+// ReceiptConfig configures the receipt links given to submitters (RECEIPT_*)
+type ReceiptConfig struct {
+	// Secret signs receipt tokens
+	Secret string
+	// TTL is how long a receipt link stays valid
+	TTL time.Duration
+	// URL is the receipt page; the token is appended to it as a path segment
+	URL string
+}
+
+// ReceiptService issues and resolves receipt links. A token carries the submission ID and
+// expiry signed with HMAC-SHA256 (see signToken), so nothing has to be stored to check it.
+type ReceiptService struct {
+	escorts repository.EscortRepository
+	config  ReceiptConfig
+}
+
+func NewReceiptService(escorts repository.EscortRepository, config ReceiptConfig) *ReceiptService {
+	return &ReceiptService{escorts: escorts, config: config}
+}
+
+// Issue creates the receipt link and QR code for a newly submitted escort
+func (s *ReceiptService) Issue(escort *models.Escort) (*models.ReceiptLink, error) {
+	if escort.SubmissionID == nil {
+		return nil, errors.New("escort has no submission ID")
+	}
+
+	// Staff scan the QR at the desk, so it holds the ticket code rather than the link
+	png, err := qrcode.Encode(*escort.SubmissionID, qrcode.Medium, receiptQRSize)
+	if err != nil {
+		return nil, err
+	}
+	metrics.QRCodesGenerated.WithLabelValues(metrics.QRCodeReceipt).Inc()
+
+	expiresAt := time.Now().Add(s.config.TTL).Truncate(time.Second)
+	token := signToken(s.config.Secret, receiptTokenPurpose, *escort.SubmissionID, expiresAt)
+	return &models.ReceiptLink{
+		Token:     token,
+		URL:       strings.TrimRight(s.config.URL, "/") + "/" + token,
+		QRCode:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Lookup returns the masked view of the escort a receipt token was issued for
+func (s *ReceiptService) Lookup(ctx context.Context, token string) (*models.EscortReceipt, error) {
+	submissionID, expiresAt, ok := verifyToken(s.config.Secret, receiptTokenPurpose, token)
+	if !ok {
+		return nil, ErrInvalidReceipt
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrReceiptExpired
+	}
+
+	escort, err := s.escorts.GetBySubmissionID(ctx, submissionID)
+	if err != nil {
+		return nil, escortError(err)
+	}
+
+	return &models.EscortReceipt{
+		SubmissionID:      submissionID,
+		Status:            escort.Status,
+		StatusLabel:       models.StatusDisplayName(escort.Status),
+		RejectionReason:   escort.RejectionReason,
+		KategoriPengantar: escort.KategoriPengantar,
+		NamaPengantar:     maskName(escort.NamaPengantar),
+		NomorHP:           maskPhone(escort.NomorHP),
+		PlatNomor:         maskPlate(escort.PlatNomor),
+		NamaPasien:        maskName(escort.NamaPasien),
+		SubmittedAt:       escort.CreatedAt,
+		UpdatedAt:         escort.UpdatedAt,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+// maskName keeps the first letter of each word: "Budi Santoso" becomes "B*** S******"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		runes := []rune(word)
+		words[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+// maskPhone keeps the first four and last three digits: "081234567890" becomes "0812*****890"
+func maskPhone(phone string) string {
+	runes := []rune(strings.TrimSpace(phone))
+	if len(runes) <= 7 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", len(runes)-7) + string(runes[len(runes)-3:])
+}
+
+// maskPlate keeps the region and suffix letters: "B 1234 CD" becomes "B **** CD"
+func maskPlate(plate string) string {
+	parts := strings.Fields(plate)
+	if len(parts) < 3 {
+		// No recognisable layout; keep only the first character
+		runes := []rune(strings.Join(parts, ""))
+		if len(runes) == 0 {
+			return ""
+		}
+		return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+	}
+	for i := 1; i < len(parts)-1; i++ {
+		parts[i] = strings.Repeat("*", len([]rune(parts[i])))
+	}
+	return strings.Join(parts, " ")
+}
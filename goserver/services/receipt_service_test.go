@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestReceiptMasking(t *testing.T) {
+	tests := []struct {
+		mask     func(string) string
+		in, want string
+	}{
+		{maskName, "Budi Santoso", "B*** S******"},
+		{maskName, "  Ñoman  ", "Ñ****"},
+		{maskPhone, "081234567890", "0812*****890"},
+		{maskPhone, "+62 812", "*******"},
+		{maskPlate, "B 1234 CD", "B **** CD"},
+		{maskPlate, "B1234CD", "B******"},
+		{maskPlate, "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.mask(tt.in); got != tt.want {
+			t.Errorf("mask(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
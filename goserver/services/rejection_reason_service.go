@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+var (
+	// ErrRejectionReasonNotFound is returned when a rejection reason code does not exist
+	ErrRejectionReasonNotFound = errors.New("rejection reason not found")
+	// ErrRejectionReasonExists is returned when creating a reason whose code is taken
+	ErrRejectionReasonExists = errors.New("rejection reason code already exists")
+)
+
+type RejectionReasonService struct {
+	reasons repository.RejectionReasonRepository
+}
+
+func NewRejectionReasonService(reasons repository.RejectionReasonRepository) *RejectionReasonService {
+	return &RejectionReasonService{reasons: reasons}
+}
+
+// ListReasons retrieves rejection reasons, optionally including deactivated ones
+func (s *RejectionReasonService) ListReasons(ctx context.Context, includeInactive bool) ([]models.RejectionReason, error) {
+	return s.reasons.List(ctx, includeInactive)
+}
+
+// CreateReason adds a reason to the list. Existing codes, active or not, are refused: escorts
+// were rejected with them, so relabelling or reactivating one goes through UpdateReason.
+func (s *RejectionReasonService) CreateReason(ctx context.Context, req models.CreateRejectionReasonRequest) (*models.RejectionReason, error) {
+	reason, err := s.reasons.Create(ctx, req.Code, req.Label)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrRejectionReasonExists
+	}
+	return reason, err
+}
+
+// UpdateReason changes the label or active flag of a reason
+func (s *RejectionReasonService) UpdateReason(ctx context.Context, code string, req models.UpdateRejectionReasonRequest) (*models.RejectionReason, error) {
+	reason, err := s.reasons.Update(ctx, code, req.Label, req.Active)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrRejectionReasonNotFound
+	}
+	return reason, err
+}
+
+// DeactivateReason hides a reason from new rejections while keeping it for existing history
+func (s *RejectionReasonService) DeactivateReason(ctx context.Context, code string) error {
+	active := false
+	_, err := s.reasons.Update(ctx, code, nil, &active)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrRejectionReasonNotFound
+	}
+	return err
+}
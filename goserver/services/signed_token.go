@@ -0,0 +1,57 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signToken builds a stateless token "<base64url(payload.expiry)>.<base64url(signature)>".
+// The signature covers the purpose too, so a token minted for one use (a receipt, a kiosk)
+// is rejected by every other use even when they share a secret.
+func signToken(secret, purpose, payload string, expiresAt time.Time) string {
+	signed := payload + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(signed)) + "." +
+		base64.RawURLEncoding.EncodeToString(tokenMAC(secret, purpose, signed))
+}
+
+// verifyToken checks a token made by signToken for the same purpose and returns its payload
+// and expiry. It does not check the expiry.
+func verifyToken(secret, purpose, token string) (payload string, expiresAt time.Time, ok bool) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return "", time.Time{}, false
+	}
+	rawPayload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	signed := string(rawPayload)
+	if !hmac.Equal(signature, tokenMAC(secret, purpose, signed)) {
+		return "", time.Time{}, false
+	}
+
+	dot := strings.LastIndexByte(signed, '.')
+	if dot <= 0 {
+		return "", time.Time{}, false
+	}
+	expiry, err := strconv.ParseInt(signed[dot+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return signed[:dot], time.Unix(expiry, 0), true
+}
+
+func tokenMAC(secret, purpose, signed string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":"))
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
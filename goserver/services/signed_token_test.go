@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignedToken(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	token := signToken("secret", "kiosk", "main_entrance", expiresAt)
+
+	payload, expiry, ok := verifyToken("secret", "kiosk", token)
+	if !ok || payload != "main_entrance" || !expiry.Equal(expiresAt) {
+		t.Fatalf("verifyToken = %q, %v, %v; want main_entrance, %v, true", payload, expiry, ok, expiresAt)
+	}
+
+	tests := map[string]struct{ secret, purpose, token string }{
+		"other secret":  {"other", "kiosk", token},
+		"other purpose": {"secret", "receipt", token},
+		"truncated":     {"secret", "kiosk", token[:len(token)-2]},
+		"no signature":  {"secret", "kiosk", token[:len(token)-44]},
+		"empty":         {"secret", "kiosk", ""},
+	}
+	for name, tt := range tests {
+		if _, _, ok := verifyToken(tt.secret, tt.purpose, tt.token); ok {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
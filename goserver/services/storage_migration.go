@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+)
+
+// ImageMigrationOptions controls MigrateImages
+type ImageMigrationOptions struct {
+	// DeleteSource removes the photos from the source backend once all of them are copied
+	DeleteSource bool
+	// DryRun reports what would be done without copying, rewriting or deleting anything
+	DryRun bool
+	// Logf, when set, is called with a line for every photo handled
+	Logf func(format string, args ...any)
+}
+
+// ImageMigrationReport counts what MigrateImages did
+type ImageMigrationReport struct {
+	Copied    int // photos copied to the destination
+	Skipped   int // photos already in the destination with the same size
+	Rewritten int // foto_pengantar values rewritten to the storage key
+	Deleted   int // photos removed from the source
+	// Missing lists the photos that are in neither backend
+	Missing []string
+}
+
+// MigrateImages copies every escort photo from one storage backend to another and rewrites
+// foto_pengantar values that are not plain storage keys (such as Laravel's "uploads/x.jpg"),
+// after which Laravel serves those photos through this API instead of its public disk.
+// Photo variants are copied along when the source has them.
+// Photos already in the destination are not copied again, so an interrupted run can simply
+// be repeated. Photos that no escort refers to are left alone.
+func MigrateImages(ctx context.Context, escorts repository.EscortRepository, from, to filestore.Storage, opts ImageMigrationOptions) (*ImageMigrationReport, error) {
+	if from.String() == to.String() {
+		return nil, fmt.Errorf("source and destination are the same storage (%s)", from)
+	}
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+
+	// Collect first; rewriting rows while the listing is still open could block on it
+	var withPhoto []models.Escort
+	err := escorts.Each(ctx, repository.EscortQuery{}, func(escort models.Escort) error {
+		if escort.FotoPengantar != nil && *escort.FotoPengantar != "" {
+			withPhoto = append(withPhoto, escort)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImageMigrationReport{}
+	inSource := map[string]bool{}
+	for _, escort := range withPhoto {
+		foto := *escort.FotoPengantar
+		key := imageKey(foto)
+
+		if _, seen := inSource[key]; !seen {
+			found, err := migrateImage(ctx, from, to, key, opts.DryRun, logf, report)
+			if err != nil {
+				return report, fmt.Errorf("escort %d: %w", escort.ID, err)
+			}
+			inSource[key] = found
+			if found {
+				if err := migrateImageVariants(ctx, from, to, key, opts.DryRun, logf); err != nil {
+					return report, fmt.Errorf("escort %d: %w", escort.ID, err)
+				}
+			}
+		}
+
+		if foto != key {
+			logf("rewrite escort %d foto_pengantar %q -> %q", escort.ID, foto, key)
+			if !opts.DryRun {
+				if err := escorts.Update(ctx, escort.ID, repository.EscortUpdate{FotoPengantar: &key}); err != nil {
+					return report, fmt.Errorf("escort %d: %w", escort.ID, err)
+				}
+			}
+			report.Rewritten++
+		}
+	}
+
+	if !opts.DeleteSource {
+		return report, nil
+	}
+	for key, found := range inSource {
+		if !found {
+			continue
+		}
+		logf("delete %s from %s", key, from)
+		if !opts.DryRun {
+			for _, stored := range append([]string{key}, imageVariantKeys(key)...) {
+				if err := from.Delete(ctx, stored); err != nil {
+					return report, err
+				}
+			}
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// migrateImage copies one photo unless the destination already has it. It reports whether
+// the photo exists in the source.
+func migrateImage(ctx context.Context, from, to filestore.Storage, key string, dryRun bool, logf func(string, ...any), report *ImageMigrationReport) (bool, error) {
+	source, err := from.Stat(ctx, key)
+	if err != nil && !errors.Is(err, filestore.ErrNotFound) {
+		return false, err
+	}
+	destination, err := to.Stat(ctx, key)
+	if err != nil && !errors.Is(err, filestore.ErrNotFound) {
+		return false, err
+	}
+
+	switch {
+	case source == nil && destination == nil:
+		logf("missing %s", key)
+		report.Missing = append(report.Missing, key)
+		return false, nil
+	case destination != nil && (source == nil || source.Size == destination.Size):
+		logf("skip %s (already in %s)", key, to)
+		report.Skipped++
+		return source != nil, nil
+	}
+
+	logf("copy %s (%d bytes)", key, source.Size)
+	if !dryRun {
+		if err := filestore.Copy(ctx, from, to, key); err != nil {
+			return true, err
+		}
+	}
+	report.Copied++
+	return true, nil
+}
+
+// migrateImageVariants copies the variants of a photo that the source has and the
+// destination lacks; variants that do not exist are recreated on demand later
+func migrateImageVariants(ctx context.Context, from, to filestore.Storage, key string, dryRun bool, logf func(string, ...any)) error {
+	for _, stored := range imageVariantKeys(key) {
+		source, err := from.Stat(ctx, stored)
+		if errors.Is(err, filestore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		destination, err := to.Stat(ctx, stored)
+		if err != nil && !errors.Is(err, filestore.ErrNotFound) {
+			return err
+		}
+		if destination != nil && destination.Size == source.Size {
+			continue
+		}
+
+		logf("copy %s (%d bytes)", stored, source.Size)
+		if !dryRun {
+			if err := filestore.Copy(ctx, from, to, stored); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+)
+
+func TestMigrateImages(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	from := filestore.NewLocal(t.TempDir())
+	to := filestore.NewLocal(t.TempDir())
+
+	photos := []struct{ foto, key string }{
+		{"escort_1.jpg", "escort_1.jpg"},         // stored by this server
+		{"uploads/escort_2.png", "escort_2.png"}, // stored by the Laravel form
+		{"escort_3.jpg", ""},                     // file is gone
+	}
+	for _, photo := range photos {
+		foto, key := photo.foto, photo.key
+		if err := store.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending, FotoPengantar: &foto}); err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			if err := from.Put(ctx, key, strings.NewReader("photo "+key), -1, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := store.Escorts.Create(ctx, &models.Escort{Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.Put(ctx, "escort_1.thumb.jpg", strings.NewReader("thumb"), -1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := MigrateImages(ctx, store.Escorts, from, to, ImageMigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 2 || report.Rewritten != 1 || len(report.Missing) != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if _, err := to.Stat(ctx, "escort_1.jpg"); !errors.Is(err, filestore.ErrNotFound) {
+		t.Fatalf("dry run copied a photo (Stat error %v)", err)
+	}
+
+	report, err = MigrateImages(ctx, store.Escorts, from, to, ImageMigrationOptions{DeleteSource: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 2 || report.Skipped != 0 || report.Rewritten != 1 || report.Deleted != 2 ||
+		len(report.Missing) != 1 || report.Missing[0] != "escort_3.jpg" {
+		t.Fatalf("report = %+v", report)
+	}
+
+	for _, key := range []string{"escort_1.jpg", "escort_1.thumb.jpg", "escort_2.png"} {
+		if _, err := to.Stat(ctx, key); err != nil {
+			t.Fatalf("%s not in destination: %v", key, err)
+		}
+		if _, err := from.Stat(ctx, key); !errors.Is(err, filestore.ErrNotFound) {
+			t.Fatalf("%s still in source (Stat error %v)", key, err)
+		}
+	}
+
+	escort, err := store.Escorts.GetByID(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escort.FotoPengantar == nil || *escort.FotoPengantar != "escort_2.png" {
+		t.Fatalf("foto_pengantar = %v, want escort_2.png", escort.FotoPengantar)
+	}
+
+	// Running again finds everything in place
+	report, err = MigrateImages(ctx, store.Escorts, from, to, ImageMigrationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 0 || report.Skipped != 2 || report.Rewritten != 0 {
+		t.Fatalf("second run report = %+v", report)
+	}
+
+	if _, err := MigrateImages(ctx, store.Escorts, from, from, ImageMigrationOptions{}); err == nil {
+		t.Fatal("migrating a backend onto itself succeeded")
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"goserver/filestore"
+	"goserver/metrics"
+	"goserver/models"
+	"goserver/repository"
+)
+
+// Orphaned photos are not deleted right away: a scan moves them under quarantinePrefix and a
+// later scan deletes them once they have been there for the grace period. A quarantined
+// photo that an escort refers to again (e.g. after restoring the database from a backup) is
+// moved back instead.
+const (
+	quarantinePrefix = "quarantine/"
+	// orphanMinAge leaves fresh files alone, since an upload is stored before its escort row commits
+	orphanMinAge = time.Hour
+)
+
+// ErrNoStorageScan is returned when no storage scan report has been saved yet
+var ErrNoStorageScan = errors.New("no storage scan has run yet")
+
+// StorageScanner checks that escort rows and stored photo files agree
+type StorageScanner struct {
+	store  *repository.Store
+	files  filestore.Storage
+	grace  time.Duration
+	minAge time.Duration
+}
+
+// NewStorageScanner creates a scanner that deletes quarantined orphans after grace
+func NewStorageScanner(store *repository.Store, files filestore.Storage, grace time.Duration) *StorageScanner {
+	return &StorageScanner{store: store, files: files, grace: grace, minAge: orphanMinAge}
+}
+
+// Scan compares the photos escorts refer to with the stored files. Files that no escort
+// refers to are quarantined, quarantined files past the grace period are deleted and
+// referenced photos found in staging or quarantine are restored; escorts whose photo is gone
+// are reported. With dryRun nothing is moved or deleted. The report is saved either way.
+func (s *StorageScanner) Scan(ctx context.Context, dryRun bool) (*models.StorageScanReport, error) {
+	report := &models.StorageScanReport{
+		Storage:     s.files.String(),
+		DryRun:      dryRun,
+		StartedAt:   time.Now(),
+		Orphans:     []models.StoredFile{},
+		Missing:     []models.MissingPhoto{},
+		Restored:    []string{},
+		Quarantined: []models.StoredFile{},
+		Purged:      []string{},
+	}
+
+	// Photo keys and the escorts referring to them; a photo keeps its variants
+	photos := map[string][]models.MissingPhoto{}
+	err := s.store.Escorts.Each(ctx, repository.EscortQuery{}, func(escort models.Escort) error {
+		if escort.FotoPengantar != nil && *escort.FotoPengantar != "" {
+			key := imageKey(*escort.FotoPengantar)
+			photos[key] = append(photos[key], models.MissingPhoto{EscortID: escort.ID, FotoPengantar: *escort.FotoPengantar})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.PhotosReferenced = len(photos)
+
+	referenced := map[string]bool{}
+	for key := range photos {
+		referenced[key] = true
+		for _, stored := range imageVariantKeys(key) {
+			referenced[stored] = true
+		}
+	}
+
+	stored := map[string]filestore.Info{}
+	staged := map[string]filestore.Info{}      // by the key the file is promoted to
+	quarantined := map[string]filestore.Info{} // by the key the file had before quarantine
+	areaBytes := map[string]int64{metrics.AreaPhotos: 0, metrics.AreaStaging: 0, metrics.AreaQuarantine: 0}
+	areaFiles := map[string]int{}
+	err = s.files.Walk(ctx, func(info filestore.Info) error {
+		area := metrics.AreaPhotos
+		switch {
+		case strings.HasPrefix(info.Key, stagingPrefix):
+			staged[strings.TrimPrefix(info.Key, stagingPrefix)] = info
+			area = metrics.AreaStaging
+		case strings.HasPrefix(info.Key, quarantinePrefix):
+			quarantined[strings.TrimPrefix(info.Key, quarantinePrefix)] = info
+			area = metrics.AreaQuarantine
+		case strings.HasPrefix(path.Base(info.Key), "."):
+			// .gitignore and the like
+			return nil
+		default:
+			stored[info.Key] = info
+			report.FilesScanned++
+		}
+		areaBytes[area] += info.Size
+		areaFiles[area]++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.files, err)
+	}
+	for area, size := range areaBytes {
+		metrics.StorageBytes.WithLabelValues(area).Set(float64(size))
+		metrics.StorageFiles.WithLabelValues(area).Set(float64(areaFiles[area]))
+	}
+
+	now := time.Now()
+
+	// Photos whose escort change committed but which were never promoted (see imageUnit)
+	for _, key := range slices.Sorted(maps.Keys(staged)) {
+		if _, exists := stored[key]; !referenced[key] || exists {
+			continue
+		}
+		if !dryRun {
+			if err := promoteImage(ctx, s.files, key); err != nil {
+				return nil, fmt.Errorf("failed to promote %s: %w", key, err)
+			}
+		}
+		stored[key] = staged[key]
+		report.Restored = append(report.Restored, key)
+		delete(staged, key)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(quarantined)) {
+		if !referenced[key] {
+			continue
+		}
+		if _, exists := stored[key]; !exists {
+			if !dryRun {
+				if err := filestore.Move(ctx, s.files, quarantinePrefix+key, key); err != nil {
+					return nil, fmt.Errorf("failed to restore %s: %w", key, err)
+				}
+			}
+			stored[key] = quarantined[key]
+			report.Restored = append(report.Restored, key)
+			delete(quarantined, key)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(photos)) {
+		if _, exists := stored[key]; !exists {
+			report.Missing = append(report.Missing, photos[key]...)
+		}
+	}
+	slices.SortFunc(report.Missing, func(a, b models.MissingPhoto) int { return cmp.Compare(a.EscortID, b.EscortID) })
+
+	for _, key := range slices.Sorted(maps.Keys(stored)) {
+		info := stored[key]
+		if referenced[key] || now.Sub(info.ModTime) < s.minAge {
+			continue
+		}
+		if !dryRun {
+			if err := filestore.Move(ctx, s.files, key, quarantinePrefix+key); err != nil {
+				return nil, fmt.Errorf("failed to quarantine %s: %w", key, err)
+			}
+		}
+		report.Orphans = append(report.Orphans, models.StoredFile{Key: key, Size: info.Size, ModTime: info.ModTime})
+	}
+
+	// Staged photos left behind by a change that never committed
+	for _, key := range slices.Sorted(maps.Keys(staged)) {
+		info := staged[key]
+		if now.Sub(info.ModTime) < s.minAge {
+			continue
+		}
+		if !dryRun {
+			if err := filestore.Move(ctx, s.files, info.Key, quarantinePrefix+key); err != nil {
+				return nil, fmt.Errorf("failed to quarantine %s: %w", info.Key, err)
+			}
+		}
+		report.Orphans = append(report.Orphans, models.StoredFile{Key: info.Key, Size: info.Size, ModTime: info.ModTime})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(quarantined)) {
+		info := quarantined[key]
+		if now.Sub(info.ModTime) < s.grace {
+			report.Quarantined = append(report.Quarantined, models.StoredFile{Key: info.Key, Size: info.Size, ModTime: info.ModTime})
+			continue
+		}
+		if !dryRun {
+			if err := s.files.Delete(ctx, info.Key); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", info.Key, err)
+			}
+		}
+		report.Purged = append(report.Purged, info.Key)
+	}
+
+	report.FinishedAt = time.Now()
+	if err := s.store.StorageScans.Save(ctx, report); err != nil {
+		return nil, err
+	}
+	metrics.StorageScanTimestamp.Set(float64(report.FinishedAt.Unix()))
+	return report, nil
+}
+
+// LatestReport retrieves the report of the most recent scan
+func (s *StorageScanner) LatestReport(ctx context.Context) (*models.StorageScanReport, error) {
+	report, err := s.store.StorageScans.Latest(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoStorageScan
+	}
+	return report, err
+}
+
+// Run scans every interval until ctx is cancelled. The first scan waits for the interval to
+// pass since the latest saved report, so restarting the server does not trigger a scan.
+func (s *StorageScanner) Run(ctx context.Context, interval time.Duration) {
+	wait := time.Duration(0)
+	if latest, err := s.store.StorageScans.Latest(ctx); err == nil {
+		wait = max(0, interval-time.Since(latest.FinishedAt))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		report, err := s.Scan(ctx, false)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.ErrorContext(ctx, "Storage scan failed", "error", err)
+		case err == nil:
+			slog.InfoContext(ctx, "Storage scan completed",
+				"quarantined", len(report.Orphans),
+				"purged", len(report.Purged),
+				"restored", len(report.Restored),
+				"missing", len(report.Missing))
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"goserver/filestore"
+	"goserver/models"
+	"goserver/repository"
+)
+
+func TestStorageScan(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	root := t.TempDir()
+	files := filestore.NewLocal(root)
+
+	// put stores a file last modified age ago
+	put := func(key string, age time.Duration) {
+		t.Helper()
+		if err := files.Put(ctx, key, strings.NewReader("file "+key), -1, ""); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(key string) bool {
+		t.Helper()
+		_, err := files.Stat(ctx, key)
+		if err != nil && !errors.Is(err, filestore.ErrNotFound) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	var escorts []models.Escort
+	for _, foto := range []string{"kept.jpg", "uploads/gone.jpg", "restored.jpg", "unpromoted.jpg"} {
+		escort := models.Escort{Status: models.StatusPending, FotoPengantar: &foto}
+		if err := store.Escorts.Create(ctx, &escort); err != nil {
+			t.Fatal(err)
+		}
+		escorts = append(escorts, escort)
+	}
+
+	day := 24 * time.Hour
+	put("kept.jpg", day)
+	put("kept.thumb.jpg", day)
+	put("orphan.jpg", day)
+	put("orphan.thumb.jpg", day)
+	put("fresh.jpg", time.Minute)            // uploaded, its escort row not committed yet
+	put(stagingPrefix+"unpromoted.jpg", day) // committed, but the server stopped before promoting it
+	put(stagingPrefix+"abandoned.jpg", day)  // its transaction rolled back
+	put(quarantinePrefix+"restored.jpg", day)
+	put(quarantinePrefix+"expired.jpg", 8*day)
+	put(quarantinePrefix+"waiting.jpg", day)
+
+	scanner := NewStorageScanner(store, files, 7*day)
+
+	report, err := scanner.Scan(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists("orphan.jpg") || !exists(quarantinePrefix+"expired.jpg") || exists("restored.jpg") {
+		t.Fatal("dry run changed the storage")
+	}
+
+	report, err = scanner.Scan(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orphans []string
+	for _, orphan := range report.Orphans {
+		orphans = append(orphans, orphan.Key)
+	}
+	if !slices.Equal(orphans, []string{"orphan.jpg", "orphan.thumb.jpg", stagingPrefix + "abandoned.jpg"}) {
+		t.Fatalf("orphans = %v", orphans)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].EscortID != escorts[1].ID || report.Missing[0].FotoPengantar != "uploads/gone.jpg" {
+		t.Fatalf("missing = %+v", report.Missing)
+	}
+	if !slices.Equal(report.Restored, []string{"unpromoted.jpg", "restored.jpg"}) || !slices.Equal(report.Purged, []string{quarantinePrefix + "expired.jpg"}) {
+		t.Fatalf("restored = %v, purged = %v", report.Restored, report.Purged)
+	}
+	if len(report.Quarantined) != 1 || report.Quarantined[0].Key != quarantinePrefix+"waiting.jpg" {
+		t.Fatalf("quarantined = %+v", report.Quarantined)
+	}
+	if report.PhotosReferenced != 4 || report.FilesScanned != 5 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	for key, want := range map[string]bool{
+		"kept.jpg":                        true,
+		"kept.thumb.jpg":                  true,
+		"fresh.jpg":                       true,
+		"restored.jpg":                    true,
+		"orphan.jpg":                      false,
+		quarantinePrefix + "orphan.jpg":   true,
+		quarantinePrefix + "restored.jpg": false,
+		quarantinePrefix + "expired.jpg":  false,
+		quarantinePrefix + "waiting.jpg":  true,
+	} {
+		if exists(key) != want {
+			t.Errorf("%s exists = %v, want %v", key, !want, want)
+		}
+	}
+
+	latest, err := scanner.LatestReport(ctx)
+	if err != nil || latest.ID != report.ID || latest.DryRun {
+		t.Fatalf("latest = %+v, %v", latest, err)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+)
+
+// Submission IDs are ticket codes such as IGD-20261016-7K3Q: the submission date and four
+// random Crockford base32 characters. The alphabet leaves out I, L, O and U so a code read
+// aloud or copied from a screen cannot be mistaken for another one.
+const (
+	submissionIDPrefix   = "IGD-"
+	submissionIDAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	submissionCodeLength = 4
+	// submissionIDAttempts bounds the retries when a generated code is already taken
+	submissionIDAttempts = 5
+)
+
+// newSubmissionID generates a ticket code for a submission made at t
+func newSubmissionID(t time.Time) string {
+	random := make([]byte, submissionCodeLength)
+	rand.Read(random)
+
+	var b strings.Builder
+	b.WriteString(submissionIDPrefix)
+	b.WriteString(t.Format("20060102"))
+	b.WriteByte('-')
+	for _, r := range random {
+		b.WriteByte(submissionIDAlphabet[int(r)%len(submissionIDAlphabet)])
+	}
+	return b.String()
+}
+
+// normalizeSubmissionID turns a ticket code as typed at the desk into its stored form: it is
+// upper-cased and, in the random part, O is read as 0 and I and L as 1. Other submission IDs
+// (such as those written by the Laravel app) are only trimmed.
+func normalizeSubmissionID(id string) string {
+	id = strings.TrimSpace(id)
+	upper := strings.ToUpper(id)
+	if !strings.HasPrefix(upper, submissionIDPrefix) {
+		return id
+	}
+
+	dash := strings.LastIndexByte(upper, '-')
+	code := strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(upper[dash+1:])
+	return upper[:dash+1] + code
+}
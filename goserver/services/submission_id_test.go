@@ -0,0 +1,42 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewSubmissionID(t *testing.T) {
+	format := regexp.MustCompile(`^IGD-20261016-[0-9A-HJKMNP-TV-Z]{4}$`)
+	day := time.Date(2026, 10, 16, 23, 59, 0, 0, time.Local)
+
+	seen := make(map[string]bool)
+	for range 200 {
+		id := newSubmissionID(day)
+		if !format.MatchString(id) {
+			t.Fatalf("submission ID %q does not match %s", id, format)
+		}
+		seen[id] = true
+	}
+	// 200 draws from about a million codes should almost never repeat
+	if len(seen) < 190 {
+		t.Fatalf("only %d distinct submission IDs out of 200", len(seen))
+	}
+}
+
+func TestNormalizeSubmissionID(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"IGD-20261016-7K3Q", "IGD-20261016-7K3Q"},
+		{" igd-20261016-7k3q\n", "IGD-20261016-7K3Q"},
+		{"IGD-20261016-O1LI", "IGD-20261016-0111"},
+		{"sub_68f0a1b2c3d4e", "sub_68f0a1b2c3d4e"},
+		{"ESC_1760600000_B1234CD", "ESC_1760600000_B1234CD"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSubmissionID(tt.in); got != tt.want {
+			t.Errorf("normalizeSubmissionID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goserver/models"
+	"goserver/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// laravelBcryptCost matches the default rounds of Laravel's Hash::make
+const laravelBcryptCost = 10
+
+// ErrUserNotFound is returned when no user has the requested ID
+var ErrUserNotFound = errors.New("user not found")
+
+type UserService struct {
+	users repository.UserRepository
+}
+
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+// userError translates repository.ErrNotFound into ErrUserNotFound
+func userError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// ListUsers retrieves all users ordered by ID
+func (s *UserService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return s.users.List(ctx)
+}
+
+// GetUser retrieves a single user by ID
+func (s *UserService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return nil, userError(err)
+	}
+	return user, nil
+}
+
+// CreateUser creates a user, hashing the password so it can log in through Laravel
+func (s *UserService) CreateUser(ctx context.Context, name, email, password, role string) (*models.User, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Name: name, Email: email, Password: hash, Role: role}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser changes a user's name, email and, when role is not empty, role
+func (s *UserService) UpdateUser(ctx context.Context, id uint, name, email, role string) error {
+	return userError(s.users.Update(ctx, &models.User{ID: id, Name: name, Email: email, Role: role}))
+}
+
+// DeleteUser deletes a user
+func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
+	return userError(s.users.Delete(ctx, id))
+}
+
+// EnsureAdmin creates an admin user, or promotes an existing user with the same email to admin.
+// The password of an existing user is only replaced when resetPassword is set.
+// It reports whether a new user was created.
+func (s *UserService) EnsureAdmin(ctx context.Context, name, email, password string, resetPassword bool) (*models.AuthUser, bool, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		user = &models.User{Name: name, Email: email, Password: hash, Role: models.RoleAdmin}
+		if err := s.users.Create(ctx, user); err != nil {
+			return nil, false, fmt.Errorf("failed to create admin user: %w", err)
+		}
+		return user.AuthUser(), true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	if !resetPassword {
+		hash = ""
+	}
+	if err := s.users.UpdateRole(ctx, user.ID, models.RoleAdmin, hash); err != nil {
+		return nil, false, fmt.Errorf("failed to promote user to admin: %w", err)
+	}
+	user.Role = models.RoleAdmin
+
+	return user.AuthUser(), false, nil
+}
+
+// HashPassword hashes a password with bcrypt so Laravel's Hash::check accepts it
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), laravelBcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
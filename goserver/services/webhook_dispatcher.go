@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"goserver/repository"
+)
+
+// Webhook request headers. The signature is "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret (see SignWebhookPayload).
+const (
+	WebhookHeaderID        = "X-Webhook-ID" // delivery ID, unchanged across retries
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	// webhookLease must exceed webhookTimeout so a delivery is not sent twice at once
+	webhookLease      = time.Minute
+	webhookMaxBackoff = 6 * time.Hour
+	// webhookErrorBody is how much of a failed response body is kept in last_error
+	webhookErrorBody = 512
+)
+
+// WebhookDispatcher sends the deliveries queued in the webhook outbox
+type WebhookDispatcher struct {
+	webhooks    repository.WebhookRepository
+	client      *http.Client
+	maxAttempts int
+	retryBase   time.Duration
+}
+
+// NewWebhookDispatcher creates a dispatcher that gives up after maxAttempts attempts.
+// Retries wait retryBase, doubling after every failed attempt up to six hours.
+func NewWebhookDispatcher(webhooks repository.WebhookRepository, maxAttempts int, retryBase time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks: webhooks,
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			// A redirect is reported as a failure instead of being followed with a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		maxAttempts: maxAttempts,
+		retryBase:   retryBase,
+	}
+}
+
+// SignWebhookPayload returns the X-Webhook-Signature value for a payload sent at timestamp
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends due deliveries until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back, then wait for the next poll
+		for {
+			sent, err := d.DispatchDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Webhook dispatch failed", "error", err)
+			}
+			if err != nil || sent < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue claims one batch of due deliveries, sends them concurrently and records the
+// outcome of each. It returns how many deliveries were attempted.
+func (d *WebhookDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	claimed, err := d.webhooks.ClaimDue(ctx, webhookBatchSize, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(claimed))
+	for i, delivery := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// deliver sends one delivery and records the attempt
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery repository.ClaimedDelivery) error {
+	statusCode, sendErr := d.send(ctx, delivery)
+	if sendErr == nil {
+		return d.webhooks.MarkDelivered(ctx, delivery.ID, *statusCode)
+	}
+
+	var retryAt *time.Time
+	if attempt := delivery.Attempts + 1; attempt < d.maxAttempts {
+		next := time.Now().Add(d.retryDelay(attempt))
+		retryAt = &next
+	}
+	return d.webhooks.MarkFailed(ctx, delivery.ID, statusCode, sendErr.Error(), retryAt)
+}
+
+// send POSTs the signed payload. It returns the response status code, when there was a
+// response, and an error unless the status was 2xx.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery repository.ClaimedDelivery) (*int, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goserver-webhooks/1.0")
+	req.Header.Set(WebhookHeaderID, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(delivery.Secret, timestamp, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	statusCode := resp.StatusCode
+	if statusCode >= 200 && statusCode < 300 {
+		return &statusCode, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBody))
+	return &statusCode, fmt.Errorf("endpoint responded %s: %s", resp.Status, bytes.TrimSpace(body))
+}
+
+// retryDelay is the wait after the given failed attempt (1 for the first)
+func (d *WebhookDispatcher) retryDelay(attempt int) time.Duration {
+	delay := d.retryBase
+	for i := 1; i < attempt && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
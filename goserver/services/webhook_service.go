@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"goserver/models"
+	"goserver/repository"
+)
+
+var (
+	// ErrWebhookNotFound is returned when no webhook subscription has the requested ID
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	// ErrWebhookDeliveryNotFound is returned when no webhook delivery has the requested ID
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type WebhookService struct {
+	webhooks repository.WebhookRepository
+}
+
+func NewWebhookService(webhooks repository.WebhookRepository) *WebhookService {
+	return &WebhookService{webhooks: webhooks}
+}
+
+// ListWebhooks retrieves every subscription; secrets are not included
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subs, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+// CreateWebhook adds a subscription. The returned subscription carries the signing secret,
+// generated when the request has none; it is not shown again afterwards.
+func (s *WebhookService) CreateWebhook(ctx context.Context, req models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:         req.URL,
+		Events:      req.Events,
+		Secret:      secret,
+		Description: req.Description,
+		Active:      true,
+	}
+	if err := s.webhooks.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// UpdateWebhook changes the URL, events, description or active flag of a subscription
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, req models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	sub, err := s.webhooks.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	if req.URL != nil {
+		sub.URL = *req.URL
+	}
+	if req.Events != nil {
+		sub.Events = req.Events
+	}
+	if req.Description != nil {
+		sub.Description = req.Description
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := s.webhooks.UpdateSubscription(ctx, sub); err != nil {
+		return nil, webhookError(err)
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	return webhookError(s.webhooks.DeleteSubscription(ctx, id))
+}
+
+// ListDeliveries retrieves the delivery log with pagination; filter on models.DeliveryDead
+// for the dead-letter view
+func (s *WebhookService) ListDeliveries(ctx context.Context, filters models.WebhookDeliveryFilters) ([]models.WebhookDelivery, *models.Meta, error) {
+	if filters.Page <= 0 {
+		filters.Page = 1
+	}
+	if filters.PerPage <= 0 {
+		filters.PerPage = 20
+	}
+	if filters.PerPage > 100 {
+		filters.PerPage = 100
+	}
+
+	deliveries, total, err := s.webhooks.ListDeliveries(ctx, filters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := &models.Meta{
+		CurrentPage: filters.Page,
+		TotalPages:  int(math.Ceil(float64(total) / float64(filters.PerPage))),
+		PerPage:     filters.PerPage,
+		Total:       total,
+	}
+
+	return deliveries, meta, nil
+}
+
+// Redeliver queues a delivery to be sent again right away with a fresh set of attempts
+func (s *WebhookService) Redeliver(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	delivery, err := s.webhooks.Redeliver(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// webhookError translates repository.ErrNotFound into ErrWebhookNotFound
+func webhookError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// webhookEventTypes returns the webhook event types an escort event is sent as
+func webhookEventTypes(event models.EscortEvent) []string {
+	types := []string{event.Type}
+	if event.Type == models.EscortEventStatusChanged && event.Escort != nil {
+		switch event.Escort.Status {
+		case models.StatusVerified:
+			types = append(types, models.WebhookEventVerified)
+		case models.StatusRejected:
+			types = append(types, models.WebhookEventRejected)
+		}
+	}
+	return types
+}
+
+// enqueueWebhooks writes the outbox deliveries of an event. Called with the Store of the
+// transaction that made the change, so deliveries exist exactly when the change commits.
+func enqueueWebhooks(ctx context.Context, webhooks repository.WebhookRepository, event models.EscortEvent) error {
+	for _, eventType := range webhookEventTypes(event) {
+		event.Type = eventType
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+		if _, err := webhooks.Enqueue(ctx, event.ID, eventType, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
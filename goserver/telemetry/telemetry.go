@@ -0,0 +1,105 @@
+// Package telemetry sets up OpenTelemetry tracing. Spans are started for every HTTP request,
+// every EscortService method and every SQL statement, and joined to the caller's trace
+// through the W3C traceparent header.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span exporters (OTEL_TRACES_EXPORTER)
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// ServiceName is reported as service.name unless OTEL_SERVICE_NAME is set
+const ServiceName = "goserver"
+
+// instrumentation names the tracer of this module's own spans
+const instrumentation = "goserver"
+
+// ParseExporter checks an OTEL_TRACES_EXPORTER value; "console" is accepted for stdout
+func ParseExporter(exporter string) (string, error) {
+	switch exporter = strings.ToLower(exporter); exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+		return exporter, nil
+	case "console":
+		return ExporterStdout, nil
+	}
+	return "", fmt.Errorf("must be %s, %s or %s", ExporterOTLP, ExporterStdout, ExporterNone)
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator. The OTLP
+// exporter is configured by the standard OTEL_EXPORTER_OTLP_* variables and sampling by
+// OTEL_TRACES_SAMPLER. With ExporterNone, incoming trace context is still propagated but no
+// spans are recorded. The returned function flushes pending spans and stops the provider.
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s span exporter: %w", exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service for tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span of this module; the global tracer provider is looked up on every call
+// so spans follow the provider installed by Setup
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End records the error *err, if any, on span and ends it. It takes a pointer so that it
+// can be deferred with a named error result:
+//
+//	ctx, span := telemetry.Start(ctx, "EscortService.GetEscortByID")
+//	defer telemetry.End(span, &err)
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestParseExporter(t *testing.T) {
+	for value, want := range map[string]string{"otlp": ExporterOTLP, "STDOUT": ExporterStdout, "console": ExporterStdout, "none": ExporterNone} {
+		if got, err := ParseExporter(value); err != nil || got != want {
+			t.Errorf("ParseExporter(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseExporter("jaeger"); err == nil {
+		t.Error("ParseExporter accepted jaeger")
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	call := func(fail bool) (err error) {
+		_, span := Start(context.Background(), "call")
+		defer End(span, &err)
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	call(false)
+	call(true)
+
+	ended := spans.Ended()
+	if len(ended) != 2 || ended[0].Status().Code != codes.Unset || ended[1].Status().Code != codes.Error {
+		t.Fatalf("span statuses = %v, want unset then error", ended)
+	}
+	if events := ended[1].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Fatalf("failed span events = %v, want the recorded error", events)
+	}
+}
@@ -0,0 +1,55 @@
+<?php
+
+namespace App\Exceptions;
+
+use Exception;
+
+/**
+ * A request the Go API refused (4xx), carrying its message, HTTP status and the "errors"
+ * field of its response so controllers can pass them on to the user
+ */
+class GoApiException extends Exception
+{
+    /**
+     * @var mixed
+     */
+    private $errors;
+
+    /**
+     * @param string $message
+     * @param int $status HTTP status of the Go API response
+     * @param mixed $errors
+     */
+    public function __construct(string $message, int $status, $errors = null)
+    {
+        parent::__construct($message, $status);
+        $this->errors = $errors;
+    }
+
+    /**
+     * Build the exception from a Go API error response
+     *
+     * @param \Psr\Http\Message\ResponseInterface $response
+     * @return static
+     */
+    public static function fromResponse($response): self
+    {
+        $data = json_decode((string) $response->getBody(), true) ?: [];
+
+        return new static(
+            $data['message'] ?? 'Unknown error from Go API',
+            $response->getStatusCode(),
+            $data['errors'] ?? null
+        );
+    }
+
+    /**
+     * The "errors" field of the Go API response
+     *
+     * @return mixed
+     */
+    public function getErrors()
+    {
+        return $this->errors;
+    }
+}
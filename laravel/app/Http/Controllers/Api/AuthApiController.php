@@ -8,6 +8,7 @@
 use Illuminate\Support\Facades\Hash;
 use Illuminate\Validation\ValidationException;
 use App\Models\User;
+use App\Services\GoApiService;
 
 class AuthApiController extends Controller
 {
@@ -61,6 +62,8 @@ public function login(Request $request)
      */
     public function logout(Request $request)
     {
+        // The Go API token created for this user's dashboard calls goes with the session
+        GoApiService::forgetUserToken(Auth::user());
         Auth::logout();
         $request->session()->invalidate();
         $request->session()->regenerateToken();
@@ -5,6 +5,7 @@
 use App\Http\Controllers\Controller;
 use Illuminate\Http\Request;
 use App\Services\GoApiService;
+use App\Exceptions\GoApiException;
 use Illuminate\Support\Facades\Session;
 use Illuminate\Support\Facades\Log;
 use Illuminate\Support\Facades\Validator;
@@ -111,7 +112,7 @@ public function index(Request $request)
                 'message' => 'Failed to retrieve escorts from Go API',
                 'error' => $e->getMessage(),
                 'laravel_session_id' => Session::getId()
-            ], 500);
+            ], $e instanceof GoApiException ? $e->getCode() : 500);
         }
     }
 
@@ -139,7 +140,8 @@ public function store(Request $request)
                 'plat_nomor' => 'required|string|max:20|min:3',
                 'nama_pasien' => 'required|string|max:255|min:3',
                 'foto_pengantar_base64' => 'nullable|string',
-                'status' => 'nullable|in:pending,verified,rejected'
+                // Signed token from the kiosk QR the form was opened with (?kiosk=...)
+                'kiosk_token' => 'nullable|string|max:512'
             ], [
                 'nama_pengantar.required' => 'Nama pengantar wajib diisi.',
                 'nama_pengantar.min' => 'Nama pengantar minimal 3 karakter.',
@@ -149,7 +151,6 @@ public function store(Request $request)
                 'plat_nomor.min' => 'Plat nomor minimal 3 karakter.',
                 'nama_pasien.required' => 'Nama pasien wajib diisi.',
                 'nama_pasien.min' => 'Nama pasien minimal 3 karakter.',
-                'status.in' => 'Status harus berupa pending, verified, atau rejected.',
             ]);
 
             if ($validator->fails()) {
@@ -168,8 +169,8 @@ public function store(Request $request)
             // Prepare data for Go API
             $data = $validator->validated();
 
-            // Call Go API
-            $result = $this->goApiService->createEscort($data);
+            // Call Go API; a retry from the form carries the same Idempotency-Key
+            $result = $this->goApiService->createEscort($data, $request->header('Idempotency-Key'));
 
             // Update session with success data
             Session::put("api_submission_{$submissionId}_completed", now());
@@ -291,9 +292,11 @@ public function show($id)
                 'error' => $e->getMessage()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
@@ -373,9 +376,11 @@ public function update(Request $request, $id)
                 'session_id' => Session::getId()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
@@ -431,9 +436,11 @@ public function destroy($id)
                 'session_id' => Session::getId()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
@@ -460,11 +467,26 @@ public function updateStatus(Request $request, $id)
             Session::put("api_status_update_{$updateId}_escort_id", $id);
             Session::put("api_status_update_{$updateId}_ip", $request->ip());
 
-            // Validate the status
+            // Validate the status; rejecting needs one of the Go API's rejection reason codes
             $validator = Validator::make($request->all(), [
-                'status' => 'required|in:pending,verified,rejected'
+                'status' => 'required|in:pending,verified,rejected',
+                'reason_code' => 'required_if:status,rejected|nullable|string|max:50',
+                'note' => 'nullable|string|max:1000'
+            ], [
+                'reason_code.required_if' => 'Alasan penolakan wajib dipilih.',
+                'note.max' => 'Catatan maksimal 1000 karakter.',
             ]);
 
+            $validator->after(function ($validator) use ($request) {
+                if ($request->status !== 'rejected' || !$request->filled('reason_code')) {
+                    return;
+                }
+                $reasons = $this->goApiService->getRejectionReasons()['data'] ?? [];
+                if (!in_array($request->reason_code, array_column($reasons, 'code'), true)) {
+                    $validator->errors()->add('reason_code', 'Alasan penolakan tidak dikenal.');
+                }
+            });
+
             if ($validator->fails()) {
                 return response()->json([
                     'status' => 'error',
@@ -475,7 +497,12 @@ public function updateStatus(Request $request, $id)
             }
 
             // Call Go API
-            $result = $this->goApiService->updateEscortStatus($id, $request->status);
+            $result = $this->goApiService->updateEscortStatus(
+                $id,
+                $request->status,
+                $request->reason_code,
+                $request->note
+            );
 
             // Track successful status update
             Session::put("api_status_update_{$updateId}_completed", now());
@@ -486,6 +513,7 @@ public function updateStatus(Request $request, $id)
             array_unshift($recentStatusUpdates, [
                 'escort_id' => $id,
                 'new_status' => $request->status,
+                'reason_code' => $request->reason_code,
                 'updated_at' => now(),
                 'update_id' => $updateId,
                 'ip' => $request->ip(),
@@ -513,6 +541,23 @@ public function updateStatus(Request $request, $id)
 
             return response()->json($result);
 
+        } catch (GoApiException $e) {
+            // Refused by the Go API: a missing/unknown reason (400), a disallowed transition
+            // (409, errors.allowed_transitions lists the statuses it can move to), a role
+            // without escorts:verify (403) or a rate limit (429)
+            Log::warning('Go API Proxy Status Update refused', [
+                'escort_id' => $id,
+                'status_code' => $e->getCode(),
+                'error' => $e->getMessage()
+            ]);
+
+            return response()->json([
+                'status' => 'error',
+                'message' => $e->getMessage(),
+                'errors' => $e->getErrors(),
+                'laravel_session_id' => Session::getId()
+            ], $e->getCode());
+
         } catch (\Exception $e) {
             Log::error('Go API Proxy Status Update Error', [
                 'escort_id' => $id,
@@ -520,9 +565,11 @@ public function updateStatus(Request $request, $id)
                 'session_id' => Session::getId()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
@@ -567,7 +614,7 @@ public function getDashboardStats()
                 'message' => 'Failed to retrieve dashboard stats from Go API',
                 'error' => $e->getMessage(),
                 'laravel_session_id' => Session::getId()
-            ], 500);
+            ], $e instanceof GoApiException ? $e->getCode() : 500);
         }
     }
 
@@ -597,9 +644,11 @@ public function getImageBase64($id)
                 'error' => $e->getMessage()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
@@ -664,9 +713,11 @@ public function uploadImageBase64(Request $request, $id)
                 'error' => $e->getMessage()
             ]);
 
+            // Not found, and refusals by the Go API (expired token, role without the
+            // permission, rate limited), keep their status
             $statusCode = 500;
-            if ($e->getCode() == 404) {
-                $statusCode = 404;
+            if ($e->getCode() == 404 || $e instanceof GoApiException) {
+                $statusCode = $e->getCode();
             }
 
             return response()->json([
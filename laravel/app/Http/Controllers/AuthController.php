@@ -7,6 +7,7 @@
 use Illuminate\Support\Facades\Hash;
 use Illuminate\Validation\ValidationException;
 use App\Models\User;
+use App\Services\GoApiService;
 
 class AuthController extends Controller
 {
@@ -77,6 +78,8 @@ public function login(Request $request)
      */
     public function logout(Request $request)
     {
+        // The Go API token created for this user's dashboard calls goes with the session
+        GoApiService::forgetUserToken(Auth::user());
         Auth::logout();
 
         $request->session()->invalidate();
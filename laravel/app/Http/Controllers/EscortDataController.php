@@ -13,6 +13,7 @@
 use BaconQrCode\Renderer\RendererStyle\RendererStyle;
 use BaconQrCode\Writer;
 use App\Services\CsvExportService;
+use App\Services\GoApiService;
 use App\Exports\EscortExport;
 use Carbon\Carbon;
 use Maatwebsite\Excel\Facades\Excel;
@@ -640,6 +641,37 @@ public function updateStatus(Request $request, EscortModel $escort)
         ]);
     }
     
+    /**
+     * Stream an escort photo kept in the Go API's storage, for foto_pengantar values that are
+     * not on the public disk (see EscortModel::getImageUrl)
+     */
+    public function photo(Request $request, EscortModel $escort, GoApiService $goApiService)
+    {
+        $variant = in_array($request->query('variant'), ['thumb', 'medium'], true) ? $request->query('variant') : null;
+        
+        try {
+            $response = $goApiService->getImage($escort->id, array_filter([
+                'If-None-Match' => $request->header('If-None-Match')
+            ]), $variant);
+        } catch (\Exception $e) {
+            abort($e->getCode() == 404 ? 404 : 502);
+        }
+        
+        $headers = ['Cache-Control' => 'private, no-cache'];
+        foreach (['Content-Type', 'Content-Length', 'ETag', 'Last-Modified'] as $header) {
+            if ($response->hasHeader($header)) {
+                $headers[$header] = $response->getHeaderLine($header);
+            }
+        }
+        
+        return response()->stream(function () use ($response) {
+            $body = $response->getBody();
+            while (!$body->eof()) {
+                echo $body->read(8192);
+            }
+        }, $response->getStatusCode(), $headers);
+    }
+    
     /**
      * Generate QR code for form submission URL
      * 
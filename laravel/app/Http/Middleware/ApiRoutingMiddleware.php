@@ -4,6 +4,7 @@
 
 use Closure;
 use Illuminate\Http\Request;
+use Illuminate\Support\Facades\Cache;
 use Illuminate\Support\Facades\Log;
 
 class ApiRoutingMiddleware
@@ -115,61 +116,81 @@ private function getRoutingReason(Request $request): string
     }
 
     /**
-     * Check if Go API is available via health check
+     * Check if Go API is ready via its readiness probe. Only a 200 counts: /readyz answers
+     * 503 while the database, the photo storage or the schema is not usable. The result is
+     * kept in the application cache, shared by all PHP workers, for 60 seconds.
      *
      * @return bool
      */
     private function isGoApiAvailable(): bool
     {
-        static $lastCheck = null;
-        static $lastResult = null;
-        static $checkInterval = 60; // Check every 60 seconds
-
-        $now = time();
+        $checkInterval = 60; // Check every 60 seconds
+
+        return Cache::remember('go_api_available', $checkInterval, function () use ($checkInterval) {
+            try {
+                $goApiUrl = config('services.go_api.url', env('GO_API_URL', 'http://localhost:8080'));
+                $timeout = config('services.go_api.timeout', env('GO_API_TIMEOUT', 5));
+
+                $context = stream_context_create([
+                    'http' => [
+                        'method' => 'GET',
+                        'timeout' => $timeout,
+                        'ignore_errors' => true
+                    ]
+                ]);
+
+                $response = @file_get_contents($goApiUrl . '/readyz', false, $context);
+                $status = $this->responseStatus($http_response_header ?? []);
+                $available = $response !== false && $status === 200;
+
+                $failing = [];
+                if ($available === false && $response !== false) {
+                    $report = json_decode($response, true);
+                    foreach ($report['components'] ?? [] as $component) {
+                        if (($component['status'] ?? null) !== 'ok') {
+                            $failing[] = $component['name'];
+                        }
+                    }
+                }
+
+                Log::debug('Go API Health Check', [
+                    'url' => $goApiUrl . '/readyz',
+                    'status' => $status,
+                    'available' => $available,
+                    'failing' => $failing,
+                    'cached_until' => time() + $checkInterval
+                ]);
+
+                return $available;
+
+            } catch (\Exception $e) {
+                Log::warning('Go API Health Check Failed', [
+                    'error' => $e->getMessage(),
+                    'url' => ($goApiUrl ?? 'unknown') . '/readyz'
+                ]);
+
+                // Cache negative result
+                return false;
+            }
+        });
+    }
 
-        // Use cached result if within check interval
-        if ($lastCheck !== null && ($now - $lastCheck) < $checkInterval && $lastResult !== null) {
-            return $lastResult;
+    /**
+     * Get the HTTP status code of the last response from its headers
+     *
+     * @param array $headers
+     * @return int|null
+     */
+    private function responseStatus(array $headers): ?int
+    {
+        // After redirects the headers of every response are listed; the last status line wins
+        $status = null;
+        foreach ($headers as $header) {
+            if (preg_match('#^HTTP/\S+\s+(\d{3})#', $header, $matches)) {
+                $status = (int) $matches[1];
+            }
         }
 
-        try {
-            $goApiUrl = config('services.go_api.url', env('GO_API_URL', 'http://localhost:8080'));
-            $timeout = config('services.go_api.timeout', env('GO_API_TIMEOUT', 5));
-
-            $context = stream_context_create([
-                'http' => [
-                    'method' => 'GET',
-                    'timeout' => $timeout,
-                    'ignore_errors' => true
-                ]
-            ]);
-
-            $response = @file_get_contents($goApiUrl . '/api/health', false, $context);
-            $available = $response !== false;
-
-            // Cache the result
-            $lastCheck = $now;
-            $lastResult = $available;
-
-            Log::debug('Go API Health Check', [
-                'url' => $goApiUrl . '/api/health',
-                'available' => $available,
-                'cached_until' => $now + $checkInterval
-            ]);
-
-            return $available;
-
-        } catch (\Exception $e) {
-            Log::warning('Go API Health Check Failed', [
-                'error' => $e->getMessage(),
-                'url' => ($goApiUrl ?? 'unknown') . '/api/health'
-            ]);
-
-            // Cache negative result
-            $lastCheck = $now;
-            $lastResult = false;
-
-            return false;
-        }
+        return $status;
     }
 }
\ No newline at end of file
@@ -82,20 +82,31 @@ public function hasImage()
         return !empty($this->foto_pengantar);
     }
     
-    // Get image storage URL
+    // Check if the image is on Laravel's public disk ("uploads/..."). Any other value is a
+    // storage key of the Go API, e.g. after `goserver storage migrate` rewrote it.
+    public function imageOnPublicDisk()
+    {
+        return $this->hasImage() && \Illuminate\Support\Str::startsWith($this->foto_pengantar, 'uploads/');
+    }
+    
+    // Get image URL: the public disk, or the dashboard route streaming it from the Go API
     public function getImageUrl()
     {
         if (!$this->hasImage()) {
             return null;
         }
         
+        if (!$this->imageOnPublicDisk()) {
+            return route('escorts.photo', $this);
+        }
+        
         return \Illuminate\Support\Facades\Storage::url($this->foto_pengantar);
     }
     
-    // Get image storage path
+    // Get image storage path (null when the Go API stores the image)
     public function getImagePath()
     {
-        if (!$this->hasImage()) {
+        if (!$this->imageOnPublicDisk()) {
             return null;
         }
         
@@ -105,7 +116,7 @@ public function getImagePath()
     // Check if image file exists in storage
     public function imageExists()
     {
-        if (!$this->hasImage()) {
+        if (!$this->imageOnPublicDisk()) {
             return false;
         }
         
@@ -2,28 +2,75 @@
 
 namespace App\Services;
 
+use App\Exceptions\GoApiException;
 use GuzzleHttp\Client;
 use GuzzleHttp\Exception\GuzzleException;
+use GuzzleHttp\HandlerStack;
+use GuzzleHttp\Middleware;
+use Illuminate\Support\Facades\Cache;
+use Illuminate\Support\Facades\Crypt;
 use Illuminate\Support\Facades\Log;
 use Illuminate\Support\Facades\Config;
+use Illuminate\Support\Str;
+use Laravel\Sanctum\PersonalAccessToken;
+use Psr\Http\Message\RequestInterface;
 
 class GoApiService
 {
     private $client;
     private $baseUrl;
+    private $requestId;
+    private $traceId;
+
+    /**
+     * Request fields kept out of the logs
+     */
+    private const PII_FIELDS = ['nama_pengantar', 'nama_pasien', 'nomor_hp', 'plat_nomor', 'email', 'password'];
+
+    /**
+     * Name of the per-user Sanctum token used for the Go API, and the cache key prefix of its
+     * encrypted plain text
+     */
+    private const USER_TOKEN_NAME = 'go-api';
+    private const USER_TOKEN_CACHE_PREFIX = 'go_api_token:';
 
     public function __construct()
     {
         // Get Go API URL from config or environment
         $this->baseUrl = config('services.go_api.url', env('GO_API_URL', 'http://localhost:8080'));
         
+        // Sent as X-Request-ID so these logs can be matched with the Go API's request logs
+        $this->requestId = request()->header('X-Request-ID') ?: (string) Str::uuid();
+
+        $headers = [
+            'Accept' => 'application/json',
+            'Content-Type' => 'application/json',
+            'X-Request-ID' => $this->requestId,
+            'traceparent' => $this->traceparent(),
+        ];
+
+        // The Go API rate limits public routes (submissions, QR codes, session stats) per client
+        // IP and trusts X-Forwarded-For from TRUSTED_PROXIES; without it every visitor would
+        // share the bucket of this server's address
+        if ($clientIp = request()->ip()) {
+            $headers['X-Forwarded-For'] = $clientIp;
+        }
+
+        // Protected Go API routes validate Sanctum personal access tokens. The token is picked
+        // when each request is sent: this service is built with the controller, before the
+        // auth middleware has resolved the signed-in user.
+        $stack = HandlerStack::create();
+        $stack->push(Middleware::mapRequest(function (RequestInterface $request) {
+            $token = $this->accessToken();
+
+            return $token ? $request->withHeader('Authorization', 'Bearer ' . $token) : $request;
+        }));
+
         $this->client = new Client([
             'base_uri' => $this->baseUrl,
             'timeout' => 30,
-            'headers' => [
-                'Accept' => 'application/json',
-                'Content-Type' => 'application/json',
-            ]
+            'headers' => $headers,
+            'handler' => $stack
         ]);
 
         // Log Go API service initialization
@@ -66,26 +113,79 @@ public function getEscorts(array $params = []): array
                 'error' => $e->getMessage(),
                 'params' => $params
             ]);
+
+            $this->rethrowRefusal($e);
+
             throw new \Exception('Failed to retrieve escorts from Go API: ' . $e->getMessage());
         }
     }
 
+    /**
+     * Export escorts as CSV or XLSX
+     *
+     * The response body is streamed, so callers can pass it on without buffering the file
+     * (e.g. response()->streamDownload(fn () => fpassthru($response->getBody()->detach()), $filename)).
+     *
+     * @param array $params Listing filters plus start_date / end_date (Y-m-d)
+     * @param string $format csv or xlsx
+     * @return \Psr\Http\Message\ResponseInterface
+     * @throws \Exception
+     */
+    public function exportEscorts(array $params = [], string $format = 'xlsx')
+    {
+        $startTime = microtime(true);
+        $endpoint = '/api/escort/export';
+        $params['format'] = $format;
+
+        try {
+            $response = $this->client->get($endpoint, [
+                'query' => $params,
+                'stream' => true,
+                'timeout' => 300,
+                'headers' => ['Accept' => '*/*']
+            ]);
+
+            $duration = microtime(true) - $startTime;
+            $this->logApiConnection('GET', $endpoint, $params, $response->getStatusCode(), $duration, 'success');
+
+            return $response;
+        } catch (GuzzleException $e) {
+            $duration = microtime(true) - $startTime;
+            $statusCode = method_exists($e, 'getResponse') && $e->getResponse() ? $e->getResponse()->getStatusCode() : null;
+
+            $this->logApiConnection('GET', $endpoint, $params, $statusCode, $duration, 'failed');
+
+            Log::error('Go API exportEscorts failed', [
+                'error' => $e->getMessage(),
+                'params' => $params
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            throw new \Exception('Failed to export escorts from Go API: ' . $e->getMessage());
+        }
+    }
+
     /**
      * Create a new escort
      *
      * @param array $data
+     * @param string|null $idempotencyKey Sent as Idempotency-Key so a retried submission is not stored twice
      * @return array
      * @throws \Exception
      */
-    public function createEscort(array $data): array
+    public function createEscort(array $data, ?string $idempotencyKey = null): array
     {
         $startTime = microtime(true);
         $endpoint = '/api/escort';
 
         try {
-            $response = $this->client->post($endpoint, [
-                'json' => $data
-            ]);
+            $options = ['json' => $data];
+            if ($idempotencyKey) {
+                $options['headers']['Idempotency-Key'] = $idempotencyKey;
+            }
+
+            $response = $this->client->post($endpoint, $options);
 
             $duration = microtime(true) - $startTime;
             $result = $this->handleResponse($response);
@@ -101,7 +201,7 @@ public function createEscort(array $data): array
             
             Log::error('Go API createEscort failed', [
                 'error' => $e->getMessage(),
-                'data' => $data
+                'data' => $this->redactPii($data)
             ]);
             throw new \Exception('Failed to create escort in Go API: ' . $e->getMessage());
         }
@@ -125,6 +225,8 @@ public function getEscort(int $id): array
                 'error' => $e->getMessage(),
                 'escort_id' => $id
             ]);
+
+            $this->rethrowRefusal($e);
             
             // Handle 404 specifically
             if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
@@ -135,6 +237,67 @@ public function getEscort(int $id): array
         }
     }
 
+    /**
+     * Get an escort by its submission ID (ticket code such as IGD-20261016-7K3Q)
+     *
+     * @param string $submissionId
+     * @return array
+     * @throws \Exception
+     */
+    public function getEscortBySubmission(string $submissionId): array
+    {
+        try {
+            $response = $this->client->get('/api/escort/by-submission/' . rawurlencode($submissionId));
+
+            return $this->handleResponse($response);
+        } catch (GuzzleException $e) {
+            Log::error('Go API getEscortBySubmission failed', [
+                'error' => $e->getMessage(),
+                'submission_id' => $submissionId
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
+                throw new \Exception('Escort not found', 404);
+            }
+
+            throw new \Exception('Failed to retrieve escort from Go API: ' . $e->getMessage());
+        }
+    }
+
+    /**
+     * Get the public, PII-masked receipt behind a receipt token
+     *
+     * @param string $token
+     * @return array
+     * @throws \Exception
+     */
+    public function getReceipt(string $token): array
+    {
+        try {
+            $response = $this->client->get('/api/receipt/' . rawurlencode($token));
+
+            return $this->handleResponse($response);
+        } catch (GuzzleException $e) {
+            Log::error('Go API getReceipt failed', [
+                'error' => $e->getMessage()
+            ]);
+
+            if (method_exists($e, 'getResponse') && $e->getResponse()) {
+                $status = $e->getResponse()->getStatusCode();
+                if ($status === 404) {
+                    throw new \Exception('Receipt not found', 404);
+                }
+                if ($status === 410) {
+                    throw new \Exception('Receipt has expired', 410);
+                }
+            }
+
+            throw new \Exception('Failed to retrieve receipt from Go API: ' . $e->getMessage());
+        }
+    }
+
     /**
      * Update an existing escort
      *
@@ -155,8 +318,10 @@ public function updateEscort(int $id, array $data): array
             Log::error('Go API updateEscort failed', [
                 'error' => $e->getMessage(),
                 'escort_id' => $id,
-                'data' => $data
+                'data' => $this->redactPii($data)
             ]);
+
+            $this->rethrowRefusal($e);
             
             // Handle 404 specifically
             if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
@@ -185,6 +350,8 @@ public function deleteEscort(int $id): array
                 'error' => $e->getMessage(),
                 'escort_id' => $id
             ]);
+
+            $this->rethrowRefusal($e);
             
             // Handle 404 specifically
             if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
@@ -198,16 +365,31 @@ public function deleteEscort(int $id): array
     /**
      * Update escort status
      *
+     * Go requires a reason_code when rejecting. Refusals of the change itself (400 for a
+     * missing or unknown reason, 409 for a disallowed transition with its allowed_transitions)
+     * are thrown as GoApiException so the caller can show them.
+     *
      * @param int $id
      * @param string $status
+     * @param string|null $reasonCode Code from getRejectionReasons(), required for rejected
+     * @param string|null $note
      * @return array
+     * @throws \App\Exceptions\GoApiException
      * @throws \Exception
      */
-    public function updateEscortStatus(int $id, string $status): array
+    public function updateEscortStatus(int $id, string $status, ?string $reasonCode = null, ?string $note = null): array
     {
+        $payload = array_filter([
+            'status' => $status,
+            'reason_code' => $reasonCode,
+            'note' => $note,
+        ], function ($value) {
+            return $value !== null && $value !== '';
+        });
+
         try {
             $response = $this->client->patch("/api/escort/{$id}/status", [
-                'json' => ['status' => $status]
+                'json' => $payload
             ]);
 
             return $this->handleResponse($response);
@@ -215,18 +397,54 @@ public function updateEscortStatus(int $id, string $status): array
             Log::error('Go API updateEscortStatus failed', [
                 'error' => $e->getMessage(),
                 'escort_id' => $id,
-                'status' => $status
+                'status' => $status,
+                'reason_code' => $reasonCode
             ]);
+
+            $this->rethrowRefusal($e);
             
-            // Handle 404 specifically
-            if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
-                throw new \Exception('Escort not found', 404);
+            if (method_exists($e, 'getResponse') && $e->getResponse()) {
+                $statusCode = $e->getResponse()->getStatusCode();
+
+                // Handle 404 specifically
+                if ($statusCode === 404) {
+                    throw new \Exception('Escort not found', 404);
+                }
+                if ($statusCode === 400 || $statusCode === 409) {
+                    throw GoApiException::fromResponse($e->getResponse());
+                }
             }
             
             throw new \Exception('Failed to update escort status in Go API: ' . $e->getMessage());
         }
     }
 
+    /**
+     * Get the rejection reasons staff can pick when rejecting an escort
+     *
+     * @param bool $includeInactive Also list retired codes
+     * @return array
+     * @throws \Exception
+     */
+    public function getRejectionReasons(bool $includeInactive = false): array
+    {
+        try {
+            $response = $this->client->get('/api/rejection-reasons', [
+                'query' => $includeInactive ? ['include_inactive' => 'true'] : []
+            ]);
+
+            return $this->handleResponse($response);
+        } catch (GuzzleException $e) {
+            Log::error('Go API getRejectionReasons failed', [
+                'error' => $e->getMessage()
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            throw new \Exception('Failed to retrieve rejection reasons from Go API: ' . $e->getMessage());
+        }
+    }
+
     /**
      * Get dashboard statistics
      *
@@ -256,10 +474,53 @@ public function getDashboardStats(): array
             Log::error('Go API getDashboardStats failed', [
                 'error' => $e->getMessage()
             ]);
+
+            $this->rethrowRefusal($e);
+
             throw new \Exception('Failed to retrieve dashboard stats from Go API: ' . $e->getMessage());
         }
     }
 
+    /**
+     * Get submissions per kiosk location
+     *
+     * @param array $params start_date / end_date (YYYY-MM-DD) and the escort list filters
+     * @return array
+     * @throws \Exception
+     */
+    public function getKioskLocationReport(array $params = []): array
+    {
+        $startTime = microtime(true);
+        $endpoint = '/api/reports/kiosk-locations';
+
+        try {
+            $response = $this->client->get($endpoint, [
+                'query' => $params
+            ]);
+
+            $duration = microtime(true) - $startTime;
+            $result = $this->handleResponse($response);
+
+            $this->logApiConnection('GET', $endpoint, $params, $response->getStatusCode(), $duration, 'success');
+
+            return $result;
+        } catch (GuzzleException $e) {
+            $duration = microtime(true) - $startTime;
+            $statusCode = method_exists($e, 'getResponse') && $e->getResponse() ? $e->getResponse()->getStatusCode() : null;
+
+            $this->logApiConnection('GET', $endpoint, $params, $statusCode, $duration, 'failed');
+
+            Log::error('Go API getKioskLocationReport failed', [
+                'error' => $e->getMessage(),
+                'params' => $params
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            throw new \Exception('Failed to retrieve kiosk location report from Go API: ' . $e->getMessage());
+        }
+    }
+
     /**
      * Get image as base64
      *
@@ -278,6 +539,8 @@ public function getImageBase64(int $id): array
                 'error' => $e->getMessage(),
                 'escort_id' => $id
             ]);
+
+            $this->rethrowRefusal($e);
             
             // Handle 404 specifically
             if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
@@ -309,6 +572,8 @@ public function uploadImageBase64(int $id, array $imageData): array
                 'error' => $e->getMessage(),
                 'escort_id' => $id
             ]);
+
+            $this->rethrowRefusal($e);
             
             // Handle 404 specifically
             if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
@@ -319,6 +584,81 @@ public function uploadImageBase64(int $id, array $imageData): array
         }
     }
 
+    /**
+     * Upload an image file as multipart/form-data, streamed from disk
+     *
+     * @param int $id
+     * @param string $path Local path of the image file
+     * @param string|null $filename Name sent with the upload (defaults to the file's basename)
+     * @return array
+     * @throws \Exception
+     */
+    public function uploadImage(int $id, string $path, ?string $filename = null): array
+    {
+        try {
+            $response = $this->client->post("/api/escort/{$id}/image", [
+                'multipart' => [
+                    [
+                        'name' => 'foto_pengantar',
+                        'contents' => fopen($path, 'r'),
+                        'filename' => $filename ?? basename($path),
+                    ],
+                ],
+            ]);
+
+            return $this->handleResponse($response);
+        } catch (GuzzleException $e) {
+            Log::error('Go API uploadImage failed', [
+                'error' => $e->getMessage(),
+                'escort_id' => $id
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            // Handle 404 specifically
+            if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
+                throw new \Exception('Escort not found', 404);
+            }
+
+            throw new \Exception('Failed to upload image to Go API: ' . $e->getMessage());
+        }
+    }
+
+    /**
+     * Get the raw image as a streamed response (pass Range / If-None-Match in $headers).
+     * $variant selects a smaller JPEG copy: 'thumb' (160px) or 'medium' (640px).
+     *
+     * @param int $id
+     * @param array $headers
+     * @param string|null $variant
+     * @return \Psr\Http\Message\ResponseInterface
+     * @throws \Exception
+     */
+    public function getImage(int $id, array $headers = [], ?string $variant = null)
+    {
+        try {
+            return $this->client->get("/api/escort/{$id}/image", [
+                'stream' => true,
+                'query' => $variant ? ['variant' => $variant] : [],
+                'headers' => array_merge(['Accept' => 'image/*'], $headers)
+            ]);
+        } catch (GuzzleException $e) {
+            Log::error('Go API getImage failed', [
+                'error' => $e->getMessage(),
+                'escort_id' => $id
+            ]);
+
+            $this->rethrowRefusal($e);
+
+            // Handle 404 specifically
+            if (method_exists($e, 'getResponse') && $e->getResponse() && $e->getResponse()->getStatusCode() === 404) {
+                throw new \Exception('Image not found', 404);
+            }
+
+            throw new \Exception('Failed to retrieve image from Go API: ' . $e->getMessage());
+        }
+    }
+
     /**
      * Generate QR code (GET method - returns PNG)
      *
@@ -361,7 +701,7 @@ public function generateQrCodeJson(array $data): array
         } catch (GuzzleException $e) {
             Log::error('Go API generateQrCodeJson failed', [
                 'error' => $e->getMessage(),
-                'data' => $data
+                'data' => $this->redactPii($data)
             ]);
             throw new \Exception('Failed to generate QR code JSON from Go API: ' . $e->getMessage());
         }
@@ -460,10 +800,12 @@ private function logApiConnection(string $method, string $endpoint, array $data
             'full_url' => $this->baseUrl . $endpoint,
             'timestamp' => now()->toISOString(),
             'result' => $result,
+            'request_id' => $this->requestId,
+            'trace_id' => $this->traceId,
         ];
 
         if (!empty($data)) {
-            $logData['request_data'] = $data;
+            $logData['request_data'] = $this->redactPii($data);
         }
 
         if ($statusCode !== null) {
@@ -481,6 +823,135 @@ private function logApiConnection(string $method, string $endpoint, array $data
         }
     }
 
+    /**
+     * Sanctum token sent to the Go API. Calls made for a signed-in user carry that user's own
+     * token, so the Go API checks their role and records them as changed_by in the escort status
+     * history. GO_API_TOKEN is only used for calls made without a user (public form
+     * submissions, console commands).
+     *
+     * @return string|null
+     */
+    private function accessToken(): ?string
+    {
+        $user = request()->user() ?: auth('sanctum')->user();
+        if (!$user) {
+            return config('services.go_api.token');
+        }
+
+        // A client that authenticated with its own Sanctum token has it forwarded as is
+        if (request()->bearerToken() && $user->currentAccessToken() instanceof PersonalAccessToken) {
+            return request()->bearerToken();
+        }
+
+        return self::userToken($user);
+    }
+
+    /**
+     * The user's "go-api" Sanctum token, created the first time their session calls the Go
+     * API. Sanctum only reveals the plain text token when it is created, so it is kept
+     * encrypted in the cache.
+     *
+     * @param \App\Models\User $user
+     * @return string
+     */
+    private static function userToken($user): string
+    {
+        $key = self::USER_TOKEN_CACHE_PREFIX . $user->getKey();
+        if ($cached = Cache::get($key)) {
+            return Crypt::decryptString($cached);
+        }
+
+        // Tokens of expired cache entries are removed; ones a moment old may belong to a
+        // concurrent request that created its own
+        $user->tokens()
+            ->where('name', self::USER_TOKEN_NAME)
+            ->where('created_at', '<', now()->subMinute())
+            ->delete();
+
+        $token = $user->createToken(self::USER_TOKEN_NAME)->plainTextToken;
+
+        // Renewed a minute before Sanctum's expiration, if there is one
+        $expiration = config('sanctum.expiration');
+        $ttl = $expiration ? now()->addMinutes(max(1, $expiration - 1)) : now()->addDay();
+        Cache::put($key, Crypt::encryptString($token), $ttl);
+
+        return $token;
+    }
+
+    /**
+     * Revoke the user's "go-api" token, called on logout
+     *
+     * @param \App\Models\User|null $user
+     * @return void
+     */
+    public static function forgetUserToken($user): void
+    {
+        if (!$user) {
+            return;
+        }
+
+        Cache::forget(self::USER_TOKEN_CACHE_PREFIX . $user->getKey());
+        $user->tokens()->where('name', self::USER_TOKEN_NAME)->delete();
+    }
+
+    /**
+     * W3C traceparent header putting the Go API's spans in the trace of this request. The
+     * trace of an incoming traceparent is continued, otherwise a new one is started; the Go
+     * API's trace_id log field then matches the trace_id logged here.
+     *
+     * @return string
+     */
+    private function traceparent(): string
+    {
+        $incoming = (string) request()->header('traceparent');
+        if (preg_match('/^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$/', $incoming, $matches)
+            && $matches[1] !== str_repeat('0', 32)) {
+            $this->traceId = $matches[1];
+            $flags = $matches[2];
+        } else {
+            $this->traceId = bin2hex(random_bytes(16));
+            $flags = '01';
+        }
+
+        return '00-' . $this->traceId . '-' . bin2hex(random_bytes(8)) . '-' . $flags;
+    }
+
+    /**
+     * Replace the values of PII fields for logging
+     *
+     * @param array $data
+     * @return array
+     */
+    private function redactPii(array $data): array
+    {
+        foreach ($data as $key => $value) {
+            if (in_array(strtolower((string) $key), self::PII_FIELDS, true)) {
+                $data[$key] = '[REDACTED]';
+            } elseif (is_array($value)) {
+                $data[$key] = $this->redactPii($value);
+            }
+        }
+
+        return $data;
+    }
+
+    /**
+     * Throw a GoApiException for responses that refuse the caller rather than fail: 401
+     * (token revoked or expired), 403 (the user's role lacks the permission) and 429 (rate
+     * limited), so controllers can answer with the same status
+     *
+     * @param GuzzleException $e
+     * @return void
+     * @throws GoApiException
+     */
+    private function rethrowRefusal(GuzzleException $e): void
+    {
+        if (method_exists($e, 'getResponse') && $e->getResponse()
+            && in_array($e->getResponse()->getStatusCode(), [401, 403, 429], true)) {
+            throw GoApiException::fromResponse($e->getResponse());
+        }
+    }
+
     /**
      * Handle HTTP response from Go API
      *
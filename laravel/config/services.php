@@ -34,6 +34,8 @@
         'url' => env('GO_API_URL', 'http://localhost:8080'),
         'timeout' => env('GO_API_TIMEOUT', 30),
         'retry_attempts' => env('GO_API_RETRY_ATTEMPTS', 3),
+        // Sanctum personal access token ("<id>|<token>") used for protected Go API routes
+        'token' => env('GO_API_TOKEN'),
     ],
 
 ];
@@ -152,6 +152,24 @@ class="logo">
 @push('scripts')
 <script src="https://cdn.jsdelivr.net/npm/sweetalert2@11.10.5/dist/sweetalert2.all.min.js"></script>
 <script>
+    // One Idempotency-Key per form fill: retries and double taps send the same key, so the
+    // Go API replays the first response instead of storing the escort twice
+    function newIdempotencyKey() {
+        if (window.crypto && crypto.randomUUID) {
+            return crypto.randomUUID();
+        }
+        const bytes = crypto.getRandomValues(new Uint8Array(16));
+        bytes[6] = (bytes[6] & 0x0f) | 0x40;
+        bytes[8] = (bytes[8] & 0x3f) | 0x80;
+        const hex = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+        return `${hex.slice(0, 8)}-${hex.slice(8, 12)}-${hex.slice(12, 16)}-${hex.slice(16, 20)}-${hex.slice(20)}`;
+    }
+    let idempotencyKey = newIdempotencyKey();
+
+    // Signed token of the kiosk QR code the form was opened with (?kiosk=...), sent back so the
+    // submission is attributed to that location
+    const kioskToken = new URLSearchParams(window.location.search).get('kiosk');
+
     $(document).ready(function() {
         // File input handling
         $('#foto_pengantar').on('change', function() {
@@ -254,6 +272,9 @@ class="logo">
                     formData.append('nomor_hp', $('#nomor_hp').val());
                     formData.append('plat_nomor', $('#plat_nomor').val());
                     formData.append('nama_pasien', $('#nama_pasien').val());
+                    if (kioskToken) {
+                        formData.append('kiosk_token', kioskToken);
+                    }
                     
                     // Add base64 image data
                     formData.append('foto_pengantar_base64', e.target.result);
@@ -299,8 +320,14 @@ function submitFormData(formData, submitBtn) {
                 data: formData,
                 processData: false,
                 contentType: false,
+                headers: {
+                    'Idempotency-Key': idempotencyKey
+                },
                 success: function (response) {
                     submitBtn.removeClass('loading').prop('disabled', false);
+
+                    // The next entry is a new submission
+                    idempotencyKey = newIdempotencyKey();
                     
                     // Store successful submission data
                     const submissionSuccess = {
@@ -357,6 +384,13 @@ function submitFormData(formData, submitBtn) {
                 },
                 error: function (xhr) {
                     submitBtn.removeClass('loading').prop('disabled', false);
+
+                    // Network errors, server errors, 409 (first attempt still running) and 429
+                    // are retried with the same key. Other refusals (e.g. validation) need
+                    // corrected data, which the Go API would reject under the old key.
+                    if (xhr.status >= 400 && xhr.status < 500 && xhr.status !== 409 && xhr.status !== 429) {
+                        idempotencyKey = newIdempotencyKey();
+                    }
                     
                     // Store error details
                     const submissionError = {
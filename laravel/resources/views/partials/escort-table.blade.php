@@ -99,7 +99,7 @@ class="btn btn-sm btn-info detail-btn"
                                         'status_badge_class' => $escort->getStatusBadgeClass(),
                                         'created_at' => $escort->created_at->format('d/m/Y H:i'),
                                         'created_at_diff' => $escort->created_at->diffForHumans(),
-                                        'foto_pengantar' => $escort->foto_pengantar ? asset('storage/' . $escort->foto_pengantar) : null
+                                        'foto_pengantar' => $escort->getImageUrl()
                                     ]) }}"
                                     title="Lihat Detail">
                                 <i class="fas fa-eye"></i>
@@ -50,6 +50,9 @@
     // Escort status management
     Route::patch('/escorts/{escort}/status', [EscortDataController::class, 'updateStatus'])->name('escorts.update-status');
     
+    // Escort photos kept in the Go API's storage (?variant=thumb|medium for smaller copies)
+    Route::get('/escorts/{escort}/photo', [EscortDataController::class, 'photo'])->name('escorts.photo');
+    
     // Data export/download routes
     Route::post('/dashboard/download/csv', [EscortDataController::class, 'downloadCsv'])->name('dashboard.download.csv');
     Route::post('/dashboard/download/excel', [EscortDataController::class, 'downloadExcel'])->name('dashboard.download.excel');
@@ -141,7 +141,7 @@ start_go_server() {
     cd "$SCRIPT_DIR"
     
     # Wait for server to be ready
-    if wait_for_server "http://localhost:$GO_PORT/api/health" "Go API Server"; then
+    if wait_for_server "http://localhost:$GO_PORT/readyz" "Go API Server"; then
         return 0
     else
         return 1